@@ -0,0 +1,124 @@
+// Command admin runs maintenance tasks against the marketplace database.
+//
+// Usage:
+//
+//	admin reencrypt-bank-accounts [-batch 100]
+//	admin grant-role -username alice -role admin
+//	admin revoke-role -username alice -role admin
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	bankaccount "github.com/citadel-corp/shopifyx-marketplace/internal/bank_account"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/encryption"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
+)
+
+func main() {
+	slogHandler := slog.NewTextHandler(os.Stdout, nil)
+	slog.SetDefault(slog.New(slogHandler))
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "reencrypt-bank-accounts":
+		err = reencryptBankAccounts(os.Args[2:])
+	case "grant-role":
+		err = updateRole(os.Args[1], os.Args[2:], true)
+	case "revoke-role":
+		err = updateRole(os.Args[1], os.Args[2:], false)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: admin <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  reencrypt-bank-accounts  seal every bank account number with BANK_ACCOUNT_KEY_ID")
+	fmt.Fprintln(os.Stderr, "  grant-role               give a user a role")
+	fmt.Fprintln(os.Stderr, "  revoke-role              take a role away from a user")
+}
+
+// reencryptBankAccounts moves every bank account number onto the active key,
+// one batch per transaction, so it can run while the service is serving traffic.
+func reencryptBankAccounts(args []string) error {
+	fs := flag.NewFlagSet("reencrypt-bank-accounts", flag.ExitOnError)
+	batch := fs.Int("batch", 100, "rows re-encrypted per transaction")
+	fs.Parse(args)
+
+	keyring, err := encryption.ParseKeyring(os.Getenv("BANK_ACCOUNT_KEYS"), os.Getenv("BANK_ACCOUNT_KEY_ID"))
+	if err != nil {
+		return fmt.Errorf("cannot load bank account encryption keys: %w", err)
+	}
+	db, err := db.Connect(db.URLFromEnv())
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer db.DB().Close()
+
+	repository := bankaccount.NewRepository(db, keyring)
+	ctx := context.Background()
+	total := 0
+	for {
+		n, err := repository.Reencrypt(ctx, *batch)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < *batch {
+			break
+		}
+	}
+	slog.Info(fmt.Sprintf("re-encrypted %d bank accounts with key %s", total, keyring.ActiveKeyID()))
+	return nil
+}
+
+// updateRole grants or revokes a role. Users pick up the change when their
+// access token is next refreshed.
+func updateRole(name string, args []string, grant bool) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	username := fs.String("username", "", "username of the user")
+	roleName := fs.String("role", "", fmt.Sprintf("one of %v", role.All))
+	fs.Parse(args)
+
+	r := role.Role(*roleName)
+	if *username == "" || !r.Valid() {
+		fs.Usage()
+		os.Exit(2)
+	}
+	db, err := db.Connect(db.URLFromEnv())
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer db.DB().Close()
+
+	repository := user.NewRepository(db)
+	if grant {
+		err = repository.GrantRole(context.Background(), *username, r)
+	} else {
+		err = repository.RevokeRole(context.Background(), *username, r)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("%s %s for %s", name, r, *username))
+	return nil
+}
@@ -9,17 +9,34 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/audit"
 	bankaccount "github.com/citadel-corp/shopifyx-marketplace/internal/bank_account"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/encryption"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/jwt"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/mail"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/middleware"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc/oidctest"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/password"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/scope"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/export"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/image"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/product"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/returns"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/shipping"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/shop"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
 	"github.com/gorilla/mux"
 )
@@ -30,13 +47,7 @@ func main() {
 
 	// Connect to database
 	env := os.Getenv("ENV")
-	sslMode := "disable"
-	if env == "production" {
-		sslMode = "verify-full sslrootcert=ap-southeast-1-bundle.pem"
-	}
-	dbURL := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), sslMode)
-	db, err := db.Connect(dbURL)
+	db, err := db.Connect(db.URLFromEnv())
 	if err != nil {
 		slog.Error(fmt.Sprintf("Cannot connect to database: %v", err))
 		os.Exit(1)
@@ -49,21 +60,228 @@ func main() {
 	// 	os.Exit(1)
 	// }
 
+	// sign tokens with key pairs when configured, JWT_SECRET is still accepted for
+	// tokens issued before the switch
+	if os.Getenv("JWT_PRIVATE_KEYS") != "" {
+		jwtKeys, err := jwt.LoadKeySet(os.Getenv("JWT_PRIVATE_KEYS"), os.Getenv("JWT_PUBLIC_KEYS"), os.Getenv("JWT_SIGNING_KEY_ID"))
+		if err != nil {
+			slog.Error(fmt.Sprintf("Cannot load JWT keys: %v", err))
+			os.Exit(1)
+		}
+		if secret := os.Getenv("JWT_SECRET"); secret != "" {
+			jwtKeys.WithSecret([]byte(secret))
+		}
+		jwt.SetKeySet(jwtKeys)
+	}
+
+	// hash new passwords with PASSWORD_HASHER, bcrypt hashes are verified either way and
+	// replaced on the next login
+	bcryptHasher := password.NewBcryptHasher(intFromEnv("BCRYPT_COST", intFromEnv("BCRYPT_SALT", 12)))
+	argon2idHasher := password.NewArgon2idHasher(password.Argon2idParams{
+		Memory:      uint32(intFromEnv("ARGON2_MEMORY", int(password.DefaultArgon2idParams.Memory))),
+		Iterations:  uint32(intFromEnv("ARGON2_ITERATIONS", int(password.DefaultArgon2idParams.Iterations))),
+		Parallelism: uint8(intFromEnv("ARGON2_PARALLELISM", int(password.DefaultArgon2idParams.Parallelism))),
+		SaltLength:  password.DefaultArgon2idParams.SaltLength,
+		KeyLength:   password.DefaultArgon2idParams.KeyLength,
+	})
+	var passwords *password.Manager
+	switch os.Getenv("PASSWORD_HASHER") {
+	case "", "argon2id":
+		passwords = password.NewManager(argon2idHasher, bcryptHasher)
+	case "bcrypt":
+		passwords = password.NewManager(bcryptHasher, argon2idHasher)
+	default:
+		slog.Error(fmt.Sprintf("Unknown password hasher: %s", os.Getenv("PASSWORD_HASHER")))
+		os.Exit(1)
+	}
+	passwordPolicy := password.Policy{
+		MinLength: intFromEnv("PASSWORD_MIN_LENGTH", 8),
+		MaxLength: intFromEnv("PASSWORD_MAX_LENGTH", 64),
+	}
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		err = passwordPolicy.LoadBreachedList(path)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Cannot load breached password list: %v", err))
+			os.Exit(1)
+		}
+	}
+	password.SetPolicy(passwordPolicy)
+
 	// initialize user domain
 	userRepository := user.NewRepository(db)
-	userService := user.NewService(userRepository)
+	sessionCache := user.NewSessionCache(userRepository, durationFromEnv("SESSION_CACHE_TTL", 30*time.Second))
+	middleware.SetSessionChecker(sessionCache)
+	middleware.SetSuspensionChecker(sessionCache)
+	middleware.SetAPITokenVerifier(user.NewAPITokenVerifier(userRepository))
+	usernamePolicy := user.ThrottlePolicy{
+		FreeAttempts:    intFromEnv("LOGIN_FREE_ATTEMPTS", 5),
+		BaseDelay:       durationFromEnv("LOGIN_BACKOFF_BASE", time.Second),
+		MaxDelay:        durationFromEnv("LOGIN_BACKOFF_MAX", 5*time.Minute),
+		LockoutAttempts: intFromEnv("LOGIN_LOCKOUT_ATTEMPTS", 10),
+		LockoutDuration: durationFromEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		Window:          durationFromEnv("LOGIN_FAILURE_WINDOW", time.Hour),
+	}
+	// many users can share an IP address, so it gets more attempts
+	ipPolicy := usernamePolicy
+	ipPolicy.FreeAttempts = intFromEnv("LOGIN_IP_FREE_ATTEMPTS", 20)
+	ipPolicy.LockoutAttempts = intFromEnv("LOGIN_IP_LOCKOUT_ATTEMPTS", 100)
+	loginThrottle := user.NewLoginThrottle(userRepository, usernamePolicy, ipPolicy)
+	var mailSender mail.Sender
+	switch os.Getenv("MAIL_SENDER") {
+	case "", "log":
+		mailSender = mail.NewLogSender()
+	case "file":
+		mailDir := os.Getenv("MAIL_DIR")
+		if mailDir == "" {
+			mailDir = "tmp/mail"
+		}
+		mailSender, err = mail.NewFileSender(mailDir)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Cannot create mail directory: %v", err))
+			os.Exit(1)
+		}
+	case "smtp":
+		mailSender = mail.NewSMTPSender(os.Getenv("SMTP_HOST"), intFromEnv("SMTP_PORT", 587),
+			os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"))
+	default:
+		slog.Error(fmt.Sprintf("Unknown mail sender: %s", os.Getenv("MAIL_SENDER")))
+		os.Exit(1)
+	}
+	userMailer := user.NewMailer(mailSender, os.Getenv("APP_URL"),
+		durationFromEnv("EMAIL_VERIFICATION_TTL", 48*time.Hour), durationFromEnv("PASSWORD_RESET_TTL", time.Hour))
+	// TOTP secrets are sealed with the bank account keys unless they have their own
+	twoFactorKeys, twoFactorKeyID := os.Getenv("TWO_FACTOR_KEYS"), os.Getenv("TWO_FACTOR_KEY_ID")
+	if twoFactorKeys == "" {
+		twoFactorKeys, twoFactorKeyID = os.Getenv("BANK_ACCOUNT_KEYS"), os.Getenv("BANK_ACCOUNT_KEY_ID")
+	}
+	twoFactorKeyring, err := encryption.ParseKeyring(twoFactorKeys, twoFactorKeyID)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Cannot load two-factor encryption keys: %v", err))
+		os.Exit(1)
+	}
+	totpIssuer := os.Getenv("TOTP_ISSUER")
+	if totpIssuer == "" {
+		totpIssuer = "shopifyx"
+	}
+	twoFactor := user.NewTwoFactor(userRepository, twoFactorKeyring, totpIssuer, durationFromEnv("LOGIN_CHALLENGE_TTL", 5*time.Minute))
+	middleware.SetSecondFactorChecker(twoFactor)
+	// OIDC_PROVIDERS names the OpenID providers, each configured by its own
+	// OIDC_<NAME>_* variables
+	oidcClient := &http.Client{Timeout: 10 * time.Second}
+	var oidcProviders []*oidc.Provider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		config := oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			slog.Error(fmt.Sprintf("Missing %sISSUER or %sCLIENT_ID", prefix, prefix))
+			os.Exit(1)
+		}
+		if config.RedirectURL == "" {
+			config.RedirectURL = os.Getenv("APP_URL") + "/auth/oidc/" + name + "/callback"
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			config.Scopes = strings.Split(scopes, ",")
+		}
+		oidcProviders = append(oidcProviders, oidc.NewProvider(config, oidcClient))
+	}
+	// the mock provider signs everyone in as the same user, never in production
+	var mockOIDCServer *oidctest.Server
+	if os.Getenv("OIDC_MOCK") == "true" && env != "production" {
+		mockOIDCServer, err = oidctest.NewServer(os.Getenv("BASE_URL")+"/mock-oidc", "shopifyx", "")
+		if err != nil {
+			slog.Error(fmt.Sprintf("Cannot create mock OpenID provider: %v", err))
+			os.Exit(1)
+		}
+		oidcProviders = append(oidcProviders, oidc.NewProvider(
+			mockOIDCServer.Config("mock", os.Getenv("APP_URL")+"/auth/oidc/mock/callback"), oidcClient))
+	}
+	userService := user.NewService(userRepository, sessionCache, loginThrottle, passwords, userMailer, twoFactor, oidc.NewRegistry(oidcProviders...),
+		time.Duration(intFromEnv("JWT_TTL", 900))*time.Second, durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour))
 	userHandler := user.NewHandler(userService)
 
+	// initialize shop domain
+	shopRepository := shop.NewRepository(db)
+	shopService := shop.NewService(shopRepository, userRepository)
+	shopHandler := shop.NewHandler(shopService)
+
 	// initialize bank account domain
-	bankAccountRepository := bankaccount.NewRepository(db)
-	bankAccountService := bankaccount.NewService(bankAccountRepository)
+	bankAccountKeyring, err := encryption.ParseKeyring(os.Getenv("BANK_ACCOUNT_KEYS"), os.Getenv("BANK_ACCOUNT_KEY_ID"))
+	if err != nil {
+		slog.Error(fmt.Sprintf("Cannot load bank account encryption keys: %v", err))
+		os.Exit(1)
+	}
+	bankAccountRepository := bankaccount.NewRepository(db, bankAccountKeyring)
+	bankRegistry, err := bankaccount.NewDefaultRegistry()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Cannot load bank registry: %v", err))
+		os.Exit(1)
+	}
+	bankAccountService := bankaccount.NewService(bankAccountRepository, shopService, bankRegistry, bankaccount.NewLogTransferer(),
+		durationFromEnv("BANK_ACCOUNT_VERIFICATION_WINDOW", 72*time.Hour), intFromEnv("BANK_ACCOUNT_VERIFICATION_ATTEMPTS", 3),
+		intFromEnv("BANK_ACCOUNT_VERIFICATION_ROUNDS", 5))
 	bankAccountHandler := bankaccount.NewHandler(bankAccountService)
 
+	// initialize payment domain
+	var paymentProvider payment.Provider
+	var fakePaymentProvider *payment.FakeProvider
+	webhookSecret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		slog.Error("PAYMENT_WEBHOOK_SECRET must be set")
+		os.Exit(1)
+	}
+	switch os.Getenv("PAYMENT_PROVIDER") {
+	case "", "fake":
+		fakePaymentProvider = payment.NewFakeProvider(webhookSecret)
+		paymentProvider = fakePaymentProvider
+	default:
+		slog.Error(fmt.Sprintf("Unknown payment provider: %s", os.Getenv("PAYMENT_PROVIDER")))
+		os.Exit(1)
+	}
+	paymentRepository := payment.NewRepository(db)
+	paymentService := payment.NewService(paymentRepository, paymentProvider, webhookSecret, durationFromEnv("PAYMENT_WINDOW", 24*time.Hour))
+	paymentHandler := payment.NewHandler(paymentService)
+	expiryWorker := payment.NewExpiryWorker(paymentRepository, durationFromEnv("PAYMENT_EXPIRY_INTERVAL", time.Minute))
+
+	// initialize address domain
+	addressRepository := address.NewRepository(db)
+	addressService := address.NewService(addressRepository)
+	addressHandler := address.NewHandler(addressService)
+
+	// initialize shipping domain
+	shippingCalculator := shipping.NewFlatRateCalculator(intFromEnv("SHIPPING_FLAT_RATE", 10000))
+	shippingRepository := shipping.NewRepository(db)
+	shippingService := shipping.NewService(shippingRepository)
+	shippingHandler := shipping.NewHandler(shippingService)
+
 	// initialize product domain
 	productRepository := product.NewRepository(db)
-	productService := product.NewService(productRepository, userRepository, bankAccountRepository)
+	productService := product.NewService(productRepository, userRepository, bankAccountRepository, addressRepository, paymentService, shippingCalculator, shopService)
 	productHandler := product.NewHandler(productService)
 
+	// initialize return domain
+	returnRepository := returns.NewRepository(db)
+	returnService := returns.NewService(returnRepository, paymentService)
+	returnHandler := returns.NewHandler(returnService)
+
+	// initialize export domain
+	exportService := export.NewService(userRepository, addressRepository, productRepository, bankAccountRepository)
+	exportHandler := export.NewHandler(exportService)
+
+	// initialize audit domain
+	auditRepository := audit.NewRepository(db)
+	auditService := audit.NewService(auditRepository)
+	auditHandler := audit.NewHandler(auditService)
+
 	// initialize image domain
 	sess, err := session.NewSession(&aws.Config{
 		Region:      aws.String("ap-southeast-1"),
@@ -76,9 +294,24 @@ func main() {
 	imageService := image.NewService(sess)
 	imageHandler := image.NewHandler(imageService)
 
+	sellerOnly := middleware.RequireRole(role.Seller)
+	buyerOnly := middleware.RequireRole(role.Buyer)
+	adminOnly := middleware.RequireRole(role.Admin)
+	freshSecondFactor := middleware.RequireFreshSecondFactor(durationFromEnv("SECOND_FACTOR_MAX_AGE", 10*time.Minute))
+	// every admin request is recorded in the audit trail
+	adminAction := func(action, targetVar string, next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+		return middleware.PanicRecoverer(middleware.Authorized(adminOnly(audit.Recorded(auditService, action, targetVar)(next))))
+	}
+
 	r := mux.NewRouter()
 	v1 := r.PathPrefix("/v1").Subrouter()
 
+	r.HandleFunc("/.well-known/jwks.json", middleware.PanicRecoverer(jwt.JWKSHandler)).Methods(http.MethodGet)
+
+	if mockOIDCServer != nil {
+		r.PathPrefix("/mock-oidc").Handler(mockOIDCServer)
+	}
+
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "text")
 		io.WriteString(w, "Service ready")
@@ -88,28 +321,116 @@ func main() {
 	ur := v1.PathPrefix("/user").Subrouter()
 	ur.HandleFunc("/register", middleware.PanicRecoverer(userHandler.CreateUser)).Methods(http.MethodPost)
 	ur.HandleFunc("/login", middleware.PanicRecoverer(userHandler.Login)).Methods(http.MethodPost)
+	ur.HandleFunc("/login/2fa", middleware.PanicRecoverer(userHandler.LoginTwoFactor)).Methods(http.MethodPost)
+	ur.HandleFunc("/oidc", middleware.PanicRecoverer(userHandler.ListProviders)).Methods(http.MethodGet)
+	ur.HandleFunc("/oidc/{provider}/login", middleware.PanicRecoverer(userHandler.BeginOIDCLogin)).Methods(http.MethodGet)
+	ur.HandleFunc("/oidc/{provider}/callback", middleware.PanicRecoverer(userHandler.FinishOIDCLogin)).Methods(http.MethodPost)
+	ur.HandleFunc("/token/refresh", middleware.PanicRecoverer(userHandler.RefreshToken)).Methods(http.MethodPost)
+	ur.HandleFunc("/logout", middleware.PanicRecoverer(userHandler.Logout)).Methods(http.MethodPost)
+	ur.HandleFunc("/sessions", middleware.PanicRecoverer(middleware.Authorized(userHandler.ListSessions))).Methods(http.MethodGet)
+	ur.HandleFunc("/sessions", middleware.PanicRecoverer(middleware.Authorized(userHandler.RevokeAllSessions))).Methods(http.MethodDelete)
+	ur.HandleFunc("/sessions/{sessionId}", middleware.PanicRecoverer(middleware.Authorized(userHandler.RevokeSession))).Methods(http.MethodDelete)
+	ur.HandleFunc("/me", middleware.PanicRecoverer(middleware.Authorized(userHandler.GetProfile))).Methods(http.MethodGet)
+	ur.HandleFunc("/me", middleware.PanicRecoverer(middleware.Authorized(userHandler.UpdateProfile))).Methods(http.MethodPatch)
+	ur.HandleFunc("/me", middleware.PanicRecoverer(middleware.Authorized(freshSecondFactor(userHandler.DeleteAccount)))).Methods(http.MethodDelete)
+	ur.HandleFunc("/me/export", middleware.PanicRecoverer(middleware.Authorized(exportHandler.Export))).Methods(http.MethodGet)
+	ur.HandleFunc("/me/password", middleware.PanicRecoverer(middleware.Authorized(userHandler.ChangePassword))).Methods(http.MethodPost)
+	ur.HandleFunc("/me/email", middleware.PanicRecoverer(middleware.Authorized(userHandler.ChangeEmail))).Methods(http.MethodPut)
+	ur.HandleFunc("/me/email/verification", middleware.PanicRecoverer(middleware.Authorized(userHandler.SendEmailVerification))).Methods(http.MethodPost)
+	ur.HandleFunc("/me/2fa/totp", middleware.PanicRecoverer(middleware.Authorized(userHandler.BeginTOTP))).Methods(http.MethodPost)
+	ur.HandleFunc("/me/2fa/totp", middleware.PanicRecoverer(middleware.Authorized(userHandler.DisableTOTP))).Methods(http.MethodDelete)
+	ur.HandleFunc("/me/2fa/totp/confirm", middleware.PanicRecoverer(middleware.Authorized(userHandler.ConfirmTOTP))).Methods(http.MethodPost)
+	ur.HandleFunc("/me/2fa/recovery-codes", middleware.PanicRecoverer(middleware.Authorized(userHandler.RegenerateRecoveryCodes))).Methods(http.MethodPost)
+	ur.HandleFunc("/me/2fa/verify", middleware.PanicRecoverer(middleware.Authorized(userHandler.VerifySecondFactor))).Methods(http.MethodPost)
+	ur.HandleFunc("/email/verify", middleware.PanicRecoverer(userHandler.VerifyEmail)).Methods(http.MethodPost)
+	ur.HandleFunc("/password/forgot", middleware.PanicRecoverer(userHandler.ForgotPassword)).Methods(http.MethodPost)
+	ur.HandleFunc("/password/reset", middleware.PanicRecoverer(userHandler.ResetPassword)).Methods(http.MethodPost)
+	ur.HandleFunc("/api-tokens", middleware.PanicRecoverer(middleware.Authorized(userHandler.CreateAPIToken))).Methods(http.MethodPost)
+	ur.HandleFunc("/api-tokens", middleware.PanicRecoverer(middleware.Authorized(userHandler.ListAPITokens))).Methods(http.MethodGet)
+	ur.HandleFunc("/api-tokens/{tokenId}", middleware.PanicRecoverer(middleware.Authorized(userHandler.RevokeAPIToken))).Methods(http.MethodDelete)
+	ur.HandleFunc("/addresses", middleware.PanicRecoverer(middleware.Authorized(addressHandler.CreateAddress))).Methods(http.MethodPost)
+	ur.HandleFunc("/addresses", middleware.PanicRecoverer(middleware.Authorized(addressHandler.ListAddress))).Methods(http.MethodGet)
+	ur.HandleFunc("/addresses/{addressId}", middleware.PanicRecoverer(middleware.Authorized(addressHandler.PartialUpdateAddress))).Methods(http.MethodPatch)
+	ur.HandleFunc("/addresses/{addressId}", middleware.PanicRecoverer(middleware.Authorized(addressHandler.DeleteAddress))).Methods(http.MethodDelete)
 
 	// product routes
 	pr := v1.PathPrefix("/product").Subrouter()
-	pr.HandleFunc("", middleware.PanicRecoverer(middleware.Authorized(productHandler.CreateProduct))).Methods(http.MethodPost)
-	pr.HandleFunc("", middleware.PanicRecoverer(middleware.Authenticate(productHandler.GetProductList))).Methods(http.MethodGet)
-	pr.HandleFunc("/{productId}", middleware.PanicRecoverer(middleware.Authorized(productHandler.PatchProduct))).Methods(http.MethodPatch)
+	pr.HandleFunc("", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(productHandler.CreateProduct), scope.ProductsWrite))).Methods(http.MethodPost)
+	pr.HandleFunc("", middleware.PanicRecoverer(middleware.Authenticate(productHandler.GetProductList, scope.ProductsRead))).Methods(http.MethodGet)
+	pr.HandleFunc("/{productId}", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(productHandler.PatchProduct), scope.ProductsWrite))).Methods(http.MethodPatch)
 	pr.HandleFunc("/{productId}", middleware.PanicRecoverer(productHandler.GetProduct)).Methods(http.MethodGet)
-	pr.HandleFunc("/{productId}", middleware.PanicRecoverer(middleware.Authorized(productHandler.DeleteProduct))).Methods(http.MethodDelete)
-	pr.HandleFunc("/{productId}/buy", middleware.PanicRecoverer(middleware.Authorized(productHandler.PurchaseProduct))).Methods(http.MethodPost)
-	pr.HandleFunc("/{productId}/stock", middleware.PanicRecoverer(middleware.Authorized(productHandler.UpdateStockProduct))).Methods(http.MethodPost)
+	pr.HandleFunc("/{productId}", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(productHandler.DeleteProduct), scope.ProductsWrite))).Methods(http.MethodDelete)
+	pr.HandleFunc("/{productId}/buy", middleware.PanicRecoverer(middleware.Authorized(buyerOnly(productHandler.PurchaseProduct)))).Methods(http.MethodPost)
+	pr.HandleFunc("/{productId}/stock", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(productHandler.UpdateStockProduct), scope.StockWrite))).Methods(http.MethodPost)
 
 	// bank routes
 	br := v1.PathPrefix("/bank").Subrouter()
-	br.HandleFunc("/account", middleware.PanicRecoverer(middleware.Authorized(bankAccountHandler.CreateBankAccount))).Methods(http.MethodPost)
+	br.HandleFunc("/account", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(freshSecondFactor(bankAccountHandler.CreateBankAccount))))).Methods(http.MethodPost)
 	br.HandleFunc("/account", middleware.PanicRecoverer(middleware.Authorized(bankAccountHandler.ListBankAccount))).Methods(http.MethodGet)
-	br.HandleFunc("/account", middleware.PanicRecoverer(middleware.Authorized(bankAccountHandler.PartialUpdateBankAccount))).Methods(http.MethodPatch)
-	br.HandleFunc("/account/{uuid}", middleware.PanicRecoverer(middleware.Authorized(bankAccountHandler.PartialUpdateBankAccount))).Methods(http.MethodPatch)
-	br.HandleFunc("/account/{uuid}", middleware.PanicRecoverer(middleware.Authorized(bankAccountHandler.DeleteBankAccount))).Methods(http.MethodDelete)
+	br.HandleFunc("/account", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(freshSecondFactor(bankAccountHandler.PartialUpdateBankAccount))))).Methods(http.MethodPatch)
+	br.HandleFunc("/account/{uuid}", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(freshSecondFactor(bankAccountHandler.PartialUpdateBankAccount))))).Methods(http.MethodPatch)
+	br.HandleFunc("/account/{uuid}", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(freshSecondFactor(bankAccountHandler.DeleteBankAccount))))).Methods(http.MethodDelete)
+	br.HandleFunc("/account/{uuid}/primary", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(freshSecondFactor(bankAccountHandler.SetPrimaryBankAccount))))).Methods(http.MethodPost)
+	br.HandleFunc("/account/{uuid}/verification", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(freshSecondFactor(bankAccountHandler.StartVerification))))).Methods(http.MethodPost)
+	br.HandleFunc("/account/{uuid}/verification/confirm", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(freshSecondFactor(bankAccountHandler.ConfirmVerification))))).Methods(http.MethodPost)
+	v1.HandleFunc("/banks", middleware.PanicRecoverer(bankAccountHandler.ListBanks)).Methods(http.MethodGet)
+
+	// shop routes
+	shr := v1.PathPrefix("/shops").Subrouter()
+	shr.HandleFunc("", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(shopHandler.CreateShop)))).Methods(http.MethodPost)
+	shr.HandleFunc("", middleware.PanicRecoverer(middleware.Authorized(shopHandler.ListShops))).Methods(http.MethodGet)
+	shr.HandleFunc("/{shopId}/members", middleware.PanicRecoverer(middleware.Authorized(shopHandler.ListMembers))).Methods(http.MethodGet)
+	shr.HandleFunc("/{shopId}/members", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(shopHandler.InviteMember)))).Methods(http.MethodPost)
+	shr.HandleFunc("/{shopId}/members/{username}", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(shopHandler.UpdateMember)))).Methods(http.MethodPatch)
+	shr.HandleFunc("/{shopId}/members/{username}", middleware.PanicRecoverer(middleware.Authorized(shopHandler.RemoveMember))).Methods(http.MethodDelete)
+	shr.HandleFunc("/{shopId}/accept", middleware.PanicRecoverer(middleware.Authorized(shopHandler.AcceptInvitation))).Methods(http.MethodPost)
+	shr.HandleFunc("/{shopId}/sales", middleware.PanicRecoverer(middleware.Authorized(productHandler.ListShopSales, scope.OrdersRead))).Methods(http.MethodGet)
+
+	// seller routes
+	sr := v1.PathPrefix("/sellers").Subrouter()
+	sr.HandleFunc("/{username}", middleware.PanicRecoverer(productHandler.GetStorefront)).Methods(http.MethodGet)
 
 	// image routes
 	ir := v1.PathPrefix("/image").Subrouter()
-	ir.HandleFunc("", middleware.PanicRecoverer(middleware.Authorized(imageHandler.UploadToS3))).Methods(http.MethodPost)
+	ir.HandleFunc("", middleware.PanicRecoverer(middleware.Authorized(imageHandler.UploadToS3, scope.ProductsWrite))).Methods(http.MethodPost)
+
+	// payment routes
+	pyr := v1.PathPrefix("/payments").Subrouter()
+	pyr.HandleFunc("/webhook", middleware.PanicRecoverer(paymentHandler.Webhook)).Methods(http.MethodPost)
+	if fakePaymentProvider != nil && os.Getenv("PAYMENT_FAKE_SETTLE") == "true" && env != "production" {
+		fakePaymentHandler := payment.NewFakeHandler(paymentService, fakePaymentProvider)
+		pyr.HandleFunc("/fake/{chargeId}/settle", middleware.PanicRecoverer(middleware.Authorized(adminOnly(fakePaymentHandler.SettleCharge)))).Methods(http.MethodPost)
+	}
+
+	// purchase routes
+	pur := v1.PathPrefix("/purchases").Subrouter()
+	pur.HandleFunc("/{transactionId}/shipment", middleware.PanicRecoverer(middleware.Authorized(shippingHandler.GetShipment, scope.OrdersRead))).Methods(http.MethodGet)
+	pur.HandleFunc("/{transactionId}/ship", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(shippingHandler.ShipPurchase), scope.OrdersWrite))).Methods(http.MethodPost)
+	pur.HandleFunc("/{transactionId}/receive", middleware.PanicRecoverer(middleware.Authorized(shippingHandler.ConfirmReceipt))).Methods(http.MethodPost)
+
+	// return routes
+	rr := v1.PathPrefix("/returns").Subrouter()
+	rr.HandleFunc("", middleware.PanicRecoverer(middleware.Authorized(buyerOnly(returnHandler.CreateReturn)))).Methods(http.MethodPost)
+	rr.HandleFunc("", middleware.PanicRecoverer(middleware.Authorized(returnHandler.ListReturn, scope.OrdersRead))).Methods(http.MethodGet)
+	rr.HandleFunc("/{returnId}", middleware.PanicRecoverer(middleware.Authorized(returnHandler.GetReturn, scope.OrdersRead))).Methods(http.MethodGet)
+	rr.HandleFunc("/{returnId}/accept", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(returnHandler.AcceptReturn), scope.OrdersWrite))).Methods(http.MethodPost)
+	rr.HandleFunc("/{returnId}/decline", middleware.PanicRecoverer(middleware.Authorized(sellerOnly(returnHandler.DeclineReturn), scope.OrdersWrite))).Methods(http.MethodPost)
+	rr.HandleFunc("/{returnId}/dispute", middleware.PanicRecoverer(middleware.Authorized(returnHandler.DisputeReturn))).Methods(http.MethodPost)
+
+	// admin routes
+	ar := v1.PathPrefix("/admin").Subrouter()
+	ar.HandleFunc("/payments/statements", adminAction("payment.import_statement", "", paymentHandler.ImportStatement)).Methods(http.MethodPost)
+	ar.HandleFunc("/returns", adminAction("return.list_disputed", "", returnHandler.ListDisputedReturn)).Methods(http.MethodGet)
+	ar.HandleFunc("/returns/{returnId}/resolve", adminAction("return.resolve", "returnId", returnHandler.ResolveReturn)).Methods(http.MethodPost)
+	ar.HandleFunc("/users", adminAction("user.search", "", userHandler.SearchUsers)).Methods(http.MethodGet)
+	ar.HandleFunc("/users/{userId}/suspend", adminAction("user.suspend", "userId", userHandler.SuspendUser)).Methods(http.MethodPost)
+	ar.HandleFunc("/users/{userId}/unsuspend", adminAction("user.unsuspend", "userId", userHandler.UnsuspendUser)).Methods(http.MethodPost)
+	ar.HandleFunc("/users/{userId}/transactions", adminAction("user.view_transactions", "userId", productHandler.ListUserTransactions)).Methods(http.MethodGet)
+	ar.HandleFunc("/users/{userId}/bank-accounts", adminAction("user.view_bank_accounts", "userId", bankAccountHandler.AdminListBankAccount)).Methods(http.MethodGet)
+	ar.HandleFunc("/products/{productId}/unlist", adminAction("product.unlist", "productId", productHandler.UnlistProduct)).Methods(http.MethodPost)
+	ar.HandleFunc("/products/{productId}/relist", adminAction("product.relist", "productId", productHandler.RelistProduct)).Methods(http.MethodPost)
+	ar.HandleFunc("/products/{productId}", adminAction("product.remove", "productId", productHandler.RemoveProduct)).Methods(http.MethodDelete)
+	ar.HandleFunc("/audit", adminAction("audit.list", "", auditHandler.ListEntries)).Methods(http.MethodGet)
 
 	httpServer := &http.Server{
 		Addr:     ":8000",
@@ -125,18 +446,52 @@ func main() {
 		slog.Info("Stopped serving new connections.")
 	}()
 
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	go expiryWorker.Run(workerCtx)
+
 	// Listen for the termination signal
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
 	// Block until termination signal received
 	<-stop
+	stopWorkers()
 	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownRelease()
 
 	slog.Info(fmt.Sprintf("Shutting down HTTP server listening on %s", httpServer.Addr))
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		slog.Error("HTTP server shutdown error: %v", err)
+		slog.Error(fmt.Sprintf("HTTP server shutdown error: %v", err))
 	}
 	slog.Info("Shutdown complete.")
 }
+
+// durationFromEnv parses the environment variable as a time.Duration such as "24h",
+// falling back to def when it is unset.
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Invalid %s: %v", name, err))
+		os.Exit(1)
+	}
+	return d
+}
+
+// intFromEnv parses the environment variable as an integer, falling back to def
+// when it is unset.
+func intFromEnv(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Invalid %s: %v", name, err))
+		os.Exit(1)
+	}
+	return i
+}
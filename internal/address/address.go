@@ -0,0 +1,43 @@
+package address
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Address struct {
+	ID            uint64
+	UUID          uuid.UUID
+	UserID        uint64
+	Label         string
+	RecipientName string
+	Phone         string
+	Street        string
+	City          string
+	Province      string
+	PostalCode    string
+	CreatedAt     time.Time
+}
+
+// Snapshot is the copy of an address stored on a purchase, so later edits to the
+// address book do not change where an order was shipped.
+type Snapshot struct {
+	RecipientName string `json:"recipientName"`
+	Phone         string `json:"phone"`
+	Street        string `json:"street"`
+	City          string `json:"city"`
+	Province      string `json:"province"`
+	PostalCode    string `json:"postalCode"`
+}
+
+func (a *Address) Snapshot() Snapshot {
+	return Snapshot{
+		RecipientName: a.RecipientName,
+		Phone:         a.Phone,
+		Street:        a.Street,
+		City:          a.City,
+		Province:      a.Province,
+		PostalCode:    a.PostalCode,
+	}
+}
@@ -0,0 +1,9 @@
+package address
+
+import "errors"
+
+var (
+	ErrValidationFailed = errors.New("validation failed")
+	ErrNotFound         = errors.New("address not found")
+	ErrForbidden        = errors.New("you are forbidden to make changes to this address")
+)
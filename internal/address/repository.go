@@ -0,0 +1,128 @@
+package address
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, address *Address) error
+	GetByUUID(ctx context.Context, uid uuid.UUID) (*Address, error)
+	List(ctx context.Context, userID uint64) ([]*Address, error)
+	Update(ctx context.Context, address *Address) error
+	Delete(ctx context.Context, uid uuid.UUID) error
+}
+
+type dbRepository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) Repository {
+	return &dbRepository{db: db}
+}
+
+// Create implements Repository.
+func (d *dbRepository) Create(ctx context.Context, address *Address) error {
+	createQuery := `
+		INSERT INTO addresses (
+			user_id, label, recipient_name, phone, street, city, province, postal_code
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8
+		)
+		RETURNING id, uid, created_at;
+	`
+	row := d.db.DB().QueryRowContext(ctx, createQuery, address.UserID, address.Label, address.RecipientName, address.Phone,
+		address.Street, address.City, address.Province, address.PostalCode)
+	return row.Scan(&address.ID, &address.UUID, &address.CreatedAt)
+}
+
+// GetByUUID implements Repository.
+func (d *dbRepository) GetByUUID(ctx context.Context, uid uuid.UUID) (*Address, error) {
+	getQuery := `
+		SELECT id, uid, user_id, label, recipient_name, phone, street, city, province, postal_code, created_at
+		FROM addresses
+		WHERE uid = $1;
+	`
+	row := d.db.DB().QueryRowContext(ctx, getQuery, uid)
+	a := &Address{}
+	err := row.Scan(&a.ID, &a.UUID, &a.UserID, &a.Label, &a.RecipientName, &a.Phone, &a.Street, &a.City, &a.Province, &a.PostalCode, &a.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// List implements Repository.
+func (d *dbRepository) List(ctx context.Context, userID uint64) ([]*Address, error) {
+	listQuery := `
+		SELECT id, uid, user_id, label, recipient_name, phone, street, city, province, postal_code, created_at
+		FROM addresses
+		WHERE user_id = $1
+		ORDER BY created_at;
+	`
+	rows, err := d.db.DB().QueryContext(ctx, listQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var addresses []*Address
+	for rows.Next() {
+		a := &Address{}
+		if err := rows.Scan(&a.ID, &a.UUID, &a.UserID, &a.Label, &a.RecipientName, &a.Phone, &a.Street, &a.City, &a.Province, &a.PostalCode, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, a)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// Update implements Repository.
+func (d *dbRepository) Update(ctx context.Context, address *Address) error {
+	updateQuery := `
+		UPDATE addresses
+		SET label = $1,
+		recipient_name = $2,
+		phone = $3,
+		street = $4,
+		city = $5,
+		province = $6,
+		postal_code = $7
+		WHERE uid = $8;
+	`
+	_, err := d.db.DB().ExecContext(ctx, updateQuery, address.Label, address.RecipientName, address.Phone,
+		address.Street, address.City, address.Province, address.PostalCode, address.UUID)
+	return err
+}
+
+// Delete implements Repository.
+func (d *dbRepository) Delete(ctx context.Context, uid uuid.UUID) error {
+	deleteQuery := `
+		DELETE FROM addresses
+		WHERE uid = $1;
+	`
+	row, err := d.db.DB().ExecContext(ctx, deleteQuery, uid)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := row.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package address
+
+import (
+	"regexp"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+
+type CreateUpdateAddressPayload struct {
+	Label         string `json:"label"`
+	RecipientName string `json:"recipientName"`
+	Phone         string `json:"phone"`
+	Street        string `json:"street"`
+	City          string `json:"city"`
+	Province      string `json:"province"`
+	PostalCode    string `json:"postalCode"`
+}
+
+func (p CreateUpdateAddressPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Label, validation.Length(1, 30)),
+		validation.Field(&p.RecipientName, validation.Required, validation.Length(1, 50)),
+		validation.Field(&p.Phone, validation.Required, validation.Match(phonePattern)),
+		validation.Field(&p.Street, validation.Required, validation.Length(5, 200)),
+		validation.Field(&p.City, validation.Required, validation.Length(2, 50)),
+		validation.Field(&p.Province, validation.Required, validation.Length(2, 50)),
+		validation.Field(&p.PostalCode, validation.Required, validation.Length(5, 10), is.Digit),
+	)
+}
+
+// ValidatePartial validates only the fields that are set, for updates.
+func (p CreateUpdateAddressPayload) ValidatePartial() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Label, validation.Length(1, 30)),
+		validation.Field(&p.RecipientName, validation.Length(1, 50)),
+		validation.Field(&p.Phone, validation.Match(phonePattern)),
+		validation.Field(&p.Street, validation.Length(5, 200)),
+		validation.Field(&p.City, validation.Length(2, 50)),
+		validation.Field(&p.Province, validation.Length(2, 50)),
+		validation.Field(&p.PostalCode, validation.Length(5, 10), is.Digit),
+	)
+}
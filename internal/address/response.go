@@ -0,0 +1,25 @@
+package address
+
+type AddressResponse struct {
+	AddressID     string `json:"addressId"`
+	Label         string `json:"label"`
+	RecipientName string `json:"recipientName"`
+	Phone         string `json:"phone"`
+	Street        string `json:"street"`
+	City          string `json:"city"`
+	Province      string `json:"province"`
+	PostalCode    string `json:"postalCode"`
+}
+
+func CreateAddressResponse(a *Address) AddressResponse {
+	return AddressResponse{
+		AddressID:     a.UUID.String(),
+		Label:         a.Label,
+		RecipientName: a.RecipientName,
+		Phone:         a.Phone,
+		Street:        a.Street,
+		City:          a.City,
+		Province:      a.Province,
+		PostalCode:    a.PostalCode,
+	}
+}
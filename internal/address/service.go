@@ -0,0 +1,120 @@
+package address
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Create(ctx context.Context, req CreateUpdateAddressPayload, userID uint64) (*AddressResponse, error)
+	List(ctx context.Context, userID uint64) ([]*AddressResponse, error)
+	PartialUpdate(ctx context.Context, req CreateUpdateAddressPayload, uid uuid.UUID, userID uint64) (*AddressResponse, error)
+	Delete(ctx context.Context, uid uuid.UUID, userID uint64) error
+}
+
+type addressService struct {
+	repository Repository
+}
+
+func NewService(repository Repository) Service {
+	return &addressService{repository: repository}
+}
+
+// Create implements Service.
+func (s *addressService) Create(ctx context.Context, req CreateUpdateAddressPayload, userID uint64) (*AddressResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	address := &Address{
+		UserID:        userID,
+		Label:         req.Label,
+		RecipientName: req.RecipientName,
+		Phone:         req.Phone,
+		Street:        req.Street,
+		City:          req.City,
+		Province:      req.Province,
+		PostalCode:    req.PostalCode,
+	}
+	err = s.repository.Create(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateAddressResponse(address)
+	return &resp, nil
+}
+
+// List implements Service.
+func (s *addressService) List(ctx context.Context, userID uint64) ([]*AddressResponse, error) {
+	addresses, err := s.repository.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*AddressResponse, len(addresses))
+	for i, address := range addresses {
+		r := CreateAddressResponse(address)
+		resp[i] = &r
+	}
+	return resp, nil
+}
+
+// PartialUpdate implements Service.
+func (s *addressService) PartialUpdate(ctx context.Context, req CreateUpdateAddressPayload, uid uuid.UUID, userID uint64) (*AddressResponse, error) {
+	err := req.ValidatePartial()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	address, err := s.repository.GetByUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if address.UserID != userID {
+		return nil, ErrForbidden
+	}
+	s.applyPartialUpdate(req, address)
+	err = s.repository.Update(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateAddressResponse(address)
+	return &resp, nil
+}
+
+// Delete implements Service. Purchases keep their own snapshot, so deleting an
+// address does not affect orders already shipped to it.
+func (s *addressService) Delete(ctx context.Context, uid uuid.UUID, userID uint64) error {
+	address, err := s.repository.GetByUUID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if address.UserID != userID {
+		return ErrForbidden
+	}
+	return s.repository.Delete(ctx, uid)
+}
+
+func (s *addressService) applyPartialUpdate(req CreateUpdateAddressPayload, address *Address) {
+	if req.Label != "" {
+		address.Label = req.Label
+	}
+	if req.RecipientName != "" {
+		address.RecipientName = req.RecipientName
+	}
+	if req.Phone != "" {
+		address.Phone = req.Phone
+	}
+	if req.Street != "" {
+		address.Street = req.Street
+	}
+	if req.City != "" {
+		address.City = req.City
+	}
+	if req.Province != "" {
+		address.Province = req.Province
+	}
+	if req.PostalCode != "" {
+		address.PostalCode = req.PostalCode
+	}
+}
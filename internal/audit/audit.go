@@ -0,0 +1,16 @@
+package audit
+
+import "time"
+
+// Entry records one admin request. Target names what the action was applied to,
+// e.g. "user:42", and StatusCode is what the request was answered with, so refused
+// attempts are recorded as well.
+type Entry struct {
+	ID         uint64
+	ActorID    uint64
+	Action     string
+	Target     string
+	Reason     string
+	StatusCode int
+	CreatedAt  time.Time
+}
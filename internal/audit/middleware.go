@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/middleware"
+	"github.com/gorilla/mux"
+)
+
+type contextEntryKey struct{}
+
+// Recorded writes an entry for every request to next once it is answered. The entry
+// is named action and targets the route variable targetVar, e.g.
+// Recorded(service, "user.suspend", "userId") records "user:<userId>". It runs inside
+// Authorized so the actor is known.
+func Recorded(service Service, action, targetVar string) func(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			entry := &Entry{Action: action}
+			if principal, err := middleware.GetPrincipal(r); err == nil {
+				entry.ActorID = principal.UserID
+			}
+			if targetVar != "" {
+				entry.Target = fmt.Sprintf("%s:%s", strings.TrimSuffix(targetVar, "Id"), mux.Vars(r)[targetVar])
+			}
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next(sw, r.WithContext(context.WithValue(r.Context(), contextEntryKey{}, entry)))
+
+			entry.StatusCode = sw.status
+			// the request is already answered, a failure to record it can only be logged
+			if err := service.Record(context.WithoutCancel(r.Context()), entry); err != nil {
+				slog.Error(fmt.Sprintf("audit: cannot record %s by %d: %v", entry.Action, entry.ActorID, err))
+			}
+		}
+	}
+}
+
+// SetReason adds the reason an admin gave for an action to its audit entry. It does
+// nothing outside of Recorded.
+func SetReason(ctx context.Context, reason string) {
+	if entry, ok := ctx.Value(contextEntryKey{}).(*Entry); ok {
+		entry.Reason = reason
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
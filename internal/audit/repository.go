@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+)
+
+type Repository interface {
+	Create(ctx context.Context, entry *Entry) error
+	List(ctx context.Context, filter ListEntriesPayload) ([]*Entry, error)
+}
+
+type dbRepository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) Repository {
+	return &dbRepository{db: db}
+}
+
+// Create implements Repository.
+func (d *dbRepository) Create(ctx context.Context, entry *Entry) error {
+	createQuery := `
+		INSERT INTO audit_entries (
+			actor_id, action, target, reason, status_code
+		) VALUES (
+			$1, $2, $3, $4, $5
+		)
+		RETURNING id, created_at;
+	`
+	row := d.db.DB().QueryRowContext(ctx, createQuery, entry.ActorID, entry.Action, entry.Target, entry.Reason, entry.StatusCode)
+	return row.Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// List implements Repository.
+func (d *dbRepository) List(ctx context.Context, filter ListEntriesPayload) ([]*Entry, error) {
+	listQuery := `
+		SELECT id, actor_id, action, target, reason, status_code, created_at
+		FROM audit_entries
+		WHERE ($1 = 0 OR actor_id = $1)
+		AND ($2 = '' OR action = $2)
+		AND ($3 = '' OR target = $3)
+		ORDER BY id DESC
+		LIMIT $4 OFFSET $5;
+	`
+	rows, err := d.db.DB().QueryContext(ctx, listQuery, filter.ActorID, filter.Action, filter.Target, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []*Entry
+	for rows.Next() {
+		e := &Entry{}
+		err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.Target, &e.Reason, &e.StatusCode, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
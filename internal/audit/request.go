@@ -0,0 +1,18 @@
+package audit
+
+import validation "github.com/go-ozzo/ozzo-validation/v4"
+
+type ListEntriesPayload struct {
+	ActorID uint64 `schema:"actorId"`
+	Action  string `schema:"action"`
+	Target  string `schema:"target"`
+	Limit   int    `schema:"limit"`
+	Offset  int    `schema:"offset"`
+}
+
+func (p ListEntriesPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Limit, validation.Min(0), validation.Max(100)),
+		validation.Field(&p.Offset, validation.Min(0)),
+	)
+}
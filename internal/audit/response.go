@@ -0,0 +1,23 @@
+package audit
+
+import "time"
+
+type EntryResponse struct {
+	ActorID    uint64    `json:"actorId"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target"`
+	Reason     string    `json:"reason,omitempty"`
+	StatusCode int       `json:"statusCode"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func CreateEntryResponse(entry *Entry) *EntryResponse {
+	return &EntryResponse{
+		ActorID:    entry.ActorID,
+		Action:     entry.Action,
+		Target:     entry.Target,
+		Reason:     entry.Reason,
+		StatusCode: entry.StatusCode,
+		CreatedAt:  entry.CreatedAt,
+	}
+}
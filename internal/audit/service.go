@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+)
+
+type Service interface {
+	Record(ctx context.Context, entry *Entry) error
+	List(ctx context.Context, req ListEntriesPayload) ([]*EntryResponse, error)
+}
+
+type auditService struct {
+	repository Repository
+}
+
+func NewService(repository Repository) Service {
+	return &auditService{repository: repository}
+}
+
+// Record implements Service.
+func (s *auditService) Record(ctx context.Context, entry *Entry) error {
+	return s.repository.Create(ctx, entry)
+}
+
+// List implements Service.
+func (s *auditService) List(ctx context.Context, req ListEntriesPayload) ([]*EntryResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+	entries, err := s.repository.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*EntryResponse, len(entries))
+	for i, entry := range entries {
+		resp[i] = CreateEntryResponse(entry)
+	}
+	return resp, nil
+}
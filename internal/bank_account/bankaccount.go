@@ -1,6 +1,8 @@
 package bankaccount
 
 import (
+	"database/sql"
+
 	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
 	"github.com/google/uuid"
 )
@@ -8,8 +10,19 @@ import (
 type BankAccount struct {
 	ID                uint64
 	UUID              uuid.UUID
+	BankCode          string
 	BankName          string
 	BankAccountName   string
 	BankAccountNumber string
-	User              user.User
+	IsPrimary         bool
+	ArchivedAt        sql.NullTime
+	Verification      Verification
+	// ShopID is the shop payments go to, User its owner.
+	ShopID uint64
+	User   user.User
+}
+
+// IsArchived reports whether the account was deleted while purchases still refer to it.
+func (b *BankAccount) IsArchived() bool {
+	return b.ArchivedAt.Valid
 }
@@ -0,0 +1,55 @@
+package bankaccount
+
+// checksums are the account number check digit algorithms a bank in the
+// registry can refer to by name.
+var checksums = map[string]func(number string) bool{
+	"luhn":  luhn,
+	"mod11": mod11,
+}
+
+// luhn validates the last digit with the Luhn (mod 10) algorithm.
+func luhn(number string) bool {
+	var sum int
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// mod11 validates the last digit as the weighted mod 11 check digit, with weights
+// 2 to 7 repeating from the right.
+func mod11(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	var sum int
+	weight := 2
+	for i := len(number) - 2; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		sum += d * weight
+		weight++
+		if weight > 7 {
+			weight = 2
+		}
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		check = 0
+	}
+	return int(number[len(number)-1]-'0') == check
+}
@@ -3,7 +3,20 @@ package bankaccount
 import "errors"
 
 var (
-	ErrValidationFailed = errors.New("validation failed")
-	ErrNotFound         = errors.New("bank account not found")
-	ErrForbidden        = errors.New("you are forbidden to make changes to this bank account")
+	ErrValidationFailed     = errors.New("validation failed")
+	ErrNotFound             = errors.New("bank account not found")
+	ErrForbidden            = errors.New("you are forbidden to make changes to this bank account")
+	ErrShopNotFound         = errors.New("shop not found")
+	ErrUnknownBank          = errors.New("bank is not registered")
+	ErrInvalidAccountNumber = errors.New("invalid bank account number")
+	ErrPendingPayment       = errors.New("bank account still has pending payments")
+
+	ErrAlreadyVerified        = errors.New("bank account is already verified")
+	ErrVerificationNotPending = errors.New("bank account verification has not been started")
+	ErrVerificationExpired    = errors.New("bank account verification has expired, start a new one")
+	ErrVerificationMismatch   = errors.New("deposited amounts do not match")
+	ErrVerificationAttempts   = errors.New("too many attempts, start a new verification")
+	ErrVerificationRounds     = errors.New("too many verifications started for this bank account, contact support")
+	ErrVerificationChanged    = errors.New("bank account verification changed, try again")
+	ErrPrimaryNotVerified     = errors.New("only a verified bank account can be made primary")
 )
@@ -22,13 +22,18 @@ func NewHandler(service Service) *Handler {
 }
 
 func (h *Handler) CreateBankAccount(w http.ResponseWriter, r *http.Request) {
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		slog.Error(err.Error())
 		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
 		return
 	}
 
+	shopUID, ok := shopIDFromQuery(w, r)
+	if !ok {
+		return
+	}
+
 	var req CreateUpdateBankAccountPayload
 
 	err = request.DecodeJSON(w, r, &req)
@@ -39,7 +44,7 @@ func (h *Handler) CreateBankAccount(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	bankAccountResp, err := h.service.Create(r.Context(), req, userID)
+	bankAccountResp, err := h.service.Create(r.Context(), req, shopUID, userID)
 	if errors.Is(err, ErrValidationFailed) {
 		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
 			Message: "Bad request",
@@ -47,6 +52,20 @@ func (h *Handler) CreateBankAccount(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if errors.Is(err, ErrShopNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrForbidden) {
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
 			Message: "Internal server error",
@@ -61,14 +80,57 @@ func (h *Handler) CreateBankAccount(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListBankAccount(w http.ResponseWriter, r *http.Request) {
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		slog.Error(err.Error())
 		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
 		return
 	}
 
-	bankAccountResp, err := h.service.List(r.Context(), userID)
+	shopUID, ok := shopIDFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	bankAccountResp, err := h.service.List(r.Context(), shopUID, userID)
+	if errors.Is(err, ErrShopNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrForbidden) {
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    bankAccountResp,
+	})
+}
+
+func (h *Handler) AdminListBankAccount(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   "user not found",
+		})
+		return
+	}
+
+	bankAccountResp, err := h.service.ListForAdmin(r.Context(), userID)
 	if err != nil {
 		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
 			Message: "Internal server error",
@@ -83,7 +145,7 @@ func (h *Handler) ListBankAccount(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) PartialUpdateBankAccount(w http.ResponseWriter, r *http.Request) {
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		slog.Error(err.Error())
 		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
@@ -145,7 +207,7 @@ func (h *Handler) PartialUpdateBankAccount(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *Handler) DeleteBankAccount(w http.ResponseWriter, r *http.Request) {
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		slog.Error(err.Error())
 		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
@@ -169,6 +231,13 @@ func (h *Handler) DeleteBankAccount(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if errors.Is(err, ErrPendingPayment) {
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+		return
+	}
 	if errors.Is(err, ErrForbidden) {
 		response.JSON(w, http.StatusForbidden, response.ResponseBody{
 			Message: "Forbidden",
@@ -188,19 +257,185 @@ func (h *Handler) DeleteBankAccount(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func getUserID(r *http.Request) (uint64, error) {
-	var userID uint64
-	var err error
+func (h *Handler) SetPrimaryBankAccount(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	params := mux.Vars(r)
+	uid, err := uuid.Parse(params["uuid"])
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   ErrNotFound.Error(),
+		})
+		return
+	}
 
-	if authValue, ok := r.Context().Value(middleware.ContextAuthKey{}).(string); ok {
-		userID, err = strconv.ParseUint(authValue, 10, 64)
-		if err != nil {
-			return 0, err
-		}
-	} else {
-		slog.Error("cannot parse auth value from context")
-		return 0, errors.New("cannot parse auth value from context")
+	bankAccountResp, err := h.service.SetPrimary(r.Context(), uid, userID)
+	if errors.Is(err, ErrNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrPrimaryNotVerified) {
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrForbidden) {
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "primary account updated successfully",
+		Data:    bankAccountResp,
+	})
+}
+
+func (h *Handler) StartVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	params := mux.Vars(r)
+	uid, err := uuid.Parse(params["uuid"])
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   ErrNotFound.Error(),
+		})
+		return
 	}
 
-	return userID, nil
+	bankAccountResp, err := h.service.StartVerification(r.Context(), uid, userID)
+	if err != nil {
+		writeVerificationError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "verification deposits sent",
+		Data:    bankAccountResp,
+	})
+}
+
+func (h *Handler) ConfirmVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	params := mux.Vars(r)
+	uid, err := uuid.Parse(params["uuid"])
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   ErrNotFound.Error(),
+		})
+		return
+	}
+
+	var req ConfirmVerificationPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	bankAccountResp, err := h.service.ConfirmVerification(r.Context(), req, uid, userID)
+	if err != nil {
+		writeVerificationError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "account verified successfully",
+		Data:    bankAccountResp,
+	})
+}
+
+func writeVerificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrValidationFailed),
+		errors.Is(err, ErrVerificationMismatch),
+		errors.Is(err, ErrVerificationExpired),
+		errors.Is(err, ErrVerificationAttempts):
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrNotFound):
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrForbidden):
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrVerificationRounds):
+		response.JSON(w, http.StatusTooManyRequests, response.ResponseBody{
+			Message: "Too many requests",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrAlreadyVerified),
+		errors.Is(err, ErrVerificationNotPending),
+		errors.Is(err, ErrVerificationChanged):
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+	default:
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+	}
+}
+
+func (h *Handler) ListBanks(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    h.service.ListBanks(r.Context()),
+	})
+}
+
+// shopIDFromQuery reads the optional shopId query parameter, which picks the shop
+// whose accounts are managed instead of the default shop of the user.
+func shopIDFromQuery(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	shopID := r.URL.Query().Get("shopId")
+	if shopID == "" {
+		return uuid.Nil, true
+	}
+	uid, err := uuid.Parse(shopID)
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   ErrShopNotFound.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return uid, true
 }
@@ -0,0 +1,91 @@
+package bankaccount
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+//go:embed banks.json
+var banksData []byte
+
+// Bank is an entry of the bank registry with the rules its account numbers follow.
+type Bank struct {
+	Code                 string `json:"code"`
+	Name                 string `json:"name"`
+	AccountNumberLengths []int  `json:"accountNumberLengths"`
+	// AccountNumberPattern defaults to digits only.
+	AccountNumberPattern string `json:"accountNumberPattern,omitempty"`
+	// Checksum names one of the checksum validators, empty when the bank has none.
+	Checksum string `json:"checksum,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+type Registry struct {
+	banks  []*Bank
+	byCode map[string]*Bank
+}
+
+// NewRegistry parses a JSON array of banks.
+func NewRegistry(data []byte) (*Registry, error) {
+	var banks []*Bank
+	if err := json.Unmarshal(data, &banks); err != nil {
+		return nil, err
+	}
+
+	r := &Registry{byCode: make(map[string]*Bank, len(banks))}
+	for _, b := range banks {
+		if b.Code == "" || len(b.AccountNumberLengths) == 0 {
+			return nil, fmt.Errorf("bank registry: %q needs a code and account number lengths", b.Name)
+		}
+		if _, ok := r.byCode[b.Code]; ok {
+			return nil, fmt.Errorf("bank registry: duplicate code %q", b.Code)
+		}
+		pattern := b.AccountNumberPattern
+		if pattern == "" {
+			pattern = `^[0-9]+$`
+		}
+		var err error
+		b.pattern, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bank registry: %s: %w", b.Code, err)
+		}
+		if _, ok := checksums[b.Checksum]; b.Checksum != "" && !ok {
+			return nil, fmt.Errorf("bank registry: %s: unknown checksum %q", b.Code, b.Checksum)
+		}
+		r.banks = append(r.banks, b)
+		r.byCode[b.Code] = b
+	}
+	return r, nil
+}
+
+// NewDefaultRegistry loads the registry embedded in the binary.
+func NewDefaultRegistry() (*Registry, error) {
+	return NewRegistry(banksData)
+}
+
+func (r *Registry) Get(code string) (*Bank, bool) {
+	b, ok := r.byCode[code]
+	return b, ok
+}
+
+func (r *Registry) List() []*Bank {
+	return r.banks
+}
+
+// ValidateAccountNumber checks number against the length, format and checksum rules of the bank.
+func (b *Bank) ValidateAccountNumber(number string) error {
+	if !slices.Contains(b.AccountNumberLengths, len(number)) {
+		return fmt.Errorf("%w: %s account numbers have %v digits", ErrInvalidAccountNumber, b.Name, b.AccountNumberLengths)
+	}
+	if !b.pattern.MatchString(number) {
+		return fmt.Errorf("%w: invalid format for %s", ErrInvalidAccountNumber, b.Name)
+	}
+	if b.Checksum != "" && !checksums[b.Checksum](number) {
+		return fmt.Errorf("%w: checksum does not match", ErrInvalidAccountNumber)
+	}
+	return nil
+}
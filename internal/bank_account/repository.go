@@ -4,44 +4,60 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/encryption"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type Repository interface {
 	Create(ctx context.Context, bankAccount *BankAccount) error
 	GetByUUID(ctx context.Context, uuid uuid.UUID) (*BankAccount, error)
-	List(ctx context.Context, userID uint64) ([]*BankAccount, error)
+	GetPrimary(ctx context.Context, shopID uint64) (*BankAccount, error)
+	List(ctx context.Context, shopID uint64) ([]*BankAccount, error)
+	ListWithArchived(ctx context.Context, userID uint64) ([]*BankAccount, error)
 	Update(ctx context.Context, bankAccount *BankAccount) error
+	SetPrimary(ctx context.Context, bankAccount *BankAccount) error
+	UpdateVerification(ctx context.Context, bankAccount *BankAccount, from Verification) error
 	Delete(ctx context.Context, uuid uuid.UUID) error
+	Reencrypt(ctx context.Context, limit int) (int, error)
 }
 
 type dbRepository struct {
-	db *db.DB
+	db      *db.DB
+	keyring *encryption.Keyring
 }
 
-func NewRepository(db *db.DB) Repository {
-	return &dbRepository{db: db}
+// NewRepository stores account numbers sealed with keyring.
+func NewRepository(db *db.DB, keyring *encryption.Keyring) Repository {
+	return &dbRepository{db: db, keyring: keyring}
 }
 
 // Create implements Repository.
 func (d *dbRepository) Create(ctx context.Context, bankAccount *BankAccount) error {
 	createUserQuery := `
 		INSERT INTO bank_accounts (
-			name, account_name, account_number, user_id
+			bank_code, name, account_name, account_number_ciphertext, account_number_data_key, account_number_key_id, user_id, shop_id, is_primary
 		) VALUES (
-			$1, $2, $3, $4
+			$1, $2, $3, $4, $5, $6, $7, $8,
+			NOT EXISTS (SELECT 1 FROM bank_accounts WHERE shop_id = $8 AND is_primary)
 		)
-		RETURNING id, uid;
+		RETURNING id, uid, is_primary, verification_status;
 	`
-	row := d.db.DB().QueryRowContext(ctx, createUserQuery, bankAccount.BankName, bankAccount.BankAccountName, bankAccount.BankAccountNumber, bankAccount.User.ID)
+	sealed, err := d.keyring.Seal([]byte(bankAccount.BankAccountNumber))
+	if err != nil {
+		return err
+	}
+	row := d.db.DB().QueryRowContext(ctx, createUserQuery, bankAccount.BankCode, bankAccount.BankName, bankAccount.BankAccountName,
+		sealed.Ciphertext, sealed.DataKey, sealed.KeyID, bankAccount.User.ID, bankAccount.ShopID)
 	if err := row.Err(); err != nil {
 		return err
 	}
 	var id uint64
 	var uuid uuid.UUID
-	err := row.Scan(&id, &uuid)
+	err = row.Scan(&id, &uuid, &bankAccount.IsPrimary, &bankAccount.Verification.Status)
 	if err != nil {
 		return err
 	}
@@ -50,54 +66,152 @@ func (d *dbRepository) Create(ctx context.Context, bankAccount *BankAccount) err
 	return nil
 }
 
-// GetByUUID implements Repository.
+// GetByUUID implements Repository. Archived accounts are returned as well.
 func (d *dbRepository) GetByUUID(ctx context.Context, uuid uuid.UUID) (*BankAccount, error) {
 	getUserQuery := `
-		SELECT b.uid, b.name, b.account_name, b.account_number, u.id
+		SELECT b.uid, COALESCE(b.bank_code, ''), b.name, b.account_name,
+			b.account_number, b.account_number_ciphertext, b.account_number_data_key, b.account_number_key_id, b.is_primary, b.archived_at,
+			b.verification_status, b.verification_amounts, b.verification_attempts, b.verification_rounds, b.verification_expires_at, b.verified_at, b.shop_id, u.id
 		FROM bank_accounts b
 		INNER JOIN users u on b.user_id = u.id
 		WHERE uid = $1;
 	`
-	row := d.db.DB().QueryRowContext(ctx, getUserQuery, uuid)
+	return d.get(ctx, getUserQuery, uuid)
+}
+
+// GetPrimary implements Repository.
+func (d *dbRepository) GetPrimary(ctx context.Context, shopID uint64) (*BankAccount, error) {
+	getQuery := `
+		SELECT b.uid, COALESCE(b.bank_code, ''), b.name, b.account_name,
+			b.account_number, b.account_number_ciphertext, b.account_number_data_key, b.account_number_key_id, b.is_primary, b.archived_at,
+			b.verification_status, b.verification_amounts, b.verification_attempts, b.verification_rounds, b.verification_expires_at, b.verified_at, b.shop_id, u.id
+		FROM bank_accounts b
+		INNER JOIN users u on b.user_id = u.id
+		WHERE b.shop_id = $1 AND b.is_primary;
+	`
+	return d.get(ctx, getQuery, shopID)
+}
+
+func (d *dbRepository) get(ctx context.Context, query string, args ...any) (*BankAccount, error) {
+	row := d.db.DB().QueryRowContext(ctx, query, args...)
 	i := &BankAccount{}
-	err := row.Scan(&i.UUID, &i.User.Name, &i.BankAccountName, &i.BankAccountNumber, &i.User.ID)
+	var number sealedAccountNumber
+	err := row.Scan(&i.UUID, &i.BankCode, &i.BankName, &i.BankAccountName,
+		&number.plaintext, &number.ciphertext, &number.dataKey, &number.keyID, &i.IsPrimary, &i.ArchivedAt,
+		&i.Verification.Status, pq.Array(&i.Verification.Amounts), &i.Verification.Attempts, &i.Verification.Rounds, &i.Verification.ExpiresAt, &i.Verification.VerifiedAt, &i.ShopID, &i.User.ID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
+	i.BankAccountNumber, err = d.openAccountNumber(number)
+	if err != nil {
+		return nil, err
+	}
 	return i, nil
 }
 
-// Delete implements Repository.
+// Delete implements Repository. Accounts that purchases were paid into are archived
+// instead, so the purchases keep their bank account. Deleting or archiving the primary
+// account makes the oldest remaining account of the shop primary, verified ones first.
 func (d *dbRepository) Delete(ctx context.Context, uid uuid.UUID) error {
+	lockQuery := `
+		SELECT shop_id, is_primary
+		FROM bank_accounts
+		WHERE uid = $1 AND archived_at IS NULL
+		FOR UPDATE;
+	`
+	transactionsQuery := `
+		SELECT
+			EXISTS (SELECT 1 FROM user_transactions WHERE bank_account_id = $1),
+			EXISTS (SELECT 1 FROM user_transactions WHERE bank_account_id = $1 AND status = 'pending');
+	`
+	archiveQuery := `
+		UPDATE bank_accounts
+		SET archived_at = current_timestamp,
+		is_primary = false
+		WHERE uid = $1;
+	`
 	deleteQuery := `
 		DELETE FROM bank_accounts
 		WHERE uid = $1;
 	`
-	row, err := d.db.DB().ExecContext(ctx, deleteQuery, uid)
-	if err != nil {
-		return err
-	}
-	rowsAffected, err := row.RowsAffected()
-	if err != nil {
+	promoteQuery := `
+		UPDATE bank_accounts
+		SET is_primary = true
+		WHERE id = (
+			SELECT id FROM bank_accounts
+			WHERE shop_id = $1 AND archived_at IS NULL
+			ORDER BY verification_status = 'verified' DESC, id
+			LIMIT 1
+		);
+	`
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		var shopID uint64
+		var isPrimary bool
+		err := tx.QueryRowContext(ctx, lockQuery, uid).Scan(&shopID, &isPrimary)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var hasTransactions, hasPending bool
+		err = tx.QueryRowContext(ctx, transactionsQuery, uid).Scan(&hasTransactions, &hasPending)
+		if err != nil {
+			return err
+		}
+		if hasPending {
+			return ErrPendingPayment
+		}
+		if hasTransactions {
+			_, err = tx.ExecContext(ctx, archiveQuery, uid)
+		} else {
+			_, err = tx.ExecContext(ctx, deleteQuery, uid)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !isPrimary {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, promoteQuery, shopID)
 		return err
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
-	}
-	return nil
+	})
+}
+
+// List implements Repository. It lists the accounts of a shop.
+func (d *dbRepository) List(ctx context.Context, shopID uint64) ([]*BankAccount, error) {
+	listQuery := `
+		SELECT uid, COALESCE(bank_code, ''), name, account_name,
+			account_number, account_number_ciphertext, account_number_data_key, account_number_key_id, is_primary,
+			archived_at, verification_status, verified_at, shop_id, user_id
+		FROM bank_accounts
+		WHERE shop_id = $1 AND archived_at IS NULL
+		ORDER BY is_primary DESC, id;
+	`
+	return d.list(ctx, listQuery, shopID)
 }
 
-// List implements Repository.
-func (d *dbRepository) List(ctx context.Context, userID uint64) ([]*BankAccount, error) {
+// ListWithArchived implements Repository. It lists the accounts of all shops the
+// user owns.
+func (d *dbRepository) ListWithArchived(ctx context.Context, userID uint64) ([]*BankAccount, error) {
 	listQuery := `
-		SELECT uid, name, account_name, account_number
+		SELECT uid, COALESCE(bank_code, ''), name, account_name,
+			account_number, account_number_ciphertext, account_number_data_key, account_number_key_id, is_primary,
+			archived_at, verification_status, verified_at, shop_id, user_id
 		FROM bank_accounts
-		WHERE user_id = $1;
+		WHERE user_id = $1
+		ORDER BY shop_id, is_primary DESC, id;
 	`
-	rows, err := d.db.DB().QueryContext(ctx, listQuery, userID)
+	return d.list(ctx, listQuery, userID)
+}
+
+func (d *dbRepository) list(ctx context.Context, query string, args ...any) ([]*BankAccount, error) {
+	rows, err := d.db.DB().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +219,15 @@ func (d *dbRepository) List(ctx context.Context, userID uint64) ([]*BankAccount,
 	var bankAccounts []*BankAccount
 	for rows.Next() {
 		i := &BankAccount{}
-		if err := rows.Scan(&i.UUID, &i.BankName, &i.BankAccountName, &i.BankAccountNumber); err != nil {
+		var number sealedAccountNumber
+		err := rows.Scan(&i.UUID, &i.BankCode, &i.BankName, &i.BankAccountName,
+			&number.plaintext, &number.ciphertext, &number.dataKey, &number.keyID, &i.IsPrimary,
+			&i.ArchivedAt, &i.Verification.Status, &i.Verification.VerifiedAt, &i.ShopID, &i.User.ID)
+		if err != nil {
+			return nil, err
+		}
+		i.BankAccountNumber, err = d.openAccountNumber(number)
+		if err != nil {
 			return nil, err
 		}
 		bankAccounts = append(bankAccounts, i)
@@ -123,11 +245,247 @@ func (d *dbRepository) List(ctx context.Context, userID uint64) ([]*BankAccount,
 func (d *dbRepository) Update(ctx context.Context, bankAccount *BankAccount) error {
 	updateQuery := `
 		UPDATE bank_accounts
-		SET name = $1,
-		account_name = $2,
-		account_number = $3
-		WHERE uid = $4;
+		SET bank_code = $1,
+		name = $2,
+		account_name = $3,
+		account_number = NULL,
+		account_number_ciphertext = $4,
+		account_number_data_key = $5,
+		account_number_key_id = $6,
+		verification_status = $7,
+		verification_amounts = $8,
+		verification_attempts = $9,
+		verification_expires_at = $10,
+		verified_at = $11
+		WHERE uid = $12;
+	`
+	sealed, err := d.keyring.Seal([]byte(bankAccount.BankAccountNumber))
+	if err != nil {
+		return err
+	}
+	v := bankAccount.Verification
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, updateQuery, bankAccount.BankCode, bankAccount.BankName, bankAccount.BankAccountName,
+			sealed.Ciphertext, sealed.DataKey, sealed.KeyID,
+			v.Status, pq.Array(v.Amounts), v.Attempts, v.ExpiresAt, v.VerifiedAt, bankAccount.UUID)
+		if err != nil {
+			return err
+		}
+		// a verified primary account that has to be verified again hands over to
+		// another verified one
+		bankAccount.IsPrimary, err = promoteVerified(ctx, tx, bankAccount)
+		return err
+	})
+}
+
+// UpdateVerification implements Repository. It fails with ErrVerificationChanged when
+// the verification is no longer in the from state, e.g. after a concurrent attempt.
+func (d *dbRepository) UpdateVerification(ctx context.Context, bankAccount *BankAccount, from Verification) error {
+	updateQuery := `
+		UPDATE bank_accounts
+		SET verification_status = $1,
+		verification_amounts = $2,
+		verification_attempts = $3,
+		verification_rounds = $4,
+		verification_expires_at = $5,
+		verified_at = $6
+		WHERE uid = $7
+		AND verification_status = $8
+		AND verification_attempts = $9
+		AND verification_rounds = $10
+		AND archived_at IS NULL;
 	`
-	_, err := d.db.DB().ExecContext(ctx, updateQuery, bankAccount.BankName, bankAccount.BankAccountName, bankAccount.BankAccountNumber, bankAccount.UUID)
-	return err
+	v := bankAccount.Verification
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, updateQuery, v.Status, pq.Array(v.Amounts), v.Attempts, v.Rounds, v.ExpiresAt, v.VerifiedAt,
+			bankAccount.UUID, from.Status, from.Attempts, from.Rounds)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrVerificationChanged
+		}
+		if v.Status != VerificationVerified {
+			return nil
+		}
+		bankAccount.IsPrimary, err = promoteVerified(ctx, tx, bankAccount)
+		return err
+	})
+}
+
+// promoteVerified makes the oldest verified account of the shop of bankAccount its
+// primary one unless the primary account is verified already, so purchases that do
+// not pick an account keep landing in one that can be paid into. It reports whether
+// bankAccount is primary afterwards.
+func promoteVerified(ctx context.Context, tx *sql.Tx, bankAccount *BankAccount) (bool, error) {
+	candidateQuery := `
+		SELECT uid, is_primary
+		FROM bank_accounts
+		WHERE shop_id = $1 AND verification_status = $2 AND archived_at IS NULL
+		ORDER BY is_primary DESC, id
+		LIMIT 1;
+	`
+	unsetQuery := `
+		UPDATE bank_accounts
+		SET is_primary = false
+		WHERE shop_id = $1 AND is_primary AND uid <> $2;
+	`
+	setQuery := `
+		UPDATE bank_accounts
+		SET is_primary = true
+		WHERE uid = $1;
+	`
+	var candidate uuid.UUID
+	var isPrimary bool
+	err := tx.QueryRowContext(ctx, candidateQuery, bankAccount.ShopID, VerificationVerified).Scan(&candidate, &isPrimary)
+	if errors.Is(err, sql.ErrNoRows) {
+		return bankAccount.IsPrimary, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !isPrimary {
+		_, err = tx.ExecContext(ctx, unsetQuery, bankAccount.ShopID, candidate)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, setQuery, candidate)
+		if err != nil {
+			return false, err
+		}
+		return candidate == bankAccount.UUID, nil
+	}
+	return bankAccount.IsPrimary, nil
+}
+
+// SetPrimary implements Repository. It fails with ErrPrimaryNotVerified unless the
+// account is verified.
+func (d *dbRepository) SetPrimary(ctx context.Context, bankAccount *BankAccount) error {
+	unsetQuery := `
+		UPDATE bank_accounts
+		SET is_primary = false
+		WHERE shop_id = $1 AND is_primary AND uid <> $2;
+	`
+	setQuery := `
+		UPDATE bank_accounts
+		SET is_primary = true
+		WHERE uid = $1 AND verification_status = $2;
+	`
+	err := d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, unsetQuery, bankAccount.ShopID, bankAccount.UUID)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, setQuery, bankAccount.UUID, VerificationVerified)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			// the verification was reset since it was read
+			return ErrPrimaryNotVerified
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bankAccount.IsPrimary = true
+	return nil
+}
+
+// Reencrypt implements Repository. It seals up to limit account numbers that are still
+// in plaintext or sealed with a key other than the active one, and returns how many
+// rows it changed.
+func (d *dbRepository) Reencrypt(ctx context.Context, limit int) (int, error) {
+	selectQuery := `
+		SELECT id, account_number, account_number_ciphertext, account_number_data_key, account_number_key_id
+		FROM bank_accounts
+		WHERE account_number_key_id IS DISTINCT FROM $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED;
+	`
+	updateQuery := `
+		UPDATE bank_accounts
+		SET account_number = NULL,
+		account_number_ciphertext = $1,
+		account_number_data_key = $2,
+		account_number_key_id = $3
+		WHERE id = $4;
+	`
+	var count int
+	err := d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, selectQuery, d.keyring.ActiveKeyID(), limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		ids := make([]uint64, 0, limit)
+		numbers := make([]string, 0, limit)
+		for rows.Next() {
+			var id uint64
+			var number sealedAccountNumber
+			if err := rows.Scan(&id, &number.plaintext, &number.ciphertext, &number.dataKey, &number.keyID); err != nil {
+				return err
+			}
+			plaintext, err := d.openAccountNumber(number)
+			if err != nil {
+				return fmt.Errorf("bank account %d: %w", id, err)
+			}
+			ids = append(ids, id)
+			numbers = append(numbers, plaintext)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			sealed, err := d.keyring.Seal([]byte(numbers[i]))
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, updateQuery, sealed.Ciphertext, sealed.DataKey, sealed.KeyID, id)
+			if err != nil {
+				return err
+			}
+		}
+		count = len(ids)
+		return nil
+	})
+	return count, err
+}
+
+// sealedAccountNumber is an account number as read from the database. Rows written
+// before encryption was introduced only have the plaintext.
+type sealedAccountNumber struct {
+	plaintext  sql.NullString
+	ciphertext []byte
+	dataKey    []byte
+	keyID      sql.NullString
+}
+
+func (d *dbRepository) openAccountNumber(number sealedAccountNumber) (string, error) {
+	if !number.keyID.Valid {
+		return number.plaintext.String, nil
+	}
+	plaintext, err := d.keyring.Open(&encryption.Sealed{
+		KeyID:      number.keyID.String,
+		DataKey:    number.dataKey,
+		Ciphertext: number.ciphertext,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
 }
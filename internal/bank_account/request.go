@@ -3,15 +3,34 @@ package bankaccount
 import validation "github.com/go-ozzo/ozzo-validation/v4"
 
 type CreateUpdateBankAccountPayload struct {
-	BankName          string `json:"bankName"`
+	BankCode          string `json:"bankCode"`
 	BankAccountName   string `json:"bankAccountName"`
 	BankAccountNumber string `json:"bankAccountNumber"`
 }
 
+type ConfirmVerificationPayload struct {
+	Amounts []int64 `json:"amounts"`
+}
+
+func (p ConfirmVerificationPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Amounts, validation.Required, validation.Length(microDepositCount, microDepositCount),
+			validation.Each(validation.Required, validation.Min(int64(1)), validation.Max(int64(maxMicroDeposit)))),
+	)
+}
+
 func (p CreateUpdateBankAccountPayload) Validate() error {
 	return validation.ValidateStruct(&p,
-		validation.Field(&p.BankName, validation.Required, validation.Length(5, 15)),
+		validation.Field(&p.BankCode, validation.Required),
 		validation.Field(&p.BankAccountName, validation.Required, validation.Length(5, 15)),
-		validation.Field(&p.BankAccountNumber, validation.Required, validation.Length(5, 15)),
+		validation.Field(&p.BankAccountNumber, validation.Required, validation.Length(5, 20)),
+	)
+}
+
+// ValidatePartial validates the fields of a partial update that are set.
+func (p CreateUpdateBankAccountPayload) ValidatePartial() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.BankAccountName, validation.Length(5, 15)),
+		validation.Field(&p.BankAccountNumber, validation.Length(5, 20)),
 	)
 }
@@ -1,8 +1,53 @@
 package bankaccount
 
+import (
+	"strings"
+	"time"
+)
+
 type BankAccountResponse struct {
 	BankAccountID     string `json:"bankAccountId"`
+	BankCode          string `json:"bankCode"`
 	BankName          string `json:"bankName"`
 	BankAccountName   string `json:"bankAccountName"`
 	BankAccountNumber string `json:"bankAccountNumber"`
+	IsPrimary         bool   `json:"isPrimary"`
+
+	VerificationStatus    VerificationStatus `json:"verificationStatus"`
+	VerificationExpiresAt *time.Time         `json:"verificationExpiresAt,omitempty"`
+	ArchivedAt            *time.Time         `json:"archivedAt,omitempty"`
+}
+
+type BankResponse struct {
+	Code                 string `json:"code"`
+	Name                 string `json:"name"`
+	AccountNumberLengths []int  `json:"accountNumberLengths"`
+}
+
+func CreateBankAccountResponse(bankAccount *BankAccount) *BankAccountResponse {
+	resp := &BankAccountResponse{
+		BankAccountID:     bankAccount.UUID.String(),
+		BankCode:          bankAccount.BankCode,
+		BankName:          bankAccount.BankName,
+		BankAccountName:   bankAccount.BankAccountName,
+		BankAccountNumber: bankAccount.BankAccountNumber,
+		IsPrimary:         bankAccount.IsPrimary,
+
+		VerificationStatus: bankAccount.Verification.Status,
+	}
+	if bankAccount.Verification.Status == VerificationPending && bankAccount.Verification.ExpiresAt.Valid {
+		resp.VerificationExpiresAt = &bankAccount.Verification.ExpiresAt.Time
+	}
+	if bankAccount.IsArchived() {
+		resp.ArchivedAt = &bankAccount.ArchivedAt.Time
+	}
+	return resp
+}
+
+// MaskAccountNumber hides every digit but the last four.
+func MaskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
 }
@@ -2,38 +2,82 @@ package bankaccount
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/citadel-corp/shopifyx-marketplace/internal/shop"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
 	"github.com/google/uuid"
 )
 
 type Service interface {
-	Create(ctx context.Context, req CreateUpdateBankAccountPayload, userID uint64) (*BankAccountResponse, error)
-	List(ctx context.Context, userID uint64) ([]*BankAccountResponse, error)
+	Create(ctx context.Context, req CreateUpdateBankAccountPayload, shopUID uuid.UUID, userID uint64) (*BankAccountResponse, error)
+	List(ctx context.Context, shopUID uuid.UUID, userID uint64) ([]*BankAccountResponse, error)
+	ListForAdmin(ctx context.Context, userID uint64) ([]*BankAccountResponse, error)
 	PartialUpdate(ctx context.Context, req CreateUpdateBankAccountPayload, uuid uuid.UUID, userID uint64) (*BankAccountResponse, error)
 	Delete(ctx context.Context, uuid uuid.UUID, userID uint64) error
+	SetPrimary(ctx context.Context, uuid uuid.UUID, userID uint64) (*BankAccountResponse, error)
+	ListBanks(ctx context.Context) []*BankResponse
+	StartVerification(ctx context.Context, uuid uuid.UUID, userID uint64) (*BankAccountResponse, error)
+	ConfirmVerification(ctx context.Context, req ConfirmVerificationPayload, uuid uuid.UUID, userID uint64) (*BankAccountResponse, error)
 }
 
 type bankAccountService struct {
-	repository Repository
+	repository         Repository
+	shopService        shop.Service
+	registry           *Registry
+	transferer         Transferer
+	verificationWindow time.Duration
+	maxAttempts        int
+	maxRounds          int
 }
 
-func NewService(repository Repository) Service {
-	return &bankAccountService{repository: repository}
+// NewService verifies accounts with deposits sent through transferer, which the
+// owner has verificationWindow and maxAttempts tries to confirm. At most maxRounds
+// verifications are started per account. Accounts are managed by the shop owner and
+// members allowed to by shopService.
+func NewService(repository Repository, shopService shop.Service, registry *Registry, transferer Transferer, verificationWindow time.Duration, maxAttempts, maxRounds int) Service {
+	return &bankAccountService{
+		repository:         repository,
+		shopService:        shopService,
+		registry:           registry,
+		transferer:         transferer,
+		verificationWindow: verificationWindow,
+		maxAttempts:        maxAttempts,
+		maxRounds:          maxRounds,
+	}
 }
 
-func (s *bankAccountService) Create(ctx context.Context, req CreateUpdateBankAccountPayload, userID uint64) (*BankAccountResponse, error) {
+// Create implements Service. Accounts are created in the default shop of the user when
+// no shop is given.
+func (s *bankAccountService) Create(ctx context.Context, req CreateUpdateBankAccountPayload, shopUID uuid.UUID, userID uint64) (*BankAccountResponse, error) {
 	err := req.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
 	}
+	bank, err := s.validateAccountNumber(req.BankCode, req.BankAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	var sh *shop.Shop
+	if shopUID == uuid.Nil {
+		sh, err = s.shopService.DefaultShop(ctx, userID)
+	} else {
+		sh, err = s.getShop(ctx, shopUID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
 	bankAccount := &BankAccount{
-		BankName:          req.BankName,
+		BankCode:          bank.Code,
+		BankName:          bank.Name,
 		BankAccountName:   req.BankAccountName,
 		BankAccountNumber: req.BankAccountNumber,
+		ShopID:            sh.ID,
 		User: user.User{
-			ID: userID,
+			ID: sh.OwnerID,
 		},
 	}
 
@@ -41,73 +85,104 @@ func (s *bankAccountService) Create(ctx context.Context, req CreateUpdateBankAcc
 	if err != nil {
 		return nil, err
 	}
-	return &BankAccountResponse{
-		BankAccountID:     bankAccount.UUID.String(),
-		BankName:          bankAccount.BankName,
-		BankAccountName:   bankAccount.BankAccountName,
-		BankAccountNumber: bankAccount.BankAccountNumber,
-	}, nil
+	return CreateBankAccountResponse(bankAccount), nil
 }
 
 // Delete implements Service.
 func (s *bankAccountService) Delete(ctx context.Context, uuid uuid.UUID, userID uint64) error {
-	bankAccount, err := s.repository.GetByUUID(ctx, uuid)
+	_, err := s.getOwned(ctx, uuid, userID)
 	if err != nil {
 		return err
 	}
-	if bankAccount.User.ID != userID {
-		return ErrForbidden
-	}
 	return s.repository.Delete(ctx, uuid)
 }
 
-// List implements Service.
-func (s *bankAccountService) List(ctx context.Context, userID uint64) ([]*BankAccountResponse, error) {
-	bankAccounts, err := s.repository.List(ctx, userID)
+// SetPrimary implements Service. Purchases are paid into the primary account, so
+// only a verified account can become it.
+func (s *bankAccountService) SetPrimary(ctx context.Context, uuid uuid.UUID, userID uint64) (*BankAccountResponse, error) {
+	bankAccount, err := s.getOwned(ctx, uuid, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bankAccount.Verification.Status != VerificationVerified {
+		return nil, ErrPrimaryNotVerified
+	}
+	err = s.repository.SetPrimary(ctx, bankAccount)
+	if err != nil {
+		return nil, err
+	}
+	return CreateBankAccountResponse(bankAccount), nil
+}
+
+// List implements Service. It lists the accounts of the default shop of the user when
+// no shop is given.
+func (s *bankAccountService) List(ctx context.Context, shopUID uuid.UUID, userID uint64) ([]*BankAccountResponse, error) {
+	sh, err := s.getShop(ctx, shopUID, userID)
+	if errors.Is(err, ErrShopNotFound) && shopUID == uuid.Nil {
+		// users who never sold have no default shop yet
+		return []*BankAccountResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	bankAccounts, err := s.repository.List(ctx, sh.ID)
 	if err != nil {
 		return nil, err
 	}
 	resp := make([]*BankAccountResponse, len(bankAccounts))
 	for i, bankAccount := range bankAccounts {
-		resp[i] = &BankAccountResponse{
-			BankAccountID:     bankAccount.UUID.String(),
-			BankName:          bankAccount.BankName,
-			BankAccountName:   bankAccount.BankAccountName,
-			BankAccountNumber: bankAccount.BankAccountNumber,
-		}
+		resp[i] = CreateBankAccountResponse(bankAccount)
+	}
+	return resp, nil
+}
+
+// ListForAdmin implements Service. Admins also see archived accounts, which purchases
+// may still refer to, with masked account numbers.
+func (s *bankAccountService) ListForAdmin(ctx context.Context, userID uint64) ([]*BankAccountResponse, error) {
+	bankAccounts, err := s.repository.ListWithArchived(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*BankAccountResponse, len(bankAccounts))
+	for i, bankAccount := range bankAccounts {
+		resp[i] = CreateBankAccountResponse(bankAccount)
+		resp[i].BankAccountNumber = MaskAccountNumber(resp[i].BankAccountNumber)
 	}
 	return resp, nil
 }
 
-// PartialUpdate implements Service.
+// PartialUpdate implements Service. Only the fields that are set change, the account
+// number is checked against the bank the account ends up with.
 func (s *bankAccountService) PartialUpdate(ctx context.Context, req CreateUpdateBankAccountPayload, uuid uuid.UUID, userID uint64) (*BankAccountResponse, error) {
-	err := req.Validate()
+	err := req.ValidatePartial()
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
 	}
-	bankAccount, err := s.repository.GetByUUID(ctx, uuid)
+	bankAccount, err := s.getOwned(ctx, uuid, userID)
 	if err != nil {
 		return nil, err
 	}
-	if bankAccount.User.ID != userID {
-		return nil, ErrForbidden
-	}
+	bankCode, accountNumber := bankAccount.BankCode, bankAccount.BankAccountNumber
 	s.applyPartialUpdate(req, bankAccount)
+	bank, err := s.validateAccountNumber(bankAccount.BankCode, bankAccount.BankAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if bankAccount.BankCode != bankCode || bankAccount.BankAccountNumber != accountNumber {
+		// a different account has to be verified again
+		bankAccount.Verification = bankAccount.Verification.reset()
+	}
+	bankAccount.BankName = bank.Name
 	err = s.repository.Update(ctx, bankAccount)
 	if err != nil {
 		return nil, err
 	}
-	return &BankAccountResponse{
-		BankAccountID:     bankAccount.UUID.String(),
-		BankName:          bankAccount.BankName,
-		BankAccountName:   bankAccount.BankAccountName,
-		BankAccountNumber: bankAccount.BankAccountNumber,
-	}, nil
+	return CreateBankAccountResponse(bankAccount), nil
 }
 
 func (s *bankAccountService) applyPartialUpdate(req CreateUpdateBankAccountPayload, bankAccount *BankAccount) {
-	if req.BankName != "" {
-		bankAccount.BankName = req.BankName
+	if req.BankCode != "" {
+		bankAccount.BankCode = req.BankCode
 	}
 	if req.BankAccountName != "" {
 		bankAccount.BankAccountName = req.BankAccountName
@@ -116,3 +191,156 @@ func (s *bankAccountService) applyPartialUpdate(req CreateUpdateBankAccountPaylo
 		bankAccount.BankAccountNumber = req.BankAccountNumber
 	}
 }
+
+// ListBanks implements Service.
+func (s *bankAccountService) ListBanks(ctx context.Context) []*BankResponse {
+	banks := s.registry.List()
+	resp := make([]*BankResponse, len(banks))
+	for i, bank := range banks {
+		resp[i] = &BankResponse{
+			Code:                 bank.Code,
+			Name:                 bank.Name,
+			AccountNumberLengths: bank.AccountNumberLengths,
+		}
+	}
+	return resp
+}
+
+func (s *bankAccountService) validateAccountNumber(bankCode, accountNumber string) (*Bank, error) {
+	bank, ok := s.registry.Get(bankCode)
+	if !ok {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, ErrUnknownBank)
+	}
+	if err := bank.ValidateAccountNumber(accountNumber); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	return bank, nil
+}
+
+// StartVerification implements Service. Starting again while a verification is
+// pending sends new deposits and resets the attempts, up to maxRounds times in total.
+func (s *bankAccountService) StartVerification(ctx context.Context, uuid uuid.UUID, userID uint64) (*BankAccountResponse, error) {
+	bankAccount, err := s.getOwned(ctx, uuid, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bankAccount.Verification.Status == VerificationVerified {
+		return nil, ErrAlreadyVerified
+	}
+	if bankAccount.Verification.Rounds >= s.maxRounds {
+		return nil, ErrVerificationRounds
+	}
+	amounts, err := generateMicroDeposits()
+	if err != nil {
+		return nil, err
+	}
+
+	from := bankAccount.Verification
+	bankAccount.Verification = Verification{
+		Status:    VerificationPending,
+		Amounts:   amounts,
+		Rounds:    from.Rounds + 1,
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(s.verificationWindow), Valid: true},
+	}
+	err = s.repository.UpdateVerification(ctx, bankAccount, from)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, amount := range amounts {
+		err = s.transferer.Transfer(ctx, TransferRequest{
+			BankCode:      bankAccount.BankCode,
+			AccountName:   bankAccount.BankAccountName,
+			AccountNumber: bankAccount.BankAccountNumber,
+			Amount:        amount,
+			Description:   "shopifyx account verification",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("sending verification deposit: %w", err)
+		}
+	}
+	return CreateBankAccountResponse(bankAccount), nil
+}
+
+// ConfirmVerification implements Service.
+func (s *bankAccountService) ConfirmVerification(ctx context.Context, req ConfirmVerificationPayload, uuid uuid.UUID, userID uint64) (*BankAccountResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	bankAccount, err := s.getOwned(ctx, uuid, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	from := bankAccount.Verification
+	switch from.Status {
+	case VerificationVerified:
+		return nil, ErrAlreadyVerified
+	case VerificationPending:
+	default:
+		return nil, ErrVerificationNotPending
+	}
+
+	now := time.Now()
+	var result error
+	switch {
+	case from.Expired(now):
+		bankAccount.Verification = from.reset()
+		result = ErrVerificationExpired
+	case from.Matches(req.Amounts):
+		bankAccount.Verification = Verification{
+			Status:     VerificationVerified,
+			Rounds:     from.Rounds,
+			VerifiedAt: sql.NullTime{Time: now, Valid: true},
+		}
+	case from.Attempts+1 >= s.maxAttempts:
+		bankAccount.Verification = from.reset()
+		result = ErrVerificationAttempts
+	default:
+		bankAccount.Verification.Attempts++
+		result = ErrVerificationMismatch
+	}
+
+	err = s.repository.UpdateVerification(ctx, bankAccount, from)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return nil, result
+	}
+	return CreateBankAccountResponse(bankAccount), nil
+}
+
+func (s *bankAccountService) getOwned(ctx context.Context, uuid uuid.UUID, userID uint64) (*BankAccount, error) {
+	bankAccount, err := s.repository.GetByUUID(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if bankAccount.IsArchived() {
+		return nil, ErrNotFound
+	}
+	err = s.shopService.Authorize(ctx, bankAccount.ShopID, userID, shop.PermissionManageBankAccounts)
+	if errors.Is(err, shop.ErrForbidden) {
+		return nil, ErrForbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bankAccount, nil
+}
+
+// getShop returns the shop whose accounts the user manages.
+func (s *bankAccountService) getShop(ctx context.Context, shopUID uuid.UUID, userID uint64) (*shop.Shop, error) {
+	sh, err := s.shopService.Authorized(ctx, shopUID, userID, shop.PermissionManageBankAccounts)
+	if errors.Is(err, shop.ErrNotFound) {
+		return nil, ErrShopNotFound
+	}
+	if errors.Is(err, shop.ErrForbidden) {
+		return nil, ErrForbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sh, nil
+}
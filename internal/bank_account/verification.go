@@ -0,0 +1,104 @@
+package bankaccount
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"time"
+)
+
+type VerificationStatus string
+
+const (
+	VerificationUnverified VerificationStatus = "unverified"
+	VerificationPending    VerificationStatus = "pending"
+	VerificationVerified   VerificationStatus = "verified"
+)
+
+// microDepositCount is how many deposits are sent, each between 1 and maxMicroDeposit.
+const (
+	microDepositCount = 2
+	maxMicroDeposit   = 99
+)
+
+// Verification proves that a seller owns a bank account: small deposits are
+// sent to the account and the seller confirms their amounts.
+type Verification struct {
+	Status   VerificationStatus
+	Amounts  []int64
+	Attempts int
+	// Rounds counts the verifications ever started for the account.
+	Rounds     int
+	ExpiresAt  sql.NullTime
+	VerifiedAt sql.NullTime
+}
+
+// reset is the verification after it failed or the account changed. The rounds
+// are kept, so failing does not allow more of them.
+func (v Verification) reset() Verification {
+	return Verification{Status: VerificationUnverified, Rounds: v.Rounds}
+}
+
+// Matches reports whether amounts are the deposited amounts, in any order.
+func (v Verification) Matches(amounts []int64) bool {
+	if len(amounts) != len(v.Amounts) {
+		return false
+	}
+	want := slices.Clone(v.Amounts)
+	got := slices.Clone(amounts)
+	slices.Sort(want)
+	slices.Sort(got)
+	return slices.Equal(want, got)
+}
+
+func (v Verification) Expired(now time.Time) bool {
+	return v.ExpiresAt.Valid && now.After(v.ExpiresAt.Time)
+}
+
+func generateMicroDeposits() ([]int64, error) {
+	amounts := make([]int64, 0, microDepositCount)
+	for len(amounts) < microDepositCount {
+		n, err := rand.Int(rand.Reader, big.NewInt(maxMicroDeposit))
+		if err != nil {
+			return nil, err
+		}
+		amount := n.Int64() + 1
+		// distinct amounts so the seller cannot confirm by guessing one value twice
+		if slices.Contains(amounts, amount) {
+			continue
+		}
+		amounts = append(amounts, amount)
+	}
+	return amounts, nil
+}
+
+type TransferRequest struct {
+	BankCode      string
+	AccountName   string
+	AccountNumber string
+	Amount        int64
+	Description   string
+}
+
+// Transferer sends money to a bank account, it is used for the verification deposits.
+type Transferer interface {
+	Transfer(ctx context.Context, req TransferRequest) error
+}
+
+// LogTransferer only logs the transfers, for local development.
+type LogTransferer struct{}
+
+func NewLogTransferer() *LogTransferer {
+	return &LogTransferer{}
+}
+
+// Transfer implements Transferer.
+func (t *LogTransferer) Transfer(ctx context.Context, req TransferRequest) error {
+	slog.Info(fmt.Sprintf("transfer of %d to %s account %s (%s): %s",
+		req.Amount, req.BankCode, MaskAccountNumber(req.AccountNumber), req.AccountName, req.Description))
+	return nil
+}
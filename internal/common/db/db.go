@@ -20,6 +20,16 @@ type DB struct {
 	sqlDB *sql.DB
 }
 
+// URLFromEnv builds the connection string from the DB_* and ENV variables.
+func URLFromEnv() string {
+	sslMode := "disable"
+	if os.Getenv("ENV") == "production" {
+		sslMode = "verify-full sslrootcert=ap-southeast-1-bundle.pem"
+	}
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), sslMode)
+}
+
 func Connect(dbURL string) (*DB, error) {
 	db, err := sql.Open("pgx", dbURL)
 	if err != nil {
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS payment_webhook_events;
+
+DROP INDEX IF EXISTS payments_transaction_id;
+DROP TABLE IF EXISTS payments;
+
+ALTER TABLE user_transactions
+	DROP CONSTRAINT IF EXISTS user_transaction_uid_unique;
+
+ALTER TABLE user_transactions ALTER COLUMN image_url SET NOT NULL;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS paid_at;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS status;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS payment_method;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS amount;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS quantity;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS uid;
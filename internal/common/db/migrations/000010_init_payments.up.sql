@@ -0,0 +1,43 @@
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS uid UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 1;
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS amount INT NOT NULL DEFAULT 0;
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS payment_method VARCHAR(20) NOT NULL DEFAULT 'transfer';
+-- rows created before payments existed were settled by the seller
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'paid';
+ALTER TABLE user_transactions ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS paid_at TIMESTAMP;
+ALTER TABLE user_transactions ALTER COLUMN image_url DROP NOT NULL;
+
+ALTER TABLE user_transactions
+	DROP CONSTRAINT IF EXISTS user_transaction_uid_unique;
+ALTER TABLE user_transactions
+	ADD CONSTRAINT user_transaction_uid_unique UNIQUE (uid);
+
+CREATE TABLE IF NOT EXISTS payments (
+	id SERIAL PRIMARY KEY,
+	uid UUID NOT NULL DEFAULT gen_random_uuid(),
+	transaction_id INT NOT NULL,
+	provider VARCHAR(30) NOT NULL,
+	charge_id VARCHAR(100) NOT NULL,
+	amount INT NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP DEFAULT current_timestamp,
+	updated_at TIMESTAMP DEFAULT current_timestamp
+);
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS fk_transaction_id;
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payment_provider_charge_id_unique;
+
+ALTER TABLE payments
+	ADD CONSTRAINT fk_transaction_id FOREIGN KEY (transaction_id) REFERENCES user_transactions(id) ON DELETE CASCADE;
+ALTER TABLE payments
+	ADD CONSTRAINT payment_provider_charge_id_unique UNIQUE (provider, charge_id);
+
+CREATE INDEX IF NOT EXISTS payments_transaction_id
+	ON payments (transaction_id);
+
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+	event_id VARCHAR(100) PRIMARY KEY,
+	provider VARCHAR(30) NOT NULL,
+	received_at TIMESTAMP DEFAULT current_timestamp
+);
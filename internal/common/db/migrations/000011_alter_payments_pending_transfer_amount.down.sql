@@ -0,0 +1 @@
+DROP INDEX IF EXISTS payments_pending_transfer_amount;
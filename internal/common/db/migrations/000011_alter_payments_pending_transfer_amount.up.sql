@@ -0,0 +1,3 @@
+-- pending bank transfers are matched to statement credits by their amount
+CREATE UNIQUE INDEX IF NOT EXISTS payments_pending_transfer_amount
+	ON payments (amount) WHERE provider = 'transfer' AND status = 'pending';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS user_transactions_pending_expires_at;
+
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS expired_at;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,6 @@
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS expired_at TIMESTAMP;
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS status_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS user_transactions_pending_expires_at
+	ON user_transactions (expires_at) WHERE status = 'pending';
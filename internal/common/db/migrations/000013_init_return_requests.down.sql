@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS return_requests_disputed;
+DROP INDEX IF EXISTS return_requests_seller_id;
+DROP INDEX IF EXISTS return_requests_buyer_id;
+
+DROP TABLE IF EXISTS return_requests;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS return_requests (
+	id SERIAL PRIMARY KEY,
+	uid UUID NOT NULL DEFAULT gen_random_uuid(),
+	transaction_id INT NOT NULL,
+	buyer_id INT NOT NULL,
+	seller_id INT NOT NULL,
+	reason TEXT NOT NULL,
+	evidence_image_urls text[] NOT NULL DEFAULT '{}',
+	status VARCHAR(20) NOT NULL,
+	seller_note TEXT,
+	buyer_note TEXT,
+	admin_note TEXT,
+	created_at TIMESTAMP DEFAULT current_timestamp,
+	updated_at TIMESTAMP DEFAULT current_timestamp
+);
+
+ALTER TABLE return_requests DROP CONSTRAINT IF EXISTS return_request_uid_unique;
+ALTER TABLE return_requests DROP CONSTRAINT IF EXISTS return_request_transaction_id_unique;
+ALTER TABLE return_requests DROP CONSTRAINT IF EXISTS fk_transaction_id;
+
+ALTER TABLE return_requests
+	ADD CONSTRAINT return_request_uid_unique UNIQUE (uid);
+-- a purchase can only be returned once
+ALTER TABLE return_requests
+	ADD CONSTRAINT return_request_transaction_id_unique UNIQUE (transaction_id);
+ALTER TABLE return_requests
+	ADD CONSTRAINT fk_transaction_id FOREIGN KEY (transaction_id) REFERENCES user_transactions(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS return_requests_buyer_id
+	ON return_requests (buyer_id);
+CREATE INDEX IF NOT EXISTS return_requests_seller_id
+	ON return_requests (seller_id);
+CREATE INDEX IF NOT EXISTS return_requests_disputed
+	ON return_requests (created_at) WHERE status = 'disputed';
@@ -0,0 +1,11 @@
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS received_at;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS shipped_at;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS tracking_number;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS courier;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS shipping_status;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS shipping_fee;
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS shipping_address;
+
+DROP INDEX IF EXISTS addresses_user_id;
+
+DROP TABLE IF EXISTS addresses;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS addresses (
+	id SERIAL PRIMARY KEY,
+	uid UUID NOT NULL DEFAULT gen_random_uuid(),
+	user_id INT NOT NULL,
+	label VARCHAR(30) NOT NULL DEFAULT '',
+	recipient_name VARCHAR(50) NOT NULL,
+	phone VARCHAR(20) NOT NULL,
+	street VARCHAR(200) NOT NULL,
+	city VARCHAR(50) NOT NULL,
+	province VARCHAR(50) NOT NULL,
+	postal_code VARCHAR(10) NOT NULL,
+	created_at TIMESTAMP DEFAULT current_timestamp
+);
+
+ALTER TABLE addresses DROP CONSTRAINT IF EXISTS fk_user_id;
+ALTER TABLE addresses DROP CONSTRAINT IF EXISTS address_uid_unique;
+
+ALTER TABLE addresses
+	ADD CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE addresses
+	ADD CONSTRAINT address_uid_unique UNIQUE (uid);
+
+CREATE INDEX IF NOT EXISTS addresses_user_id
+	ON addresses (user_id);
+
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS shipping_address JSONB;
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS shipping_fee INT NOT NULL DEFAULT 0;
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS shipping_status VARCHAR(20);
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS courier VARCHAR(30);
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS tracking_number VARCHAR(50);
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS shipped_at TIMESTAMP;
+ALTER TABLE user_transactions ADD COLUMN IF NOT EXISTS received_at TIMESTAMP;
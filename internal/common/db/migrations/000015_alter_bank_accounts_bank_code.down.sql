@@ -0,0 +1,2 @@
+ALTER TABLE bank_accounts
+	DROP COLUMN IF EXISTS bank_code;
@@ -0,0 +1,6 @@
+ALTER TABLE bank_accounts
+	ADD COLUMN IF NOT EXISTS bank_code VARCHAR(20);
+
+ALTER TABLE bank_accounts
+	ALTER COLUMN name TYPE VARCHAR(50),
+	ALTER COLUMN account_number TYPE VARCHAR(20);
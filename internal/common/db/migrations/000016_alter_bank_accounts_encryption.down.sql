@@ -0,0 +1,6 @@
+-- Rows encrypted since the up migration lose their account numbers; decrypt them first.
+DROP INDEX IF EXISTS bank_accounts_account_number_key_id;
+
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS account_number_key_id;
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS account_number_data_key;
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS account_number_ciphertext;
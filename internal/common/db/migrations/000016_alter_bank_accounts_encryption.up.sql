@@ -0,0 +1,6 @@
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS account_number_ciphertext BYTEA;
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS account_number_data_key BYTEA;
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS account_number_key_id VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS bank_accounts_account_number_key_id
+	ON bank_accounts (account_number_key_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS bank_accounts_user_id_primary;
+
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS is_primary;
@@ -0,0 +1,16 @@
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS is_primary BOOLEAN NOT NULL DEFAULT false;
+
+-- the oldest account of every user starts as primary
+UPDATE bank_accounts b
+SET is_primary = true
+WHERE b.id = (
+	SELECT MIN(id) FROM bank_accounts
+	WHERE user_id = b.user_id
+)
+AND NOT EXISTS (
+	SELECT 1 FROM bank_accounts
+	WHERE user_id = b.user_id AND is_primary
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS bank_accounts_user_id_primary
+	ON bank_accounts (user_id) WHERE is_primary;
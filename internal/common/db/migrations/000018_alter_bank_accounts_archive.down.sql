@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS user_transactions_bank_account_id;
+
+ALTER TABLE user_transactions DROP CONSTRAINT IF EXISTS fk_bank_account_id;
+
+ALTER TABLE user_transactions
+	ADD CONSTRAINT fk_bank_account_id FOREIGN KEY (bank_account_id) REFERENCES bank_accounts(uid) ON DELETE CASCADE;
+
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,10 @@
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+-- bank accounts with purchases are archived instead of deleted, never cascade
+ALTER TABLE user_transactions DROP CONSTRAINT IF EXISTS fk_bank_account_id;
+
+ALTER TABLE user_transactions
+	ADD CONSTRAINT fk_bank_account_id FOREIGN KEY (bank_account_id) REFERENCES bank_accounts(uid) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS user_transactions_bank_account_id
+	ON user_transactions (bank_account_id);
@@ -0,0 +1,5 @@
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS verified_at;
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS verification_expires_at;
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS verification_attempts;
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS verification_amounts;
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS verification_status;
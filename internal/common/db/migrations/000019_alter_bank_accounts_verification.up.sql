@@ -0,0 +1,7 @@
+-- accounts created before verification existed stay trusted
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS verification_status VARCHAR(20) NOT NULL DEFAULT 'verified';
+ALTER TABLE bank_accounts ALTER COLUMN verification_status SET DEFAULT 'unverified';
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS verification_amounts INT[];
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS verification_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS verification_expires_at TIMESTAMP;
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
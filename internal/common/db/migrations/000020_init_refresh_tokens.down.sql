@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS refresh_tokens_family_id;
+DROP INDEX IF EXISTS refresh_tokens_token_hash;
+
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id SERIAL PRIMARY KEY,
+	family_id UUID NOT NULL,
+	user_id INT NOT NULL,
+	token_hash BYTEA NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT current_timestamp
+);
+
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS fk_user_id;
+
+ALTER TABLE refresh_tokens
+	ADD CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS refresh_tokens_token_hash
+	ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id
+	ON refresh_tokens (family_id);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS sessions (
+	id UUID PRIMARY KEY,
+	user_id INT NOT NULL,
+	device VARCHAR(100) NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_address VARCHAR(45) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	last_seen_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	revoked_at TIMESTAMP
+);
+
+ALTER TABLE sessions DROP CONSTRAINT IF EXISTS fk_user_id;
+
+ALTER TABLE sessions
+	ADD CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS sessions_user_id
+	ON sessions (user_id);
+
+-- logins from before sessions existed keep working through their refresh tokens
+INSERT INTO sessions (id, user_id, created_at, last_seen_at)
+SELECT family_id, user_id, MIN(created_at), MAX(created_at)
+FROM refresh_tokens
+WHERE revoked_at IS NULL
+GROUP BY family_id, user_id
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS audit_entries;
+
+DROP INDEX IF EXISTS products_listed;
+
+ALTER TABLE products
+	DROP COLUMN IF EXISTS moderation_status,
+	DROP COLUMN IF EXISTS moderation_reason,
+	DROP COLUMN IF EXISTS moderated_at;
+
+DROP TYPE IF EXISTS product_moderation_status;
+
+ALTER TABLE users
+	DROP COLUMN IF EXISTS suspended_at,
+	DROP COLUMN IF EXISTS suspension_reason;
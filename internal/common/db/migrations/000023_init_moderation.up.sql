@@ -0,0 +1,31 @@
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP,
+	ADD COLUMN IF NOT EXISTS suspension_reason TEXT NOT NULL DEFAULT '';
+
+DROP TYPE IF EXISTS product_moderation_status;
+CREATE TYPE product_moderation_status AS ENUM ('listed', 'unlisted', 'removed');
+
+ALTER TABLE products
+	ADD COLUMN IF NOT EXISTS moderation_status product_moderation_status NOT NULL DEFAULT 'listed',
+	ADD COLUMN IF NOT EXISTS moderation_reason TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS products_listed
+	ON products (moderation_status) WHERE moderation_status = 'listed';
+
+CREATE TABLE IF NOT EXISTS audit_entries (
+	id SERIAL PRIMARY KEY,
+	actor_id INT NOT NULL,
+	action VARCHAR(50) NOT NULL,
+	target VARCHAR(100) NOT NULL DEFAULT '',
+	reason TEXT NOT NULL DEFAULT '',
+	status_code INT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS audit_entries_actor_id
+	ON audit_entries (actor_id);
+CREATE INDEX IF NOT EXISTS audit_entries_target
+	ON audit_entries (target);
+CREATE INDEX IF NOT EXISTS audit_entries_created_at
+	ON audit_entries (created_at DESC);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS login_failures (
+	key VARCHAR(100) PRIMARY KEY,
+	failures INT NOT NULL,
+	last_failed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_last_failed_at
+	ON login_failures (last_failed_at);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS user_tokens;
+
+DROP TYPE IF EXISTS user_token_purpose;
+
+DROP INDEX IF EXISTS users_email;
+
+ALTER TABLE users
+	DROP COLUMN IF EXISTS email,
+	DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,27 @@
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS email VARCHAR(254),
+	ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email
+	ON users (lower(email));
+
+DROP TYPE IF EXISTS user_token_purpose;
+CREATE TYPE user_token_purpose AS ENUM ('email_verification', 'password_reset');
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	purpose user_token_purpose NOT NULL,
+	token_hash BYTEA NOT NULL UNIQUE,
+	email VARCHAR(254) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	CONSTRAINT fk_user_id
+		FOREIGN KEY (user_id)
+		REFERENCES users(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_id
+	ON user_tokens (user_id, purpose);
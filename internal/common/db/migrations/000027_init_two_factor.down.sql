@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS user_recovery_codes;
+
+DROP TABLE IF EXISTS user_totp;
+
+ALTER TABLE sessions
+	DROP COLUMN IF EXISTS second_factor_at;
+
+-- enum values cannot be dropped, unused challenge tokens are removed instead
+DELETE FROM user_tokens WHERE purpose = 'login_challenge';
@@ -0,0 +1,33 @@
+ALTER TYPE user_token_purpose ADD VALUE IF NOT EXISTS 'login_challenge';
+
+ALTER TABLE sessions
+	ADD COLUMN IF NOT EXISTS second_factor_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS user_totp (
+	user_id INT PRIMARY KEY,
+	secret_ciphertext BYTEA NOT NULL,
+	secret_data_key BYTEA NOT NULL,
+	secret_key_id VARCHAR(64) NOT NULL,
+	confirmed_at TIMESTAMP,
+	last_used_step BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	CONSTRAINT fk_user_id
+		FOREIGN KEY (user_id)
+		REFERENCES users(id)
+		ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	code_hash BYTEA NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	CONSTRAINT fk_user_id
+		FOREIGN KEY (user_id)
+		REFERENCES users(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_recovery_codes_user_id
+	ON user_recovery_codes (user_id);
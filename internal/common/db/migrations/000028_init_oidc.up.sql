@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+	provider VARCHAR(64) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	user_id INT NOT NULL,
+	email VARCHAR(254),
+	created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	last_login_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	PRIMARY KEY (provider, subject),
+	CONSTRAINT fk_user_id
+		FOREIGN KEY (user_id)
+		REFERENCES users(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id
+	ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+	state_hash BYTEA PRIMARY KEY,
+	provider VARCHAR(64) NOT NULL,
+	code_verifier VARCHAR(128) NOT NULL,
+	nonce VARCHAR(128) NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,2 @@
+-- deleted users are anonymized instead of removed, purchases of others refer to them
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
@@ -0,0 +1,19 @@
+DROP INDEX IF EXISTS bank_accounts_shop_id_primary;
+
+-- keep the oldest primary account of every user when shops are merged back
+UPDATE bank_accounts b
+SET is_primary = false
+WHERE is_primary
+AND EXISTS (
+	SELECT 1 FROM bank_accounts
+	WHERE user_id = b.user_id AND is_primary AND id < b.id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS bank_accounts_user_id_primary
+	ON bank_accounts (user_id) WHERE is_primary;
+
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS shop_id;
+ALTER TABLE products DROP COLUMN IF EXISTS shop_id;
+
+DROP TABLE IF EXISTS shop_members;
+DROP TABLE IF EXISTS shops;
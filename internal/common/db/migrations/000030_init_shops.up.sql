@@ -0,0 +1,84 @@
+CREATE TABLE IF NOT EXISTS shops (
+	id SERIAL PRIMARY KEY,
+	uid UUID NOT NULL DEFAULT gen_random_uuid() UNIQUE,
+	name VARCHAR(50) NOT NULL,
+	owner_id INT NOT NULL,
+	is_default BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	CONSTRAINT fk_owner_id
+		FOREIGN KEY (owner_id)
+		REFERENCES users(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS shops_owner_id
+	ON shops (owner_id);
+-- every seller has one default shop, used when no shop is picked
+CREATE UNIQUE INDEX IF NOT EXISTS shops_owner_id_default
+	ON shops (owner_id) WHERE is_default;
+
+CREATE TABLE IF NOT EXISTS shop_members (
+	shop_id INT NOT NULL,
+	user_id INT NOT NULL,
+	permissions TEXT[] NOT NULL DEFAULT '{}',
+	status VARCHAR(20) NOT NULL DEFAULT 'invited',
+	invited_by INT,
+	invited_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+	joined_at TIMESTAMP,
+	PRIMARY KEY (shop_id, user_id),
+	CONSTRAINT fk_shop_id
+		FOREIGN KEY (shop_id)
+		REFERENCES shops(id)
+		ON DELETE CASCADE,
+	CONSTRAINT fk_user_id
+		FOREIGN KEY (user_id)
+		REFERENCES users(id)
+		ON DELETE CASCADE,
+	CONSTRAINT fk_invited_by
+		FOREIGN KEY (invited_by)
+		REFERENCES users(id)
+		ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS shop_members_user_id
+	ON shop_members (user_id);
+
+-- existing sellers get a default shop named after them holding their products and accounts
+INSERT INTO shops (name, owner_id, is_default)
+SELECT u.name, u.id, true
+FROM users u
+WHERE EXISTS (SELECT 1 FROM products WHERE user_id = u.id)
+OR EXISTS (SELECT 1 FROM bank_accounts WHERE user_id = u.id)
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS shop_id INT;
+UPDATE products p
+SET shop_id = s.id
+FROM shops s
+WHERE s.owner_id = p.user_id AND s.is_default AND p.shop_id IS NULL;
+ALTER TABLE products ALTER COLUMN shop_id SET NOT NULL;
+
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS shop_id INT;
+UPDATE bank_accounts b
+SET shop_id = s.id
+FROM shops s
+WHERE s.owner_id = b.user_id AND s.is_default AND b.shop_id IS NULL;
+ALTER TABLE bank_accounts ALTER COLUMN shop_id SET NOT NULL;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS fk_shop_id;
+ALTER TABLE bank_accounts DROP CONSTRAINT IF EXISTS fk_shop_id;
+
+ALTER TABLE products
+	ADD CONSTRAINT fk_shop_id FOREIGN KEY (shop_id) REFERENCES shops(id) ON DELETE CASCADE;
+ALTER TABLE bank_accounts
+	ADD CONSTRAINT fk_shop_id FOREIGN KEY (shop_id) REFERENCES shops(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS products_shop_id
+	ON products (shop_id);
+CREATE INDEX IF NOT EXISTS bank_accounts_shop_id
+	ON bank_accounts (shop_id);
+
+-- payouts go to the primary account of the shop the product belongs to
+DROP INDEX IF EXISTS bank_accounts_user_id_primary;
+CREATE UNIQUE INDEX IF NOT EXISTS bank_accounts_shop_id_primary
+	ON bank_accounts (shop_id) WHERE is_primary;
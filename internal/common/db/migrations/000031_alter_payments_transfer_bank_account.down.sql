@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS payments_pending_transfer_bank_account_amount;
+CREATE UNIQUE INDEX IF NOT EXISTS payments_pending_transfer_amount
+	ON payments (amount) WHERE provider = 'transfer' AND status = 'pending';
+
+ALTER TABLE payments DROP COLUMN IF EXISTS bank_account_id;
@@ -0,0 +1,14 @@
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS bank_account_id UUID;
+
+UPDATE payments p
+SET bank_account_id = t.bank_account_id
+FROM user_transactions t
+WHERE t.id = p.transaction_id
+AND p.provider = 'transfer'
+AND p.bank_account_id IS NULL;
+
+DROP INDEX IF EXISTS payments_pending_transfer_amount;
+-- pending bank transfers are matched to the credits on the statement of the bank
+-- account they are paid into by their amount
+CREATE UNIQUE INDEX IF NOT EXISTS payments_pending_transfer_bank_account_amount
+	ON payments (bank_account_id, amount) WHERE provider = 'transfer' AND status = 'pending';
@@ -0,0 +1,3 @@
+-- verifications started for the account, it is not reset so deposits cannot be
+-- requested without limit
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS verification_rounds INT NOT NULL DEFAULT 0;
@@ -2,55 +2,71 @@ package jwt
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
-	key = []byte(os.Getenv("JWT_SECRET"))
+	// keys default to the HS256 JWT_SECRET, see SetKeySet.
+	keys = NewSecretKeySet([]byte(os.Getenv("JWT_SECRET")))
 
 	ErrUnknownClaims = errors.New("unknown claims type")
 	ErrTokenInvalid  = errors.New("invalid token")
 )
 
-func Sign(ttl time.Duration, subject string) (string, error) {
+// Claims are the claims of an access token. ID is unique per token, SessionID
+// names the login session the token was issued for and Roles are the roles of the
+// user when it was issued.
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID string   `json:"sid"`
+	Roles     []string `json:"roles"`
+}
+
+// SetKeySet replaces the keys tokens are signed and verified with.
+func SetKeySet(ks *KeySet) {
+	keys = ks
+}
+
+func Sign(ttl time.Duration, subject string, sessionID string, roles []string) (string, error) {
 	now := time.Now()
 	expiry := now.Add(ttl)
-	t := jwt.NewWithClaims(
-		jwt.SigningMethodHS256,
-		jwt.RegisteredClaims{
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(expiry),
 			Subject:   subject,
 		},
-	)
-	return t.SignedString(key)
+		SessionID: sessionID,
+		Roles:     roles,
+	}
+	if keys.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(keys.secret)
+	}
+	t := jwt.NewWithClaims(keys.signing.Method, claims)
+	t.Header["kid"] = keys.signing.ID
+	return t.SignedString(keys.signing.PrivateKey)
 }
 
-func VerifyAndGetSubject(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-
-		return key, nil
-	})
+func Verify(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyFunc)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Checking token validity
 	if !token.Valid {
-		return "", ErrTokenInvalid
+		return nil, ErrTokenInvalid
 	}
 
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok {
-		return claims.Subject, nil
+	if claims, ok := token.Claims.(*Claims); ok {
+		return claims, nil
 	} else {
-		return "", ErrUnknownClaims
+		return nil, ErrUnknownClaims
 	}
 }
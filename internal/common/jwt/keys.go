@@ -0,0 +1,172 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a key pair used to sign or verify tokens. Verification only keys have no
+// private key.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// KeySet signs tokens with one key and verifies them with any key of the set, so a
+// new key can be published before it signs anything and an old one kept until the
+// tokens it signed have expired.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	// secret verifies and, without a signing key, signs HS256 tokens without kid.
+	secret []byte
+}
+
+// NewKeySet signs with the key named signingKeyID, which needs a private key.
+func NewKeySet(keys []*Key, signingKeyID string) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		if _, ok := ks.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+	signing, ok := ks.keys[signingKeyID]
+	if !ok || signing.PrivateKey == nil {
+		return nil, fmt.Errorf("%w: no private key for signing key %q", ErrUnknownKey, signingKeyID)
+	}
+	ks.signing = signing
+	return ks, nil
+}
+
+// NewSecretKeySet signs and verifies HS256 tokens with a shared secret.
+func NewSecretKeySet(secret []byte) *KeySet {
+	return &KeySet{keys: map[string]*Key{}, secret: secret}
+}
+
+// WithSecret keeps verifying HS256 tokens signed with secret, e.g. while switching
+// from a shared secret to key pairs.
+func (ks *KeySet) WithSecret(secret []byte) *KeySet {
+	ks.secret = secret
+	return ks
+}
+
+// LoadKeySet reads PEM encoded keys from comma separated "<kid>:<path>" lists.
+// privateKeys hold PKCS#8 (or PKCS#1 RSA) private keys, publicKeys hold PKIX
+// public keys that are only used for verification.
+func LoadKeySet(privateKeys, publicKeys, signingKeyID string) (*KeySet, error) {
+	var keys []*Key
+	for _, spec := range []struct {
+		list    string
+		private bool
+	}{{privateKeys, true}, {publicKeys, false}} {
+		for _, pair := range strings.Split(spec.list, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			id, path, ok := strings.Cut(pair, ":")
+			if !ok || id == "" || path == "" {
+				return nil, fmt.Errorf("invalid key %q, expected <kid>:<path>", pair)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			var k *Key
+			if spec.private {
+				k, err = ParsePrivateKey(id, data)
+			} else {
+				k, err = ParsePublicKey(id, data)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", id, err)
+			}
+			keys = append(keys, k)
+		}
+	}
+	return NewKeySet(keys, signingKeyID)
+}
+
+// ParsePrivateKey reads an RSA or Ed25519 private key, signing with RS256 or EdDSA.
+func ParsePrivateKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	var private any
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+	k, err := newKey(id, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	k.PrivateKey = signer
+	return k, nil
+}
+
+func ParsePublicKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(id, public)
+}
+
+func newKey(id string, public crypto.PublicKey) (*Key, error) {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, PublicKey: public}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, PublicKey: public}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", public)
+	}
+}
+
+// keyFunc picks the verification key named by the kid header of token.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(ks.secret) == 0 {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return ks.secret, nil
+	}
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return k.PublicKey, nil
+}
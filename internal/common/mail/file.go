@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSender writes every message to its own file in Dir, for local development.
+type FileSender struct {
+	Dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func NewFileSender(dir string) (*FileSender, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSender{Dir: dir}, nil
+}
+
+// Send implements Sender.
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return ErrNoRecipient
+	}
+	s.mu.Lock()
+	s.seq++
+	name := fmt.Sprintf("%s-%03d.txt", time.Now().UTC().Format("20060102T150405"), s.seq)
+	s.mu.Unlock()
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	// messages carry one-time tokens, only the owner may read them
+	return os.WriteFile(filepath.Join(s.Dir, name), []byte(content), 0o600)
+}
+
+// LogSender only logs the messages, for local development.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send implements Sender.
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return ErrNoRecipient
+	}
+	slog.Info(fmt.Sprintf("mail to %s: %s\n%s", msg.To, msg.Subject, strings.TrimSpace(msg.Body)))
+	return nil
+}
@@ -0,0 +1,20 @@
+// Package mail sends email to users.
+package mail
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNoRecipient = errors.New("mail has no recipient")
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages. Implementations are safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
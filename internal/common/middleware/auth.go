@@ -1,16 +1,17 @@
 package middleware
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 
-	"github.com/citadel-corp/shopifyx-marketplace/internal/common/jwt"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/scope"
 )
 
-type ContextAuthKey struct{}
-
-func Authorized(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+// Authorized requires an access token or an API token with any of scopes. Routes
+// without scopes only accept access tokens.
+func Authorized(next func(w http.ResponseWriter, r *http.Request), scopes ...scope.Scope) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
@@ -34,22 +35,31 @@ func Authorized(next func(w http.ResponseWriter, r *http.Request)) func(w http.R
 			return
 		}
 
-		subject, err := jwt.VerifyAndGetSubject(tokenString)
+		principal, err := verifyToken(r.Context(), tokenString)
+		if errors.Is(err, ErrUserSuspended) {
+			w.WriteHeader(http.StatusForbidden)
+			slog.InfoContext(r.Context(), "Suspended user")
+			return
+		}
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
-			slog.InfoContext(r.Context(), "Invalid token: %v", err)
+			slog.InfoContext(r.Context(), fmt.Sprintf("Invalid token: %v", err))
+			return
+		}
+		if !principal.HasScope(scopes...) {
+			w.WriteHeader(http.StatusForbidden)
+			slog.InfoContext(r.Context(), ErrMissingScope.Error())
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), ContextAuthKey{}, subject)
-		r = r.WithContext(ctx)
+		r = r.WithContext(withPrincipal(r.Context(), principal))
 
 		next(w, r)
 	}
 }
 
-// Authenticate request only if authorization header is set
-func Authenticate(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+// Authenticate request only if authorization header is set, API tokens need any of scopes
+func Authenticate(next func(w http.ResponseWriter, r *http.Request), scopes ...scope.Scope) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
@@ -70,15 +80,24 @@ func Authenticate(next func(w http.ResponseWriter, r *http.Request)) func(w http
 			return
 		}
 
-		subject, err := jwt.VerifyAndGetSubject(tokenString)
+		principal, err := verifyToken(r.Context(), tokenString)
+		if errors.Is(err, ErrUserSuspended) {
+			w.WriteHeader(http.StatusForbidden)
+			slog.InfoContext(r.Context(), "Suspended user")
+			return
+		}
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
-			slog.InfoContext(r.Context(), "Invalid token: %v", err)
+			slog.InfoContext(r.Context(), fmt.Sprintf("Invalid token: %v", err))
+			return
+		}
+		if !principal.HasScope(scopes...) {
+			w.WriteHeader(http.StatusForbidden)
+			slog.InfoContext(r.Context(), ErrMissingScope.Error())
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), ContextAuthKey{}, subject)
-		r = r.WithContext(ctx)
+		r = r.WithContext(withPrincipal(r.Context(), principal))
 
 		next(w, r)
 	}
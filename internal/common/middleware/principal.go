@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/scope"
+)
+
+var ErrUnauthenticated = errors.New("request is not authenticated")
+
+// Principal is the authenticated user a request is made for. Requests with an API
+// token have its ID and scopes and no session.
+type Principal struct {
+	UserID     uint64
+	SessionID  string
+	Roles      []role.Role
+	APITokenID uint64
+	Scopes     []scope.Scope
+}
+
+// IsAPIToken reports whether the request was made with an API token instead of a login.
+func (p *Principal) IsAPIToken() bool {
+	return p.APITokenID != 0
+}
+
+// HasScope reports whether the principal may use a route that requires any of scopes.
+// Logins have every scope.
+func (p *Principal) HasScope(scopes ...scope.Scope) bool {
+	if !p.IsAPIToken() {
+		return true
+	}
+	for _, s := range scopes {
+		if slices.Contains(p.Scopes, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the principal has any of roles.
+func (p *Principal) HasRole(roles ...role.Role) bool {
+	for _, r := range roles {
+		if slices.Contains(p.Roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+type contextPrincipalKey struct{}
+
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextPrincipalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal set by Authorized or Authenticate.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextPrincipalKey{}).(*Principal)
+	return p, ok
+}
+
+// GetPrincipal returns ErrUnauthenticated when the request carries no valid token.
+func GetPrincipal(r *http.Request) (*Principal, error) {
+	p, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return p, nil
+}
+
+// GetUserID returns the ID of the authenticated user, or ErrUnauthenticated.
+func GetUserID(r *http.Request) (uint64, error) {
+	p, err := GetPrincipal(r)
+	if err != nil {
+		return 0, err
+	}
+	return p.UserID, nil
+}
+
+func principalFromSubject(subject, sessionID string, roles []string) (*Principal, error) {
+	userID, err := strconv.ParseUint(subject, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.New("invalid token subject")
+	}
+	p := &Principal{
+		UserID:    userID,
+		SessionID: sessionID,
+		Roles:     role.FromStrings(roles),
+	}
+	// tokens issued before roles were added carry no roles claim at all
+	if roles == nil {
+		p.Roles = role.Default
+	}
+	return p, nil
+}
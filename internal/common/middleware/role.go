@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+)
+
+// RequireRole only lets through principals with any of roles. It runs inside
+// Authorized, e.g. Authorized(RequireRole(role.Admin)(handler)).
+func RequireRole(roles ...role.Role) func(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			p, err := GetPrincipal(r)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				slog.InfoContext(r.Context(), "Missing principal")
+				return
+			}
+			if !p.HasRole(roles...) {
+				w.WriteHeader(http.StatusForbidden)
+				slog.InfoContext(r.Context(), "Missing role")
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
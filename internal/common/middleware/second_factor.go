@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
+)
+
+var ErrSecondFactorRequired = errors.New("a fresh second factor is required, verify a code first")
+
+// SecondFactorChecker reports whether the session gave a second factor within maxAge.
+// Users without a second factor pass.
+type SecondFactorChecker interface {
+	HasFreshSecondFactor(ctx context.Context, userID uint64, sessionID string, maxAge time.Duration) (bool, error)
+}
+
+var secondFactorChecker SecondFactorChecker
+
+// SetSecondFactorChecker sets the checker used by RequireFreshSecondFactor. Without
+// one, every request passes.
+func SetSecondFactorChecker(checker SecondFactorChecker) {
+	secondFactorChecker = checker
+}
+
+// RequireFreshSecondFactor only lets through sessions that gave a second factor within
+// maxAge. It runs inside Authorized; the client answers 403 by verifying a code and
+// retrying.
+func RequireFreshSecondFactor(maxAge time.Duration) func(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			p, err := GetPrincipal(r)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				slog.InfoContext(r.Context(), "Missing principal")
+				return
+			}
+			if secondFactorChecker != nil {
+				fresh, err := secondFactorChecker.HasFreshSecondFactor(r.Context(), p.UserID, p.SessionID, maxAge)
+				if err != nil {
+					slog.Error(fmt.Sprintf("cannot check second factor of user %d: %v", p.UserID, err))
+					response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+					return
+				}
+				if !fresh {
+					response.JSON(w, http.StatusForbidden, response.ResponseBody{
+						Message: "Forbidden",
+						Error:   ErrSecondFactorRequired.Error(),
+					})
+					return
+				}
+			}
+
+			next(w, r)
+		}
+	}
+}
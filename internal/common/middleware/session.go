@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/jwt"
+)
+
+var (
+	ErrSessionRevoked = errors.New("session has been revoked")
+	ErrUserSuspended  = errors.New("user is suspended")
+	ErrMissingScope   = errors.New("token is missing the scope of this route")
+)
+
+// APITokenPrefix starts every API token, so they are told apart from access tokens
+// without parsing them.
+const APITokenPrefix = "shpx_"
+
+// SessionChecker reports whether a login session was revoked. Access tokens of a
+// revoked session are refused even if they have not expired yet.
+type SessionChecker interface {
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+var sessionChecker SessionChecker
+
+// SetSessionChecker sets the checker used by Authorized and Authenticate. Without
+// one, tokens are only checked for their signature and expiry.
+func SetSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
+// SuspensionChecker reports whether a user was suspended by an admin. Requests of
+// suspended users are refused with 403.
+type SuspensionChecker interface {
+	IsUserSuspended(ctx context.Context, userID uint64) (bool, error)
+}
+
+var suspensionChecker SuspensionChecker
+
+// SetSuspensionChecker sets the checker used by Authorized and Authenticate.
+func SetSuspensionChecker(checker SuspensionChecker) {
+	suspensionChecker = checker
+}
+
+// APITokenVerifier resolves API tokens, which are accepted in place of access tokens.
+type APITokenVerifier interface {
+	VerifyAPIToken(ctx context.Context, token string) (*Principal, error)
+}
+
+var apiTokenVerifier APITokenVerifier
+
+// SetAPITokenVerifier sets the verifier used by Authorized and Authenticate. Without
+// one, API tokens are refused.
+func SetAPITokenVerifier(verifier APITokenVerifier) {
+	apiTokenVerifier = verifier
+}
+
+func verifyToken(ctx context.Context, tokenString string) (*Principal, error) {
+	var principal *Principal
+	var err error
+	if strings.HasPrefix(tokenString, APITokenPrefix) {
+		principal, err = verifyAPIToken(ctx, tokenString)
+	} else {
+		principal, err = verifyAccessToken(ctx, tokenString)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if suspensionChecker != nil {
+		suspended, err := suspensionChecker.IsUserSuspended(ctx, principal.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if suspended {
+			return nil, ErrUserSuspended
+		}
+	}
+	return principal, nil
+}
+
+func verifyAPIToken(ctx context.Context, tokenString string) (*Principal, error) {
+	if apiTokenVerifier == nil {
+		return nil, jwt.ErrTokenInvalid
+	}
+	return apiTokenVerifier.VerifyAPIToken(ctx, tokenString)
+}
+
+func verifyAccessToken(ctx context.Context, tokenString string) (*Principal, error) {
+	claims, err := jwt.Verify(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, jwt.ErrTokenInvalid
+	}
+	principal, err := principalFromSubject(claims.Subject, claims.SessionID, claims.Roles)
+	if err != nil {
+		return nil, err
+	}
+	if sessionChecker != nil {
+		revoked, err := sessionChecker.IsSessionRevoked(ctx, claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrSessionRevoked
+		}
+	}
+	return principal, nil
+}
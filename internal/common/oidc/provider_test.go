@@ -0,0 +1,187 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost/callback"
+
+// startLogin starts a provider and signs in at it, returning the code the provider
+// redirected back with and the PKCE verifier it was requested with.
+func startLogin(t *testing.T, server *oidctest.Server, nonce string) (provider *oidc.Provider, code, verifier string) {
+	t.Helper()
+	provider = oidc.NewProvider(server.Config("test", redirectURL), nil)
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", nonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := authorize(t, authURL)
+	if state != "state" {
+		t.Fatalf("state = %q, want %q", state, "state")
+	}
+	return provider, code, verifier
+}
+
+// authorize follows authURL and returns the code and state of the redirect back.
+func authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", res.StatusCode, http.StatusFound)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func startServer(t *testing.T) *oidctest.Server {
+	t.Helper()
+	server, err := oidctest.Start("client", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExchange(t *testing.T) {
+	server := startServer(t)
+	server.SetUser(oidctest.User{
+		Subject:       "subject",
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Name:          "Test User",
+	})
+	provider, code, verifier := startLogin(t, server, "nonce")
+
+	claims, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "subject" || claims.Email != "user@example.com" || !bool(claims.EmailVerified) {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestExchangePKCEMismatch(t *testing.T) {
+	server := startServer(t)
+	provider, code, _ := startLogin(t, server, "nonce")
+	otherVerifier, _, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = provider.Exchange(context.Background(), code, otherVerifier, "nonce")
+	if !errors.Is(err, oidc.ErrExchangeFailed) {
+		t.Errorf("err = %v, want %v", err, oidc.ErrExchangeFailed)
+	}
+}
+
+func TestExchangeCodeReuse(t *testing.T) {
+	server := startServer(t)
+	provider, code, verifier := startLogin(t, server, "nonce")
+
+	_, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.Exchange(context.Background(), code, verifier, "nonce")
+	if !errors.Is(err, oidc.ErrExchangeFailed) {
+		t.Errorf("err = %v, want %v", err, oidc.ErrExchangeFailed)
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	server := startServer(t)
+	provider, code, verifier := startLogin(t, server, "nonce")
+
+	_, err := provider.Exchange(context.Background(), code, verifier, "other nonce")
+	if !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("err = %v, want %v", err, oidc.ErrInvalidIDToken)
+	}
+}
+
+func TestExchangeRejectsIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]any
+	}{
+		{name: "other audience", claims: map[string]any{"aud": "other-client"}},
+		{name: "several audiences without authorized party", claims: map[string]any{"aud": []string{"client", "other-client"}}},
+		{name: "other authorized party", claims: map[string]any{"azp": "other-client"}},
+		{name: "other issuer", claims: map[string]any{"iss": "https://issuer.example.com"}},
+		{name: "expired", claims: map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{name: "no expiry", claims: map[string]any{"exp": nil}},
+		{name: "issued in the future", claims: map[string]any{"iat": time.Now().Add(time.Hour).Unix()}},
+		{name: "no subject", claims: map[string]any{"sub": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startServer(t)
+			server.SetClaims(tt.claims)
+			provider, code, verifier := startLogin(t, server, "nonce")
+
+			_, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+			if !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("err = %v, want %v", err, oidc.ErrInvalidIDToken)
+			}
+		})
+	}
+}
+
+func TestExchangeAcceptsAuthorizedParty(t *testing.T) {
+	server := startServer(t)
+	server.SetClaims(map[string]any{"aud": []string{"client", "other-client"}, "azp": "client"})
+	provider, code, verifier := startLogin(t, server, "nonce")
+
+	_, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoolClaim(t *testing.T) {
+	tests := []struct {
+		json string
+		want oidc.Bool
+	}{
+		{`true`, true},
+		{`false`, false},
+		{`"true"`, true},
+		{`"TRUE"`, true},
+		{`"false"`, false},
+		{`1`, false},
+	}
+	for _, tt := range tests {
+		var b oidc.Bool
+		if err := b.UnmarshalJSON([]byte(tt.json)); err != nil {
+			t.Fatalf("UnmarshalJSON(%s): %v", tt.json, err)
+		}
+		if b != tt.want {
+			t.Errorf("UnmarshalJSON(%s) = %v, want %v", tt.json, b, tt.want)
+		}
+	}
+}
@@ -2,22 +2,23 @@ package password
 
 import (
 	"errors"
-	"os"
-	"strconv"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-var (
-	costStr = os.Getenv("BCRYPT_SALT")
-)
+// BcryptHasher hashes with bcrypt in its modular crypt format, e.g. "$2a$12$...".
+type BcryptHasher struct {
+	cost int
+}
 
-func Hash(plaintextPassword string) (string, error) {
-	cost, err := strconv.Atoi(costStr)
-	if err != nil {
-		return "", err
-	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), cost)
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{cost: cost}
+}
+
+// Hash implements Hasher.
+func (h *BcryptHasher) Hash(plaintextPassword string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), h.cost)
 	if err != nil {
 		return "", err
 	}
@@ -25,8 +26,9 @@ func Hash(plaintextPassword string) (string, error) {
 	return string(hashedPassword), nil
 }
 
-func Matches(plaintextPassword, hashedPassword string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plaintextPassword))
+// Matches implements Hasher.
+func (h *BcryptHasher) Matches(plaintextPassword, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plaintextPassword))
 	if err != nil {
 		switch {
 		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
@@ -38,3 +40,14 @@ func Matches(plaintextPassword, hashedPassword string) (bool, error) {
 
 	return true, nil
 }
+
+// Identifies implements Hasher.
+func (h *BcryptHasher) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// NeedsRehash implements Hasher.
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
@@ -0,0 +1,74 @@
+package password
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrUnknownHash = errors.New("password hash format is not recognized")
+
+// Hasher hashes passwords into encoded strings that carry the algorithm and its
+// parameters, so hashes made with other parameters can still be verified.
+type Hasher interface {
+	Hash(plaintextPassword string) (string, error)
+	// Matches reports whether plaintextPassword hashes to encoded, which must be
+	// identified by this hasher.
+	Matches(plaintextPassword, encoded string) (bool, error)
+	// Identifies reports whether encoded was made by this kind of hasher.
+	Identifies(encoded string) bool
+	// NeedsRehash reports whether encoded was made with other parameters than the
+	// ones the hasher uses now.
+	NeedsRehash(encoded string) bool
+}
+
+// Manager hashes new passwords with its preferred hasher and verifies passwords
+// hashed by any of its hashers, e.g. bcrypt hashes from before argon2id was used.
+type Manager struct {
+	preferred Hasher
+	hashers   []Hasher
+
+	dummyOnce sync.Once
+	dummyHash string
+	dummyErr  error
+}
+
+func NewManager(preferred Hasher, others ...Hasher) *Manager {
+	return &Manager{
+		preferred: preferred,
+		hashers:   append([]Hasher{preferred}, others...),
+	}
+}
+
+func (m *Manager) Hash(plaintextPassword string) (string, error) {
+	return m.preferred.Hash(plaintextPassword)
+}
+
+// Matches reports whether plaintextPassword is the password of encoded.
+func (m *Manager) Matches(plaintextPassword, encoded string) (bool, error) {
+	for _, h := range m.hashers {
+		if h.Identifies(encoded) {
+			return h.Matches(plaintextPassword, encoded)
+		}
+	}
+	return false, ErrUnknownHash
+}
+
+// NeedsRehash reports whether encoded should be replaced by a hash of the preferred
+// hasher once the password is known.
+func (m *Manager) NeedsRehash(encoded string) bool {
+	return !m.preferred.Identifies(encoded) || m.preferred.NeedsRehash(encoded)
+}
+
+// MatchesNothing takes as long as Matches against a new hash and never matches.
+// Comparing against it for unknown users keeps them from being told apart from
+// existing ones by response time.
+func (m *Manager) MatchesNothing(plaintextPassword string) error {
+	m.dummyOnce.Do(func() {
+		m.dummyHash, m.dummyErr = m.preferred.Hash("not the password of anyone")
+	})
+	if m.dummyErr != nil {
+		return m.dummyErr
+	}
+	_, err := m.preferred.Matches(plaintextPassword, m.dummyHash)
+	return err
+}
@@ -0,0 +1,81 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLength bounds every password so hashing stays cheap, whatever the policy allows.
+const MaxLength = 128
+
+var ErrBreached = errors.New("password appears in a list of breached passwords")
+
+// Policy is what new passwords have to satisfy.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	breached  map[[sha1.Size]byte]struct{}
+}
+
+var policy = Policy{MinLength: 8, MaxLength: 64}
+
+// SetPolicy replaces the policy Check applies.
+func SetPolicy(p Policy) {
+	policy = p
+}
+
+// Check validates a new password against the policy.
+func Check(plaintextPassword string) error {
+	return policy.Check(plaintextPassword)
+}
+
+// Check validates a new password against p.
+func (p Policy) Check(plaintextPassword string) error {
+	length := utf8.RuneCountInString(plaintextPassword)
+	if length < p.MinLength || length > min(p.MaxLength, MaxLength) {
+		return fmt.Errorf("the length must be between %d and %d", p.MinLength, min(p.MaxLength, MaxLength))
+	}
+	if _, ok := p.breached[sha1.Sum([]byte(plaintextPassword))]; ok {
+		return ErrBreached
+	}
+	return nil
+}
+
+// LoadBreachedList makes the policy reject the passwords listed in the file at path.
+// Every line is either a password or, as in the Pwned Passwords downloads, the hex
+// SHA-1 of one optionally followed by ":<count>".
+func (p *Policy) LoadBreachedList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	p.breached = make(map[[sha1.Size]byte]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		p.breached[breachedListEntry(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+func breachedListEntry(line string) [sha1.Size]byte {
+	digest, _, _ := strings.Cut(line, ":")
+	var sum [sha1.Size]byte
+	if len(digest) == hex.EncodedLen(sha1.Size) {
+		if _, err := hex.Decode(sum[:], []byte(digest)); err == nil {
+			return sum
+		}
+	}
+	return sha1.Sum([]byte(line))
+}
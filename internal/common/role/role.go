@@ -0,0 +1,40 @@
+package role
+
+import "slices"
+
+// Role grants a user access to a group of endpoints.
+type Role string
+
+const (
+	Buyer  Role = "buyer"
+	Seller Role = "seller"
+	Admin  Role = "admin"
+)
+
+var All = []Role{Buyer, Seller, Admin}
+
+// Default is what every user gets when registering.
+var Default = []Role{Buyer, Seller}
+
+func (r Role) Valid() bool {
+	return slices.Contains(All, r)
+}
+
+// FromStrings keeps the valid roles of names, e.g. from token claims.
+func FromStrings(names []string) []Role {
+	roles := make([]Role, 0, len(names))
+	for _, name := range names {
+		if r := Role(name); r.Valid() {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+func Strings(roles []Role) []string {
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = string(r)
+	}
+	return names
+}
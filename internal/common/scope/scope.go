@@ -0,0 +1,40 @@
+package scope
+
+import "slices"
+
+// Scope limits what an API token can do. Logins are not limited by scopes, only by
+// roles.
+type Scope string
+
+const (
+	ProductsRead  Scope = "products:read"
+	ProductsWrite Scope = "products:write"
+	StockWrite    Scope = "stock:write"
+	OrdersRead    Scope = "orders:read"
+	OrdersWrite   Scope = "orders:write"
+)
+
+var All = []Scope{ProductsRead, ProductsWrite, StockWrite, OrdersRead, OrdersWrite}
+
+func (s Scope) Valid() bool {
+	return slices.Contains(All, s)
+}
+
+// FromStrings keeps the valid scopes of names.
+func FromStrings(names []string) []Scope {
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		if s := Scope(name); s.Valid() {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func Strings(scopes []Scope) []string {
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = string(s)
+	}
+	return names
+}
@@ -0,0 +1,7 @@
+package export
+
+import "errors"
+
+var (
+	ErrValidationFailed = errors.New("validation failed")
+)
@@ -0,0 +1,73 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
+	bankaccount "github.com/citadel-corp/shopifyx-marketplace/internal/bank_account"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/product"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatZIP  Format = "zip"
+)
+
+var Formats []interface{} = []interface{}{FormatJSON, FormatZIP}
+
+// Archive is the personal data the marketplace keeps of a user, for them to take
+// along. Bank account numbers are masked.
+type Archive struct {
+	ExportedAt   time.Time                          `json:"exportedAt"`
+	Profile      *user.ProfileResponse              `json:"profile"`
+	Addresses    []address.AddressResponse          `json:"addresses"`
+	Products     []product.ProductResponse          `json:"products"`
+	BankAccounts []*bankaccount.BankAccountResponse `json:"bankAccounts"`
+	Purchases    []product.AdminTransactionResponse `json:"purchases"`
+	Sales        []product.AdminTransactionResponse `json:"sales"`
+}
+
+// WriteJSON writes the archive as a single JSON document.
+func (a *Archive) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// WriteZIP writes the archive as a ZIP file with a JSON file per part.
+func (a *Archive) WriteZIP(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", a.Profile},
+		{"addresses.json", a.Addresses},
+		{"products.json", a.Products},
+		{"bank_accounts.json", a.BankAccounts},
+		{"purchases.json", a.Purchases},
+		{"sales.json", a.Sales},
+	}
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: a.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,90 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/middleware"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
+	"github.com/gorilla/schema"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Export downloads the archive of the requesting user, as JSON unless the format
+// query parameter asks for ZIP.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+
+	var req ExportPayload
+	newSchema := schema.NewDecoder()
+	newSchema.IgnoreUnknownKeys(true)
+	if err = newSchema.Decode(&req, r.URL.Query()); err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err = req.Validate(); err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   fmt.Errorf("%w: %w", ErrValidationFailed, err).Error(),
+		})
+		return
+	}
+
+	archive, err := h.service.Export(r.Context(), userID)
+	if errors.Is(err, user.ErrUserNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// written to a buffer first so a failure can still be answered with an error
+	var buf bytes.Buffer
+	contentType, extension := "application/json", "json"
+	if req.Format == FormatZIP {
+		contentType, extension = "application/zip", "zip"
+		err = archive.WriteZIP(&buf)
+	} else {
+		err = archive.WriteJSON(&buf)
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	filename := fmt.Sprintf("shopifyx-export-%s-%s.%s", archive.Profile.Username, archive.ExportedAt.Format("20060102"), extension)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,15 @@
+package export
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ExportPayload struct {
+	Format Format `schema:"format"`
+}
+
+func (p ExportPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Format, validation.In(Formats...)),
+	)
+}
@@ -0,0 +1,108 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
+	bankaccount "github.com/citadel-corp/shopifyx-marketplace/internal/bank_account"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/product"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
+)
+
+// pageSize is how many transactions are read at a time.
+const pageSize = 100
+
+type Service interface {
+	Export(ctx context.Context, userID uint64) (*Archive, error)
+}
+
+type exportService struct {
+	userRepository        user.Repository
+	addressRepository     address.Repository
+	productRepository     product.Repository
+	bankAccountRepository bankaccount.Repository
+}
+
+func NewService(userRepository user.Repository, addressRepository address.Repository,
+	productRepository product.Repository, bankAccountRepository bankaccount.Repository) Service {
+	return &exportService{
+		userRepository:        userRepository,
+		addressRepository:     addressRepository,
+		productRepository:     productRepository,
+		bankAccountRepository: bankAccountRepository,
+	}
+}
+
+// Export implements Service. Archived bank accounts are included, purchases may
+// still refer to them.
+func (s *exportService) Export(ctx context.Context, userID uint64) (*Archive, error) {
+	profile, err := s.userRepository.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{
+		ExportedAt:   time.Now().UTC(),
+		Profile:      user.CreateProfileResponse(profile),
+		Addresses:    []address.AddressResponse{},
+		Products:     []product.ProductResponse{},
+		BankAccounts: []*bankaccount.BankAccountResponse{},
+		Purchases:    []product.AdminTransactionResponse{},
+		Sales:        []product.AdminTransactionResponse{},
+	}
+
+	addresses, err := s.addressRepository.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addresses {
+		archive.Addresses = append(archive.Addresses, address.CreateAddressResponse(a))
+	}
+
+	// without a limit the list is not paginated
+	products, _, err := s.productRepository.List(ctx, product.ListProductPayload{
+		UserOnly:       true,
+		UserID:         userID,
+		ShowEmptyStock: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		archive.Products = append(archive.Products, product.CreateProductResponse(p))
+	}
+
+	bankAccounts, err := s.bankAccountRepository.ListWithArchived(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bankAccounts {
+		resp := bankaccount.CreateBankAccountResponse(b)
+		resp.BankAccountNumber = bankaccount.MaskAccountNumber(resp.BankAccountNumber)
+		archive.BankAccounts = append(archive.BankAccounts, resp)
+	}
+
+	for offset := 0; ; offset += pageSize {
+		transactions, _, err := s.productRepository.ListUserTransactions(ctx, product.ListUserTransactionsPayload{
+			UserID: userID,
+			Limit:  pageSize,
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range transactions {
+			// a seller buying their own product gets it in both lists
+			if t.BuyerID == userID {
+				archive.Purchases = append(archive.Purchases, product.CreateAdminTransactionResponse(t))
+			}
+			if t.SellerID == userID {
+				archive.Sales = append(archive.Sales, product.CreateAdminTransactionResponse(t))
+			}
+		}
+		if len(transactions) < pageSize {
+			break
+		}
+	}
+	return archive, nil
+}
@@ -0,0 +1,20 @@
+package payment
+
+import "errors"
+
+var (
+	ErrValidationFailed       = errors.New("validation failed")
+	ErrNotFound               = errors.New("payment not found")
+	ErrChargeNotFound         = errors.New("charge not found")
+	ErrInvalidSignature       = errors.New("invalid webhook signature")
+	ErrEventAlreadyProcessed  = errors.New("webhook event already processed")
+	ErrAmountMismatch         = errors.New("charge amount does not match payment")
+	ErrRefundExceedsAmount    = errors.New("refund exceeds charged amount")
+	ErrChargeNotRefundable    = errors.New("charge is not refundable")
+	ErrChargeNotCancelable    = errors.New("charge can no longer be canceled")
+	ErrUnsupportedEventType   = errors.New("unsupported webhook event type")
+	ErrDuplicateTransfer      = errors.New("another pending transfer has the same reference or amount")
+	ErrAmbiguousTransfer      = errors.New("several pending transfers have the same amount")
+	ErrUnknownStatementFormat = errors.New("unknown bank statement format")
+	ErrStatusChanged          = errors.New("payment status changed meanwhile")
+)
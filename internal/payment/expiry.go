@@ -0,0 +1,62 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	expiryBatchSize = 100
+	expiryReason    = "payment window elapsed"
+)
+
+// ExpiryWorker periodically expires purchases that were not paid within the
+// payment window, putting their stock back on sale. It is safe to run in every
+// replica at once.
+type ExpiryWorker struct {
+	repository Repository
+	interval   time.Duration
+}
+
+func NewExpiryWorker(repository Repository, interval time.Duration) *ExpiryWorker {
+	return &ExpiryWorker{
+		repository: repository,
+		interval:   interval,
+	}
+}
+
+// Run expires overdue purchases every interval until ctx is done.
+func (w *ExpiryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := w.ExpireOverdue(ctx)
+			if err != nil {
+				slog.Error(fmt.Sprintf("payment.ExpiryWorker: error expiring purchases: %v", err))
+				continue
+			}
+			if expired > 0 {
+				slog.Info(fmt.Sprintf("payment.ExpiryWorker: expired %d purchases", expired))
+			}
+		}
+	}
+}
+
+// ExpireOverdue expires overdue purchases batch by batch and returns how many it expired.
+func (w *ExpiryWorker) ExpireOverdue(ctx context.Context) (int, error) {
+	var total int
+	for {
+		expired, err := w.repository.ExpireOverdue(ctx, time.Now(), expiryBatchSize, expiryReason)
+		total += expired
+		if err != nil || expired < expiryBatchSize {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FakeProvider is an in-process Provider for local development and tests.
+// Charges stay pending until Settle is called, which also produces the signed
+// webhook a real gateway would send.
+type FakeProvider struct {
+	mu            sync.Mutex
+	charges       map[string]*Charge
+	refunds       map[string]Charge
+	webhookSecret []byte
+}
+
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{
+		charges:       make(map[string]*Charge),
+		refunds:       make(map[string]Charge),
+		webhookSecret: []byte(webhookSecret),
+	}
+}
+
+// Name implements Provider.
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateCharge implements Provider.
+func (p *FakeProvider) CreateCharge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := "fake_ch_" + uuid.NewString()
+	charge := &Charge{
+		ID:         id,
+		Reference:  req.Reference,
+		Amount:     req.Amount,
+		Status:     StatusPending,
+		PaymentURL: fmt.Sprintf("/v1/payments/fake/%s/settle", id),
+	}
+	p.charges[id] = charge
+	c := *charge
+	return &c, nil
+}
+
+// GetCharge implements Provider.
+func (p *FakeProvider) GetCharge(ctx context.Context, chargeID string) (*Charge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[chargeID]
+	if !ok {
+		return nil, ErrChargeNotFound
+	}
+	c := *charge
+	return &c, nil
+}
+
+// CancelCharge implements Provider.
+func (p *FakeProvider) CancelCharge(ctx context.Context, chargeID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[chargeID]
+	if !ok {
+		return ErrChargeNotFound
+	}
+	if charge.Status != StatusPending {
+		return ErrChargeNotCancelable
+	}
+	charge.Status = StatusFailed
+	return nil
+}
+
+// Refund implements Provider.
+func (p *FakeProvider) Refund(ctx context.Context, chargeID string, amount int, idempotencyKey string) (*Charge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.refunds[idempotencyKey]; ok {
+		return &c, nil
+	}
+	charge, ok := p.charges[chargeID]
+	if !ok {
+		return nil, ErrChargeNotFound
+	}
+	if charge.Status != StatusSucceeded && charge.Status != StatusRefunded {
+		return nil, ErrChargeNotRefundable
+	}
+	if charge.RefundedAmount+amount > charge.Amount {
+		return nil, ErrRefundExceedsAmount
+	}
+	charge.RefundedAmount += amount
+	if charge.RefundedAmount == charge.Amount {
+		charge.Status = StatusRefunded
+	}
+	c := *charge
+	p.refunds[idempotencyKey] = c
+	return &c, nil
+}
+
+// Settle marks a pending charge as succeeded or failed and returns the signed
+// webhook payload announcing it.
+func (p *FakeProvider) Settle(chargeID string, succeeded bool) (payload []byte, signature string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[chargeID]
+	if !ok {
+		return nil, "", ErrChargeNotFound
+	}
+	if charge.Status != StatusPending {
+		return nil, "", fmt.Errorf("%w: charge is %s", ErrValidationFailed, charge.Status)
+	}
+
+	eventType := EventChargeFailed
+	charge.Status = StatusFailed
+	if succeeded {
+		eventType = EventChargeSucceeded
+		charge.Status = StatusSucceeded
+	}
+
+	payload, err = json.Marshal(Event{
+		ID:   "fake_evt_" + uuid.NewString(),
+		Type: eventType,
+		Data: EventData{
+			ChargeID: charge.ID,
+			Amount:   charge.Amount,
+		},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, SignWebhook(p.webhookSecret, time.Now(), payload), nil
+}
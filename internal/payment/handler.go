@@ -0,0 +1,193 @@
+package payment
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/request"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Webhook(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024) // 64 KB
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to read body",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = h.service.HandleWebhook(r.Context(), payload, r.Header.Get(SignatureHeader))
+	handleWebhookResult(w, err)
+}
+
+func handleWebhookResult(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrEventAlreadyProcessed) {
+		response.JSON(w, http.StatusOK, response.ResponseBody{
+			Message: "Event already processed",
+		})
+		return
+	}
+	if errors.Is(err, ErrInvalidSignature) {
+		response.JSON(w, http.StatusUnauthorized, response.ResponseBody{
+			Message: "Unauthorized",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrStatusChanged) {
+		// the provider redelivers the event and it is applied to the new status
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrAmountMismatch) || errors.Is(err, ErrUnsupportedEventType) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Event processed",
+	})
+}
+
+func (h *Handler) ImportStatement(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 5*1024*1024) // 5 MB
+
+	if err := r.ParseMultipartForm(5 * 1024 * 1024); err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "File must be smaller than 5 MB",
+		})
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "File should not be empty",
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	req := ImportStatementPayload{
+		Format: r.FormValue("format"),
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	if bankAccountID := r.FormValue("bankAccountId"); bankAccountID != "" {
+		uid, err := uuid.Parse(bankAccountID)
+		if err != nil {
+			response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+				Message: "Failed to parse UUID",
+				Error:   err.Error(),
+			})
+			return
+		}
+		req.BankAccountID = uuid.NullUUID{UUID: uid, Valid: true}
+	}
+
+	resp, err := h.service.ImportStatement(r.Context(), req, file)
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrUnknownStatementFormat) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Statement imported successfully",
+		Data:    resp,
+	})
+}
+
+// FakeHandler exposes the FakeProvider so a charge can be settled by hand
+// during local development. It must not be routed in production, and only
+// for admins elsewhere.
+type FakeHandler struct {
+	service  Service
+	provider *FakeProvider
+}
+
+func NewFakeHandler(service Service, provider *FakeProvider) *FakeHandler {
+	return &FakeHandler{service: service, provider: provider}
+}
+
+func (h *FakeHandler) SettleCharge(w http.ResponseWriter, r *http.Request) {
+	var req SettleFakeChargePayload
+
+	err := request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	payload, signature, err := h.provider.Settle(mux.Vars(r)["chargeId"], req.Succeeded)
+	if errors.Is(err, ErrChargeNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrValidationFailed) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = h.service.HandleWebhook(r.Context(), payload, signature)
+	handleWebhookResult(w, err)
+}
@@ -0,0 +1,62 @@
+package payment
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Payment struct {
+	ID            uint64
+	UUID          uuid.UUID
+	TransactionID uint64
+	Provider      string
+	ChargeID      string
+	Amount        int
+	Status        Status
+	// BankAccountID is the seller's bank account a transfer is paid into.
+	BankAccountID uuid.NullUUID
+	PaymentURL    string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusRefunded  Status = "refunded"
+	StatusExpired   Status = "expired"
+	// StatusRefunding is a payment whose refund was started but not confirmed yet. A
+	// payment left in it needs an admin to check the refund with the provider.
+	StatusRefunding Status = "refunding"
+)
+
+// Method is how the buyer pays for a purchase.
+type Method string
+
+const (
+	// MethodTransfer is a manual bank transfer to the seller, proven by an uploaded image.
+	MethodTransfer Method = "transfer"
+	// MethodGateway is a charge created through the configured payment provider.
+	MethodGateway Method = "gateway"
+)
+
+var Methods []interface{} = []interface{}{MethodTransfer, MethodGateway}
+
+// TransferProvider is stored as the provider of bank transfer payments. Their
+// charge ID is the payment reference the buyer puts in the transfer description.
+const TransferProvider = "transfer"
+
+// TransactionStatus is the payment state of a row in user_transactions.
+type TransactionStatus string
+
+const (
+	TransactionPending  TransactionStatus = "pending"
+	TransactionPaid     TransactionStatus = "paid"
+	TransactionExpired  TransactionStatus = "expired"
+	TransactionRefunded TransactionStatus = "refunded"
+)
@@ -0,0 +1,32 @@
+package payment
+
+import "context"
+
+// Provider is a payment gateway able to collect money from buyers.
+type Provider interface {
+	// Name identifies the provider, it is stored alongside every payment.
+	Name() string
+	CreateCharge(ctx context.Context, req ChargeRequest) (*Charge, error)
+	GetCharge(ctx context.Context, chargeID string) (*Charge, error)
+	// CancelCharge voids a charge the buyer has not paid yet.
+	CancelCharge(ctx context.Context, chargeID string) error
+	// Refund gives amount back to the buyer. Calls repeated with the same
+	// idempotencyKey refund only once.
+	Refund(ctx context.Context, chargeID string, amount int, idempotencyKey string) (*Charge, error)
+}
+
+type ChargeRequest struct {
+	// Reference is our own identifier for the charge, usually the transaction UUID.
+	Reference   string
+	Amount      int
+	Description string
+}
+
+type Charge struct {
+	ID             string
+	Reference      string
+	Amount         int
+	RefundedAmount int
+	Status         Status
+	PaymentURL     string
+}
@@ -0,0 +1,31 @@
+package payment
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+)
+
+const (
+	referencePrefix   = "SX"
+	referenceAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	referenceLength   = 8
+	// transferSuffixMax bounds the amount added to a transfer to make it unique.
+	transferSuffixMax = 999
+)
+
+var referencePattern = regexp.MustCompile(fmt.Sprintf("%s[%s]{%d}", referencePrefix, referenceAlphabet, referenceLength))
+
+func generateReference() (string, error) {
+	b := make([]byte, referenceLength)
+	max := big.NewInt(int64(len(referenceAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = referenceAlphabet[n.Int64()]
+	}
+	return referencePrefix + string(b), nil
+}
@@ -0,0 +1,291 @@
+package payment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	GetByChargeID(ctx context.Context, provider string, chargeID string) (*Payment, error)
+	GetByTransactionID(ctx context.Context, transactionID uint64) (*Payment, error)
+	GetPendingTransferByReference(ctx context.Context, reference string) (*Payment, error)
+	GetPendingTransferByAmount(ctx context.Context, amount int, bankAccountID uuid.NullUUID) (*Payment, error)
+	UpdateStatus(ctx context.Context, payment *Payment, from Status) error
+	ApplyEvent(ctx context.Context, eventID string, payment *Payment, from Status) error
+	ExpireOverdue(ctx context.Context, now time.Time, limit int, reason string) (int, error)
+}
+
+type dbRepository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) Repository {
+	return &dbRepository{db: db}
+}
+
+// GetByChargeID implements Repository.
+func (d *dbRepository) GetByChargeID(ctx context.Context, provider string, chargeID string) (*Payment, error) {
+	getQuery := `
+		SELECT id, uid, transaction_id, provider, charge_id, amount, status, created_at, updated_at
+		FROM payments
+		WHERE provider = $1 AND charge_id = $2;
+	`
+	row := d.db.DB().QueryRowContext(ctx, getQuery, provider, chargeID)
+	p := &Payment{}
+	err := row.Scan(&p.ID, &p.UUID, &p.TransactionID, &p.Provider, &p.ChargeID, &p.Amount, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetByTransactionID implements Repository.
+func (d *dbRepository) GetByTransactionID(ctx context.Context, transactionID uint64) (*Payment, error) {
+	getQuery := `
+		SELECT id, uid, transaction_id, provider, charge_id, amount, status, created_at, updated_at
+		FROM payments
+		WHERE transaction_id = $1
+		ORDER BY id DESC
+		LIMIT 1;
+	`
+	row := d.db.DB().QueryRowContext(ctx, getQuery, transactionID)
+	p := &Payment{}
+	err := row.Scan(&p.ID, &p.UUID, &p.TransactionID, &p.Provider, &p.ChargeID, &p.Amount, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPendingTransferByReference implements Repository.
+func (d *dbRepository) GetPendingTransferByReference(ctx context.Context, reference string) (*Payment, error) {
+	getQuery := `
+		SELECT id, uid, transaction_id, provider, charge_id, amount, status, created_at, updated_at
+		FROM payments
+		WHERE provider = $1 AND charge_id = $2 AND status = $3;
+	`
+	row := d.db.DB().QueryRowContext(ctx, getQuery, TransferProvider, reference, StatusPending)
+	p := &Payment{}
+	err := row.Scan(&p.ID, &p.UUID, &p.TransactionID, &p.Provider, &p.ChargeID, &p.Amount, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPendingTransferByAmount implements Repository. Pending transfer amounts are
+// unique per bank account, so without one it fails with ErrAmbiguousTransfer when
+// transfers into several accounts match.
+func (d *dbRepository) GetPendingTransferByAmount(ctx context.Context, amount int, bankAccountID uuid.NullUUID) (*Payment, error) {
+	getQuery := `
+		SELECT id, uid, transaction_id, provider, charge_id, amount, status, bank_account_id, created_at, updated_at
+		FROM payments
+		WHERE provider = $1 AND amount = $2 AND status = $3
+		AND ($4::uuid IS NULL OR bank_account_id = $4)
+		LIMIT 2;
+	`
+	rows, err := d.db.DB().QueryContext(ctx, getQuery, TransferProvider, amount, StatusPending, bankAccountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var payments []*Payment
+	for rows.Next() {
+		p := &Payment{}
+		err := rows.Scan(&p.ID, &p.UUID, &p.TransactionID, &p.Provider, &p.ChargeID, &p.Amount, &p.Status, &p.BankAccountID, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	switch len(payments) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return payments[0], nil
+	default:
+		return nil, ErrAmbiguousTransfer
+	}
+}
+
+// UpdateStatus implements Repository. The update only applies while the payment is
+// still in the from status.
+func (d *dbRepository) UpdateStatus(ctx context.Context, payment *Payment, from Status) error {
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		return updateStatus(ctx, tx, payment, from)
+	})
+}
+
+// ApplyEvent implements Repository. The event ID is recorded in the same
+// transaction as the status change, so a replayed event is never applied twice.
+func (d *dbRepository) ApplyEvent(ctx context.Context, eventID string, payment *Payment, from Status) error {
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO payment_webhook_events (event_id, provider)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING;
+		`, eventID, payment.Provider)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrEventAlreadyProcessed
+		}
+
+		return updateStatus(ctx, tx, payment, from)
+	})
+}
+
+// updateStatus moves the payment from the from status to its current one and settles
+// its transaction once paid. It fails with ErrStatusChanged when the payment or its
+// transaction moved on meanwhile, e.g. because the purchase expired.
+func updateStatus(ctx context.Context, tx *sql.Tx, payment *Payment, from Status) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE payments
+		SET status = $1,
+		updated_at = current_timestamp
+		WHERE id = $2
+		AND status = $3;
+	`, payment.Status, payment.ID, from)
+	if err != nil {
+		return err
+	}
+	err = requireRowsAffected(res)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status == StatusSucceeded {
+		res, err = tx.ExecContext(ctx, `
+			UPDATE user_transactions
+			SET status = $1,
+			paid_at = current_timestamp
+			WHERE id = $2
+			AND status = $3;
+		`, TransactionPaid, payment.TransactionID, TransactionPending)
+		if err != nil {
+			return err
+		}
+		return requireRowsAffected(res)
+	}
+	return nil
+}
+
+func requireRowsAffected(res sql.Result) error {
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrStatusChanged
+	}
+	return nil
+}
+
+// ExpireOverdue implements Repository. Overdue purchases are locked with SKIP LOCKED
+// so several workers can run at once without expiring the same purchase twice.
+func (d *dbRepository) ExpireOverdue(ctx context.Context, now time.Time, limit int, reason string) (int, error) {
+	var expired int
+	err := d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id, product_id, quantity
+			FROM user_transactions
+			WHERE status = $1 AND expires_at <= $2
+			ORDER BY expires_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED;
+		`, TransactionPending, now, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		type overdueTransaction struct {
+			id        uint64
+			productID uuid.UUID
+			quantity  int
+		}
+		var overdue []overdueTransaction
+		for rows.Next() {
+			var t overdueTransaction
+			if err := rows.Scan(&t.id, &t.productID, &t.quantity); err != nil {
+				return err
+			}
+			overdue = append(overdue, t)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, t := range overdue {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE user_transactions
+				SET status = $1,
+				status_reason = $2,
+				expired_at = $3
+				WHERE id = $4;
+			`, TransactionExpired, reason, now, t.id)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				UPDATE payments
+				SET status = $1,
+				updated_at = current_timestamp
+				WHERE transaction_id = $2
+				AND status = $3;
+			`, StatusExpired, t.id, StatusPending)
+			if err != nil {
+				return err
+			}
+
+			// release the stock and undo the counters set by the purchase
+			_, err = tx.ExecContext(ctx, `
+				WITH restored AS (
+					UPDATE products
+					SET stock = stock + $1,
+					purchase_count = purchase_count - $1
+					WHERE uid = $2
+					RETURNING user_id
+				)
+				UPDATE users
+				SET product_sold_total = product_sold_total - $1
+				WHERE id IN (SELECT user_id FROM restored);
+			`, t.quantity, t.productID)
+			if err != nil {
+				return err
+			}
+		}
+		expired = len(overdue)
+		return nil
+	})
+	return expired, err
+}
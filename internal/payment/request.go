@@ -0,0 +1,37 @@
+package payment
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+)
+
+func (e Event) Validate() error {
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.ID, validation.Required, validation.Length(1, 100)),
+		validation.Field(&e.Type, validation.Required),
+		validation.Field(&e.Data, validation.Required),
+	)
+}
+
+func (d EventData) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.ChargeID, validation.Required, validation.Length(1, 100)),
+		validation.Field(&d.Amount, validation.Required, validation.Min(1)),
+	)
+}
+
+type SettleFakeChargePayload struct {
+	Succeeded bool `json:"succeeded"`
+}
+
+type ImportStatementPayload struct {
+	Format string
+	// BankAccountID restricts matching to purchases paid into this account.
+	BankAccountID uuid.NullUUID
+}
+
+func (p ImportStatementPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Format, validation.Required, validation.Length(1, 20)),
+	)
+}
@@ -0,0 +1,48 @@
+package payment
+
+import "time"
+
+type PaymentResponse struct {
+	Provider   string    `json:"provider"`
+	ChargeID   string    `json:"chargeId"`
+	Amount     int       `json:"amount"`
+	Status     Status    `json:"status"`
+	PaymentURL string    `json:"paymentUrl,omitempty"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+func CreatePaymentResponse(payment *Payment) *PaymentResponse {
+	if payment == nil {
+		return nil
+	}
+	return &PaymentResponse{
+		Provider:   payment.Provider,
+		ChargeID:   payment.ChargeID,
+		Amount:     payment.Amount,
+		Status:     payment.Status,
+		PaymentURL: payment.PaymentURL,
+		ExpiresAt:  payment.ExpiresAt,
+	}
+}
+
+type StatementImportResponse struct {
+	Total     int                      `json:"total"`
+	Ignored   int                      `json:"ignored"`
+	Matched   []MatchedStatementLine   `json:"matched"`
+	Unmatched []UnmatchedStatementLine `json:"unmatched"`
+}
+
+type MatchedStatementLine struct {
+	Line      int    `json:"line"`
+	Amount    int    `json:"amount"`
+	Reference string `json:"reference"`
+	PaymentID string `json:"paymentId"`
+}
+
+type UnmatchedStatementLine struct {
+	Line        int    `json:"line"`
+	Date        string `json:"date"`
+	Description string `json:"description"`
+	Amount      int    `json:"amount"`
+	Reason      string `json:"reason"`
+}
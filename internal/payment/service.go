@@ -0,0 +1,306 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	CreateCharge(ctx context.Context, req ChargeRequest) (*Payment, error)
+	CancelCharge(ctx context.Context, payment *Payment) error
+	CreateTransfer(ctx context.Context, amount int, bankAccountID uuid.UUID) (*Payment, error)
+	HandleWebhook(ctx context.Context, payload []byte, signature string) error
+	Refund(ctx context.Context, transactionID uint64) (*Payment, error)
+	ImportStatement(ctx context.Context, req ImportStatementPayload, file io.Reader) (*StatementImportResponse, error)
+}
+
+type paymentService struct {
+	repository    Repository
+	provider      Provider
+	webhookSecret []byte
+	window        time.Duration
+}
+
+// NewService creates the payment service. window is how long a buyer has to pay
+// before the purchase expires.
+func NewService(repository Repository, provider Provider, webhookSecret string, window time.Duration) Service {
+	return &paymentService{
+		repository:    repository,
+		provider:      provider,
+		webhookSecret: []byte(webhookSecret),
+		window:        window,
+	}
+}
+
+// CreateCharge implements Service. The returned payment is not stored yet, the
+// caller persists it together with the transaction it pays for, or cancels it with
+// CancelCharge when that fails.
+func (s *paymentService) CreateCharge(ctx context.Context, req ChargeRequest) (*Payment, error) {
+	charge, err := s.provider.CreateCharge(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &Payment{
+		Provider:   s.provider.Name(),
+		ChargeID:   charge.ID,
+		Amount:     charge.Amount,
+		Status:     charge.Status,
+		PaymentURL: charge.PaymentURL,
+		ExpiresAt:  time.Now().Add(s.window),
+	}, nil
+}
+
+// CancelCharge implements Service. It voids a charge created by CreateCharge whose
+// payment could not be stored, so the buyer cannot pay for a purchase that does not
+// exist.
+func (s *paymentService) CancelCharge(ctx context.Context, payment *Payment) error {
+	return s.provider.CancelCharge(ctx, payment.ChargeID)
+}
+
+// CreateTransfer implements Service. The transfer gets a random reference and a
+// small amount suffix so a bank statement credit can be matched to it even when
+// the buyer leaves out the reference. Amounts only need to be unique per bank
+// account. Like CreateCharge, the payment is not stored yet; a collision surfaces as
+// ErrDuplicateTransfer when it is.
+func (s *paymentService) CreateTransfer(ctx context.Context, amount int, bankAccountID uuid.UUID) (*Payment, error) {
+	reference, err := generateReference()
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := rand.Int(rand.Reader, big.NewInt(transferSuffixMax))
+	if err != nil {
+		return nil, err
+	}
+	return &Payment{
+		Provider:      TransferProvider,
+		ChargeID:      reference,
+		Amount:        amount + int(suffix.Int64()) + 1,
+		Status:        StatusPending,
+		BankAccountID: uuid.NullUUID{UUID: bankAccountID, Valid: true},
+		ExpiresAt:     time.Now().Add(s.window),
+	}, nil
+}
+
+// HandleWebhook implements Service.
+func (s *paymentService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	err := VerifyWebhookSignature(s.webhookSecret, signature, payload, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+
+	payment, err := s.repository.GetByChargeID(ctx, s.provider.Name(), event.Data.ChargeID)
+	if err != nil {
+		return err
+	}
+
+	from := payment.Status
+	switch event.Type {
+	case EventChargeSucceeded:
+		if event.Data.Amount != payment.Amount {
+			return ErrAmountMismatch
+		}
+		switch from {
+		case StatusPending:
+			payment.Status = StatusSucceeded
+		case StatusExpired, StatusFailed:
+			return s.refundLatePayment(ctx, event.ID, payment)
+		default:
+			// an earlier event already settled the charge
+			return nil
+		}
+	case EventChargeFailed:
+		if from != StatusPending {
+			// a failed retry does not undo a charge that was already settled
+			return nil
+		}
+		payment.Status = StatusFailed
+	case EventChargeRefunded:
+		if from != StatusSucceeded && from != StatusRefunding {
+			// only money that was taken can come back
+			return nil
+		}
+		payment.Status = StatusRefunded
+	default:
+		return ErrUnsupportedEventType
+	}
+
+	return s.repository.ApplyEvent(ctx, event.ID, payment, from)
+}
+
+// refundLatePayment gives the money back when a charge succeeds after its purchase
+// expired, the stock is already back on sale. The payment is claimed as refunding
+// together with the event, so a refund that fails stays flagged instead of being
+// retried by a redelivered event.
+func (s *paymentService) refundLatePayment(ctx context.Context, eventID string, payment *Payment) error {
+	from := payment.Status
+	payment.Status = StatusRefunding
+	err := s.repository.ApplyEvent(ctx, eventID, payment, from)
+	if err != nil {
+		return err
+	}
+	err = s.completeRefund(ctx, payment)
+	if err != nil {
+		slog.Error(fmt.Sprintf("payment.Service: error refunding late payment %s: %v", payment.UUID, err))
+		return err
+	}
+	return nil
+}
+
+// completeRefund refunds a payment claimed as refunding. The refund is keyed on the
+// transaction, so calling it again after a failure never refunds twice.
+func (s *paymentService) completeRefund(ctx context.Context, payment *Payment) error {
+	_, err := s.provider.Refund(ctx, payment.ChargeID, payment.Amount, refundKey(payment))
+	if err != nil {
+		return err
+	}
+	payment.Status = StatusRefunded
+	err = s.repository.UpdateStatus(ctx, payment, StatusRefunding)
+	if errors.Is(err, ErrStatusChanged) {
+		// the refund webhook of the provider got there first
+		return nil
+	}
+	return err
+}
+
+func refundKey(payment *Payment) string {
+	return fmt.Sprintf("refund_%d", payment.TransactionID)
+}
+
+// Refund implements Service. Gateway payments are refunded through the provider.
+// Bank transfers cannot be pulled back, so their payment is returned unchanged and
+// the seller refunds the buyer by hand. Refunding a transaction again returns its
+// payment and finishes a refund an earlier call left refunding.
+func (s *paymentService) Refund(ctx context.Context, transactionID uint64) (*Payment, error) {
+	payment, err := s.repository.GetByTransactionID(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Provider == TransferProvider {
+		return payment, nil
+	}
+	switch payment.Status {
+	case StatusRefunded:
+		return payment, nil
+	case StatusSucceeded:
+		payment.Status = StatusRefunding
+		err = s.repository.UpdateStatus(ctx, payment, StatusSucceeded)
+		if err != nil {
+			return nil, err
+		}
+	case StatusRefunding:
+	default:
+		return nil, ErrChargeNotRefundable
+	}
+
+	err = s.completeRefund(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// ImportStatement implements Service. Credits are matched to pending transfers by
+// the reference in their description first, then by their unique amount.
+func (s *paymentService) ImportStatement(ctx context.Context, req ImportStatementPayload, file io.Reader) (*StatementImportResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	parser, err := getStatementParser(req.Format)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := parser.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &StatementImportResponse{
+		Total:     len(lines),
+		Matched:   []MatchedStatementLine{},
+		Unmatched: []UnmatchedStatementLine{},
+	}
+	for _, line := range lines {
+		if line.Amount <= 0 {
+			resp.Ignored++
+			continue
+		}
+
+		payment, reason, err := s.matchStatementLine(ctx, req, line)
+		if err != nil {
+			return nil, err
+		}
+		if payment != nil {
+			payment.Status = StatusSucceeded
+			err = s.repository.UpdateStatus(ctx, payment, StatusPending)
+			if errors.Is(err, ErrStatusChanged) {
+				// a concurrent import or the expiry worker got to the payment first
+				payment, reason = nil, "payment is no longer pending"
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if payment == nil {
+			resp.Unmatched = append(resp.Unmatched, UnmatchedStatementLine{
+				Line:        line.Line,
+				Date:        line.Date.Format(time.DateOnly),
+				Description: line.Description,
+				Amount:      line.Amount,
+				Reason:      reason,
+			})
+			continue
+		}
+		resp.Matched = append(resp.Matched, MatchedStatementLine{
+			Line:      line.Line,
+			Amount:    line.Amount,
+			Reference: payment.ChargeID,
+			PaymentID: payment.UUID.String(),
+		})
+	}
+	return resp, nil
+}
+
+func (s *paymentService) matchStatementLine(ctx context.Context, req ImportStatementPayload, line StatementLine) (*Payment, string, error) {
+	if reference := referencePattern.FindString(strings.ToUpper(line.Description)); reference != "" {
+		payment, err := s.repository.GetPendingTransferByReference(ctx, reference)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, "", err
+		}
+		if payment != nil {
+			if payment.Amount != line.Amount {
+				return nil, fmt.Sprintf("amount does not match reference %s, expected %d", reference, payment.Amount), nil
+			}
+			return payment, "", nil
+		}
+	}
+
+	payment, err := s.repository.GetPendingTransferByAmount(ctx, line.Amount, req.BankAccountID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, "no pending transfer with this reference or amount", nil
+	}
+	if errors.Is(err, ErrAmbiguousTransfer) {
+		return nil, "pending transfers into several bank accounts have this amount, import with bankAccountId", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return payment, "", nil
+}
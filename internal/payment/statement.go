@@ -0,0 +1,46 @@
+package payment
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// StatementLine is a single entry of an imported bank statement.
+type StatementLine struct {
+	// Line is the position of the entry in the source file, used in reports.
+	Line        int
+	Date        time.Time
+	Description string
+	// Amount is positive for credits and negative for debits.
+	Amount int
+}
+
+// StatementParser reads a bank statement in a specific file format, such as CSV or MT940.
+type StatementParser interface {
+	Parse(r io.Reader) ([]StatementLine, error)
+}
+
+var (
+	statementParsersMu sync.RWMutex
+	statementParsers   = map[string]StatementParser{
+		"csv": CSVStatementParser{},
+	}
+)
+
+// RegisterStatementParser makes a parser available under format for statement imports.
+func RegisterStatementParser(format string, parser StatementParser) {
+	statementParsersMu.Lock()
+	defer statementParsersMu.Unlock()
+	statementParsers[format] = parser
+}
+
+func getStatementParser(format string) (StatementParser, error) {
+	statementParsersMu.RLock()
+	defer statementParsersMu.RUnlock()
+	parser, ok := statementParsers[format]
+	if !ok {
+		return nil, ErrUnknownStatementFormat
+	}
+	return parser, nil
+}
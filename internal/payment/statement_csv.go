@@ -0,0 +1,77 @@
+package payment
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVStatementParser reads statements with a header row containing at least the
+// date, description and amount columns. Dates use YYYY-MM-DD and debits have a
+// negative amount.
+type CSVStatementParser struct{}
+
+// Parse implements StatementParser.
+func (CSVStatementParser) Parse(r io.Reader) ([]StatementLine, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: statement is empty", ErrValidationFailed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"date", "description", "amount"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrValidationFailed, name)
+		}
+	}
+
+	var lines []StatementLine
+	for lineNumber := 2; ; lineNumber++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+		}
+
+		date, err := time.Parse(time.DateOnly, strings.TrimSpace(record[columns["date"]]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid date", ErrValidationFailed, lineNumber)
+		}
+		amount, err := parseStatementAmount(record[columns["amount"]])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid amount", ErrValidationFailed, lineNumber)
+		}
+		lines = append(lines, StatementLine{
+			Line:        lineNumber,
+			Date:        date,
+			Description: strings.TrimSpace(record[columns["description"]]),
+			Amount:      amount,
+		})
+	}
+	return lines, nil
+}
+
+func parseStatementAmount(s string) (int, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(amount)), nil
+}
@@ -0,0 +1,99 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the webhook signature in the form "t=<unix>,v1=<hex hmac>".
+	SignatureHeader = "X-Payment-Signature"
+	// SignatureTolerance is how old a signed webhook may be before it is treated as a replay.
+	SignatureTolerance = 5 * time.Minute
+)
+
+type EventType string
+
+const (
+	EventChargeSucceeded EventType = "charge.succeeded"
+	EventChargeFailed    EventType = "charge.failed"
+	EventChargeRefunded  EventType = "charge.refunded"
+)
+
+type Event struct {
+	ID   string    `json:"id"`
+	Type EventType `json:"type"`
+	Data EventData `json:"data"`
+}
+
+type EventData struct {
+	ChargeID string `json:"chargeId"`
+	Amount   int    `json:"amount"`
+}
+
+// SignWebhook signs payload the same way providers are expected to, so that
+// VerifyWebhookSignature accepts it.
+func SignWebhook(secret []byte, timestamp time.Time, payload []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, computeSignature(secret, ts, payload))
+}
+
+// VerifyWebhookSignature checks the signature header against payload and rejects
+// signatures older than SignatureTolerance. Without a secret anyone could sign, so
+// every signature is rejected.
+func VerifyWebhookSignature(secret []byte, header string, payload []byte, now time.Time) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("%w: no webhook secret configured", ErrInvalidSignature)
+	}
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	if ts == "" || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > SignatureTolerance || age < -SignatureTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	expected, err := hex.DecodeString(computeSignature(secret, ts, payload))
+	if err != nil {
+		return err
+	}
+	actual, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal(expected, actual) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func computeSignature(secret []byte, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -14,6 +14,8 @@ var (
 	ErrorNoRecords     = Response{Code: http.StatusOK, Message: "No records found"}
 	ErrorNotFound      = Response{Code: http.StatusNotFound, Message: "No records found"}
 
-	ErrorNotPurchasable    = Response{Code: http.StatusBadRequest, Message: "product is not purchasable"}
-	ErrorInsufficientStock = Response{Code: http.StatusBadRequest, Message: "insufficient product stock"}
+	ErrorNotPurchasable        = Response{Code: http.StatusBadRequest, Message: "product is not purchasable"}
+	ErrorInsufficientStock     = Response{Code: http.StatusBadRequest, Message: "insufficient product stock"}
+	ErrorNoBankAccount         = Response{Code: http.StatusBadRequest, Message: "seller has no bank account to transfer to"}
+	ErrorBankAccountUnverified = Response{Code: http.StatusBadRequest, Message: "seller bank account is not verified"}
 )
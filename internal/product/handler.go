@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/citadel-corp/shopifyx-marketplace/internal/audit"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/middleware"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/request"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
@@ -27,10 +28,10 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	var resp Response
 	var err error
 
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		switch {
-		case errors.Is(err, ErrorUnauthorized.Error):
+		case errors.Is(err, middleware.ErrUnauthenticated):
 			response.JSON(w, ErrorUnauthorized.Code, response.ResponseBody{})
 			return
 		default:
@@ -79,10 +80,10 @@ func (h *Handler) GetProductList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.UserOnly {
-		userID, err := getUserID(r)
+		userID, err := middleware.GetUserID(r)
 		if err != nil {
 			switch {
-			case errors.Is(err, ErrorUnauthorized.Error):
+			case errors.Is(err, middleware.ErrUnauthenticated):
 				response.JSON(w, ErrorUnauthorized.Code, response.ResponseBody{})
 				return
 			default:
@@ -110,15 +111,48 @@ func (h *Handler) GetProductList(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (h *Handler) GetStorefront(w http.ResponseWriter, r *http.Request) {
+	var req ListProductPayload
+	var resp Response
+	var err error
+
+	newSchema := schema.NewDecoder()
+	newSchema.IgnoreUnknownKeys(true)
+	if err = newSchema.Decode(&req, r.URL.Query()); err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{})
+		return
+	}
+	// a storefront only lists what everyone gets to see
+	req.UserOnly = false
+	req.UserID = 0
+	req.SellerUsername = mux.Vars(r)["username"]
+
+	err = req.Validate()
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp = h.service.GetStorefront(r.Context(), req)
+	response.JSON(w, resp.Code, response.ResponseBody{
+		Message: resp.Message,
+		Data:    resp.Data,
+		Meta:    resp.Meta,
+	})
+}
+
 func (h *Handler) PatchProduct(w http.ResponseWriter, r *http.Request) {
 	var req UpdateProductPayload
 	var resp Response
 	var err error
 
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		switch {
-		case errors.Is(err, ErrorUnauthorized.Error):
+		case errors.Is(err, middleware.ErrUnauthenticated):
 			response.JSON(w, ErrorUnauthorized.Code, response.ResponseBody{})
 			return
 		default:
@@ -203,10 +237,10 @@ func (h *Handler) PurchaseProduct(w http.ResponseWriter, r *http.Request) {
 	var resp Response
 	var err error
 
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		switch {
-		case errors.Is(err, ErrorUnauthorized.Error):
+		case errors.Is(err, middleware.ErrUnauthenticated):
 			response.JSON(w, ErrorUnauthorized.Code, response.ResponseBody{})
 			return
 		default:
@@ -259,10 +293,10 @@ func (h *Handler) UpdateStockProduct(w http.ResponseWriter, r *http.Request) {
 	var resp Response
 	var err error
 
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		switch {
-		case errors.Is(err, ErrorUnauthorized.Error):
+		case errors.Is(err, middleware.ErrUnauthenticated):
 			response.JSON(w, ErrorUnauthorized.Code, response.ResponseBody{})
 			return
 		default:
@@ -315,10 +349,10 @@ func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	var resp Response
 	var err error
 
-	userID, err := getUserID(r)
+	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		switch {
-		case errors.Is(err, ErrorUnauthorized.Error):
+		case errors.Is(err, middleware.ErrUnauthenticated):
 			response.JSON(w, ErrorUnauthorized.Code, response.ResponseBody{})
 			return
 		default:
@@ -357,25 +391,145 @@ func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func getUserID(r *http.Request) (uint64, error) {
-	var userID uint64
+func (h *Handler) UnlistProduct(w http.ResponseWriter, r *http.Request) {
+	h.moderateProduct(w, r, ModerationUnlisted)
+}
+
+func (h *Handler) RemoveProduct(w http.ResponseWriter, r *http.Request) {
+	h.moderateProduct(w, r, ModerationRemoved)
+}
+
+func (h *Handler) RelistProduct(w http.ResponseWriter, r *http.Request) {
+	h.moderateProduct(w, r, ModerationListed)
+}
+
+func (h *Handler) moderateProduct(w http.ResponseWriter, r *http.Request, status ModerationStatus) {
+	var req ModerateProductPayload
+	var resp Response
 	var err error
 
-	if authValue, ok := r.Context().Value(middleware.ContextAuthKey{}).(string); ok {
-		userID, err = strconv.ParseUint(authValue, 10, 64)
+	params := mux.Vars(r)
+	uid, err := uuid.Parse(params["productId"])
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to parse UUID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// relisting needs no reason, so an empty body is fine
+	if status != ModerationListed || r.ContentLength != 0 {
+		err = request.DecodeJSON(w, r, &req)
 		if err != nil {
-			slog.Error("getUserID: %v", err)
-			return 0, ErrorInternal.Error
+			response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+				Message: "Failed to decode JSON",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	req.ProductUID = uid
+	req.Status = status
+	audit.SetReason(r.Context(), req.Reason)
+
+	err = req.Validate()
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp = h.service.Moderate(r.Context(), req)
+	response.JSON(w, resp.Code, response.ResponseBody{
+		Message: resp.Message,
+	})
+}
+
+func (h *Handler) ListUserTransactions(w http.ResponseWriter, r *http.Request) {
+	var req ListUserTransactionsPayload
+	var resp Response
+	var err error
+
+	newSchema := schema.NewDecoder()
+	newSchema.IgnoreUnknownKeys(true)
+	if err = newSchema.Decode(&req, r.URL.Query()); err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{})
+		return
+	}
+
+	req.UserID, err = strconv.ParseUint(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		response.JSON(w, ErrorNotFound.Code, response.ResponseBody{
+			Message: ErrorNotFound.Message,
+		})
+		return
+	}
+
+	err = req.Validate()
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp = h.service.ListUserTransactions(r.Context(), req)
+	response.JSON(w, resp.Code, response.ResponseBody{
+		Message: resp.Message,
+		Data:    resp.Data,
+		Meta:    resp.Meta,
+	})
+}
+
+func (h *Handler) ListShopSales(w http.ResponseWriter, r *http.Request) {
+	var req ListShopSalesPayload
+	var resp Response
+	var err error
+
+	newSchema := schema.NewDecoder()
+	newSchema.IgnoreUnknownKeys(true)
+	if err = newSchema.Decode(&req, r.URL.Query()); err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{})
+		return
+	}
+
+	req.UserID, err = middleware.GetUserID(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, middleware.ErrUnauthenticated):
+			response.JSON(w, ErrorUnauthorized.Code, response.ResponseBody{})
+			return
+		default:
+			response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+			return
 		}
-	} else {
-		slog.Error("getUserID: cannot parse auth value from context")
-		return 0, ErrorUnauthorized.Error
 	}
 
-	if userID == 0 {
-		slog.Error("getUserID: userID is not set")
-		return 0, ErrorUnauthorized.Error
+	req.ShopUID, err = uuid.Parse(mux.Vars(r)["shopId"])
+	if err != nil {
+		response.JSON(w, ErrorNotFound.Code, response.ResponseBody{
+			Message: ErrorNotFound.Message,
+		})
+		return
+	}
+
+	err = req.Validate()
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Error: err.Error(),
+		})
+		return
 	}
 
-	return userID, nil
+	resp = h.service.ListShopSales(r.Context(), req)
+	response.JSON(w, resp.Code, response.ResponseBody{
+		Message: resp.Message,
+		Data:    resp.Data,
+		Meta:    resp.Meta,
+	})
 }
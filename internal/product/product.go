@@ -19,8 +19,27 @@ type Product struct {
 	IsPurchasable bool
 	Price         int
 	PurchaseCount int
-	User          user.User
-	CreatedAt     time.Time
+	// ShopID is the shop selling the product, User its owner who gets paid.
+	ShopID    uint64
+	User      user.User
+	CreatedAt time.Time
+
+	ModerationStatus ModerationStatus
+	ModerationReason string
+}
+
+// ModerationStatus is set by admins. Only listed products are shown to and can be
+// bought by buyers; the seller still sees the others with the reason.
+type ModerationStatus string
+
+const (
+	ModerationListed   ModerationStatus = "listed"
+	ModerationUnlisted ModerationStatus = "unlisted"
+	ModerationRemoved  ModerationStatus = "removed"
+)
+
+func (p *Product) IsListed() bool {
+	return p.ModerationStatus == ModerationListed
 }
 
 type Condition string
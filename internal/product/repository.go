@@ -3,12 +3,18 @@ package product
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/shipping"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/lib/pq"
 )
 
@@ -18,8 +24,11 @@ type Repository interface {
 	Update(ctx context.Context, product *Product) error
 	GetByUUID(ctx context.Context, uuid uuid.UUID) (*Product, error)
 	Patch(ctx context.Context, product *Product) error
-	Purchase(ctx context.Context, data PurchaseProductPayload) error
+	Purchase(ctx context.Context, data PurchaseProductPayload, trx *Transaction) error
 	Delete(ctx context.Context, uid uuid.UUID) error
+	Moderate(ctx context.Context, uid uuid.UUID, status ModerationStatus, reason string) error
+	ListUserTransactions(ctx context.Context, filter ListUserTransactionsPayload) ([]*Transaction, *response.Pagination, error)
+	ListShopSales(ctx context.Context, shopID uint64, limit, offset int) ([]*Transaction, *response.Pagination, error)
 }
 
 type DBRepository struct {
@@ -33,11 +42,12 @@ func NewRepository(db *db.DB) *DBRepository {
 func (d *DBRepository) Create(ctx context.Context, product *Product) error {
 	err := d.db.StartTx(ctx, func(tx *sql.Tx) error {
 		_, err := tx.Exec(`INSERT INTO products (
-				name, image_url, stock, condition, tags, is_purchaseable, price, user_id
+				name, image_url, stock, condition, tags, is_purchaseable, price, user_id, shop_id
 			) VALUES (
-				$1, $2, $3, $4, $5, $6, $7, $8
+				$1, $2, $3, $4, $5, $6, $7, $8, $9
 			)`,
-			product.Name, product.ImageURL, product.Stock, product.Condition, pq.Array(product.Tags), product.IsPurchasable, product.Price, product.User.ID)
+			product.Name, product.ImageURL, product.Stock, product.Condition, pq.Array(product.Tags), product.IsPurchasable, product.Price, product.User.ID,
+			product.ShopID)
 		if err != nil {
 			return err
 		}
@@ -69,6 +79,28 @@ func (d *DBRepository) List(ctx context.Context, filter ListProductPayload) ([]P
 		columnCtr++
 	}
 
+	if filter.ShopID != "" {
+		whereStatement = insertWhereStatement(len(args) > 0, whereStatement)
+		whereStatement = fmt.Sprintf("%s products.shop_id = (SELECT id FROM shops WHERE uid = $%d)", whereStatement, columnCtr)
+		args = append(args, filter.ShopID)
+		columnCtr++
+	}
+
+	if filter.SellerUsername != "" {
+		whereStatement = insertWhereStatement(len(args) > 0, whereStatement)
+		whereStatement = fmt.Sprintf("%s products.user_id = (SELECT id FROM users WHERE username = $%d)", whereStatement, columnCtr)
+		args = append(args, filter.SellerUsername)
+		columnCtr++
+	}
+
+	// sellers see their moderated products, everyone else only listed ones
+	if !filter.UserOnly {
+		whereStatement = insertWhereStatement(len(args) > 0, whereStatement)
+		whereStatement = fmt.Sprintf("%s products.moderation_status = $%d", whereStatement, columnCtr)
+		args = append(args, ModerationListed)
+		columnCtr++
+	}
+
 	if len(filter.Tags) > 0 {
 		whereStatement = insertWhereStatement(len(args) > 0, whereStatement)
 		for i := range filter.Tags {
@@ -146,7 +178,8 @@ func (d *DBRepository) List(ctx context.Context, filter ListProductPayload) ([]P
 		selectStatement = fmt.Sprintf(`
 			SELECT COUNT(*) OVER() AS total_count, products.uid as productId, products.name as name, products.image_url as imageUrl, 
 				products.stock as stock, products.condition as condition, products.tags as tags, products.is_purchaseable as isPurchasable, 
-				products.price as price, products.purchase_count as purchaseCount 
+				products.price as price, products.purchase_count as purchaseCount, 
+				products.moderation_status as moderationStatus, products.moderation_reason as moderationReason 
 			FROM products 
 		%s`, selectStatement)
 
@@ -171,7 +204,7 @@ func (d *DBRepository) List(ctx context.Context, filter ListProductPayload) ([]P
 		for rows.Next() {
 			var p Product
 			if err := rows.Scan(&pagination.Total, &p.UUID, &p.Name, &p.ImageURL, &p.Stock, &p.Condition,
-				pq.Array(&p.Tags), &p.IsPurchasable, &p.Price, &p.PurchaseCount); err != nil {
+				pq.Array(&p.Tags), &p.IsPurchasable, &p.Price, &p.PurchaseCount, &p.ModerationStatus, &p.ModerationReason); err != nil {
 				return products, nil, err
 			}
 			products = append(products, p)
@@ -180,7 +213,8 @@ func (d *DBRepository) List(ctx context.Context, filter ListProductPayload) ([]P
 		selectStatement = fmt.Sprintf(`
 			SELECT products.uid as productId, products.name as name, products.image_url as imageUrl, 
 				products.stock as stock, products.condition as condition, products.tags as tags, products.is_purchaseable as isPurchasable, 
-				products.price as price, products.purchase_count as purchaseCount 
+				products.price as price, products.purchase_count as purchaseCount, 
+				products.moderation_status as moderationStatus, products.moderation_reason as moderationReason 
 			FROM products 
 		%s`, selectStatement)
 
@@ -198,7 +232,7 @@ func (d *DBRepository) List(ctx context.Context, filter ListProductPayload) ([]P
 		for rows.Next() {
 			var p Product
 			if err := rows.Scan(&p.UUID, &p.Name, &p.ImageURL, &p.Stock, &p.Condition,
-				pq.Array(&p.Tags), &p.IsPurchasable, &p.Price, &p.PurchaseCount); err != nil {
+				pq.Array(&p.Tags), &p.IsPurchasable, &p.Price, &p.PurchaseCount, &p.ModerationStatus, &p.ModerationReason); err != nil {
 				return products, nil, err
 			}
 			products = append(products, p)
@@ -225,10 +259,9 @@ func (d *DBRepository) Update(ctx context.Context, product *Product) error {
 				image_url = $3,
 				condition = $4,
 				tags = $5
-				WHERE uid = $6
-				AND user_id = $7;
+				WHERE uid = $6;
 			`,
-			product.Name, product.Price, product.ImageURL, product.Condition, pq.Array(product.Tags), product.UUID, product.User.ID)
+			product.Name, product.Price, product.ImageURL, product.Condition, pq.Array(product.Tags), product.UUID)
 		if err != nil {
 			return err
 		}
@@ -240,13 +273,15 @@ func (d *DBRepository) Update(ctx context.Context, product *Product) error {
 
 func (d *DBRepository) GetByUUID(ctx context.Context, uuid uuid.UUID) (*Product, error) {
 	row := d.db.DB().QueryRowContext(ctx, `
-		SELECT p.uid, p.user_id, p.name, p.price, p.image_url, p.stock, p.condition, p.tags, p.is_purchaseable, p.purchase_count
+		SELECT p.uid, p.user_id, p.shop_id, p.name, p.price, p.image_url, p.stock, p.condition, p.tags, p.is_purchaseable, p.purchase_count,
+			p.moderation_status, p.moderation_reason
 		FROM products p
 		WHERE uid = $1;
 	`, uuid)
 
 	var p Product
-	err := row.Scan(&p.UUID, &p.User.ID, &p.Name, &p.Price, &p.ImageURL, &p.Stock, &p.Condition, pq.Array(&p.Tags), &p.IsPurchasable, &p.PurchaseCount)
+	err := row.Scan(&p.UUID, &p.User.ID, &p.ShopID, &p.Name, &p.Price, &p.ImageURL, &p.Stock, &p.Condition, pq.Array(&p.Tags), &p.IsPurchasable, &p.PurchaseCount,
+		&p.ModerationStatus, &p.ModerationReason)
 	if err != nil {
 		return nil, err
 	}
@@ -292,7 +327,7 @@ func (d *DBRepository) Patch(ctx context.Context, product *Product) error {
 	return nil
 }
 
-func (d *DBRepository) Purchase(ctx context.Context, data PurchaseProductPayload) error {
+func (d *DBRepository) Purchase(ctx context.Context, data PurchaseProductPayload, trx *Transaction) error {
 	err := d.db.StartTx(ctx, func(tx *sql.Tx) error {
 		// update product sold total
 		_, err := tx.ExecContext(ctx, `
@@ -306,17 +341,55 @@ func (d *DBRepository) Purchase(ctx context.Context, data PurchaseProductPayload
 		}
 
 		// update user transactions
-		_, err = tx.ExecContext(ctx, `
+		var bankAccountID uuid.NullUUID
+		if trx.BankAccount != nil {
+			bankAccountID = uuid.NullUUID{UUID: trx.BankAccount.UUID, Valid: true}
+		}
+		imageURL := sql.NullString{String: trx.PaymentProofImageURL, Valid: trx.PaymentProofImageURL != ""}
+		paidAt := sql.NullTime{Time: time.Now(), Valid: trx.Status == payment.TransactionPaid}
+		var expiresAt sql.NullTime
+		if trx.Payment != nil {
+			expiresAt = sql.NullTime{Time: trx.Payment.ExpiresAt, Valid: true}
+		}
+		shippingAddress, err := json.Marshal(trx.ShippingAddress)
+		if err != nil {
+			return err
+		}
+		row := tx.QueryRowContext(ctx, `
 			INSERT INTO user_transactions (
-				user_id, product_id, bank_account_id, image_url
+				uid, user_id, product_id, bank_account_id, image_url, quantity, amount, payment_method, status, paid_at, expires_at,
+				shipping_address, shipping_fee, shipping_status
 			) VALUES (
-				$1, $2, $3, $4
+				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
 			)
-		`, data.BuyerID, data.ProductUID, data.BankAccountID, data.PaymentProofImageURL)
+			RETURNING id
+		`, trx.UUID, data.BuyerID, data.ProductUID, bankAccountID, imageURL, trx.Quantity, trx.Amount, trx.PaymentMethod, trx.Status, paidAt, expiresAt,
+			shippingAddress, trx.ShippingFee, shipping.StatusAwaitingShipment)
+		err = row.Scan(&trx.ID)
 		if err != nil {
 			return err
 		}
 
+		if trx.Payment != nil {
+			trx.Payment.TransactionID = trx.ID
+			row = tx.QueryRowContext(ctx, `
+				INSERT INTO payments (
+					transaction_id, provider, charge_id, amount, status, bank_account_id
+				) VALUES (
+					$1, $2, $3, $4, $5, $6
+				)
+				RETURNING id, uid
+			`, trx.Payment.TransactionID, trx.Payment.Provider, trx.Payment.ChargeID, trx.Payment.Amount, trx.Payment.Status, trx.Payment.BankAccountID)
+			err = row.Scan(&trx.Payment.ID, &trx.Payment.UUID)
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" && isTransferCollision(pgErr.ConstraintName) {
+				return payment.ErrDuplicateTransfer
+			}
+			if err != nil {
+				return err
+			}
+		}
+
 		// update seller
 		_, err = tx.ExecContext(ctx, `
 			UPDATE users
@@ -336,6 +409,12 @@ func (d *DBRepository) Purchase(ctx context.Context, data PurchaseProductPayload
 	return nil
 }
 
+// isTransferCollision reports whether constraint is one of the unique constraints a
+// new transfer violates when its random reference or amount is already taken.
+func isTransferCollision(constraint string) bool {
+	return constraint == "payment_provider_charge_id_unique" || constraint == "payments_pending_transfer_bank_account_amount"
+}
+
 // Delete implements Repository.
 func (d *DBRepository) Delete(ctx context.Context, uid uuid.UUID) error {
 	deleteQuery := `
@@ -356,6 +435,108 @@ func (d *DBRepository) Delete(ctx context.Context, uid uuid.UUID) error {
 	return nil
 }
 
+// Moderate implements Repository.
+func (d *DBRepository) Moderate(ctx context.Context, uid uuid.UUID, status ModerationStatus, reason string) error {
+	moderateQuery := `
+		UPDATE products
+		SET moderation_status = $1,
+		moderation_reason = $2,
+		moderated_at = current_timestamp
+		WHERE uid = $3;
+	`
+	row, err := d.db.DB().ExecContext(ctx, moderateQuery, status, reason, uid)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := row.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListUserTransactions implements Repository. It lists the purchases the user made
+// or sold, newest first.
+func (d *DBRepository) ListUserTransactions(ctx context.Context, filter ListUserTransactionsPayload) ([]*Transaction, *response.Pagination, error) {
+	listQuery := `
+		SELECT COUNT(*) OVER() AS total_count, t.uid, t.product_id, t.user_id, p.user_id, t.quantity, t.amount, t.shipping_fee, t.payment_method, t.status,
+			COALESCE(t.image_url, ''), t.created_at
+		FROM user_transactions t
+		JOIN products p ON p.uid = t.product_id
+		WHERE t.user_id = $1 OR p.user_id = $1
+		ORDER BY t.id DESC
+		LIMIT $2 OFFSET $3;
+	`
+	pagination := &response.Pagination{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+	rows, err := d.db.DB().QueryContext(ctx, listQuery, filter.UserID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var transactions []*Transaction
+	for rows.Next() {
+		t := &Transaction{}
+		err := rows.Scan(&pagination.Total, &t.UUID, &t.ProductUID, &t.BuyerID, &t.SellerID, &t.Quantity, &t.Amount, &t.ShippingFee,
+			&t.PaymentMethod, &t.Status, &t.PaymentProofImageURL, &t.CreatedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return transactions, pagination, nil
+}
+
+// ListShopSales implements Repository. It lists the purchases of the products of a
+// shop, newest first.
+func (d *DBRepository) ListShopSales(ctx context.Context, shopID uint64, limit, offset int) ([]*Transaction, *response.Pagination, error) {
+	listQuery := `
+		SELECT COUNT(*) OVER() AS total_count, t.uid, t.product_id, t.user_id, p.user_id, t.quantity, t.amount, t.shipping_fee, t.payment_method, t.status, t.created_at
+		FROM user_transactions t
+		JOIN products p ON p.uid = t.product_id
+		WHERE p.shop_id = $1
+		ORDER BY t.id DESC
+		LIMIT $2 OFFSET $3;
+	`
+	pagination := &response.Pagination{
+		Limit:  limit,
+		Offset: offset,
+	}
+	rows, err := d.db.DB().QueryContext(ctx, listQuery, shopID, limit, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var transactions []*Transaction
+	for rows.Next() {
+		t := &Transaction{}
+		err := rows.Scan(&pagination.Total, &t.UUID, &t.ProductUID, &t.BuyerID, &t.SellerID, &t.Quantity, &t.Amount, &t.ShippingFee,
+			&t.PaymentMethod, &t.Status, &t.CreatedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return transactions, pagination, nil
+}
+
 func insertWhereStatement(condition bool, statement string) string {
 	if condition {
 		return fmt.Sprintf(`%v AND`, statement)
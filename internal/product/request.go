@@ -3,6 +3,7 @@ package product
 import (
 	"errors"
 
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
 	"github.com/google/uuid"
 	validation "github.com/itgelo/ozzo-validation/v4"
 	"github.com/itgelo/ozzo-validation/v4/is"
@@ -16,6 +17,7 @@ type CreateProductPayload struct {
 	Condition     Condition `json:"condition"`
 	Tags          []string  `json:"tags"`
 	IsPurchasable bool      `json:"isPurchasable"`
+	ShopID        uuid.UUID `json:"shopId"`
 	UserID        uint64    `json:"-"`
 }
 
@@ -50,6 +52,8 @@ var productSortBys []interface{} = []interface{}{SortByPrice, SortByDate}
 type ListProductPayload struct {
 	UserOnly       bool `schema:"userOnly" binding:"omitempty"`
 	UserID         uint64
+	SellerUsername string        `schema:"sellerUsername" binding:"omitempty"`
+	ShopID         string        `schema:"shopId" binding:"omitempty"`
 	Tags           []string      `schema:"tags" binding:"omitempty"`
 	Condition      Condition     `schema:"condition" binding:"omitempty"`
 	ShowEmptyStock bool          `schema:"showEmptyStock" binding:"omitempty"`
@@ -65,6 +69,8 @@ type ListProductPayload struct {
 func (p ListProductPayload) Validate() error {
 	return validation.ValidateStruct(&p,
 		validation.Field(&p.UserID, validation.When(p.UserOnly, validation.Required.Error(ErrorUnauthorized.Message))),
+		validation.Field(&p.SellerUsername, validation.Length(5, 15)),
+		validation.Field(&p.ShopID, is.UUID),
 		validation.Field(&p.Condition, validation.In(Conditions...)),
 		validation.Field(&p.MinPrice, validation.When(p.MaxPrice != 0, validation.Max(p.MaxPrice))),
 		validation.Field(&p.MaxPrice, validation.When(p.MinPrice != 0, validation.Min(p.MinPrice))),
@@ -116,17 +122,22 @@ func (p GetProductPayload) Validate() error {
 
 type PurchaseProductPayload struct {
 	ProductUID           uuid.UUID
-	BankAccountID        uuid.UUID `json:"bankAccountId"`
-	PaymentProofImageURL string    `json:"paymentProofImageUrl"`
-	Quantity             int       `json:"quantity"`
+	PaymentMethod        payment.Method `json:"paymentMethod"`
+	BankAccountID        uuid.UUID      `json:"bankAccountId"`
+	AddressID            uuid.UUID      `json:"addressId"`
+	PaymentProofImageURL string         `json:"paymentProofImageUrl"`
+	Quantity             int            `json:"quantity"`
 	BuyerID              uint64
 	SellerID             uint64
 }
 
 func (p PurchaseProductPayload) Validate() error {
+	isTransfer := p.PaymentMethod == "" || p.PaymentMethod == payment.MethodTransfer
 	return validation.ValidateStruct(&p,
-		validation.Field(&p.BankAccountID, validation.Required.Error(ErrorRequiredField.Message), is.UUID),
-		validation.Field(&p.PaymentProofImageURL, validation.Required.Error(ErrorRequiredField.Message), is.URL),
+		validation.Field(&p.PaymentMethod, validation.In(payment.Methods...)),
+		validation.Field(&p.BankAccountID, validation.When(isTransfer, is.UUID)),
+		validation.Field(&p.AddressID, validation.Required.Error(ErrorRequiredField.Message), is.UUID),
+		validation.Field(&p.PaymentProofImageURL, is.URL),
 		validation.Field(&p.Quantity, validation.Required.Error(ErrorRequiredField.Message), validation.Min(1)),
 		validation.Field(&p.BuyerID, validation.Required.Error(ErrorUnauthorized.Message)),
 	)
@@ -155,3 +166,43 @@ func (p DeleteProductPayload) Validate() error {
 		validation.Field(&p.UserID, validation.Required.Error(ErrorUnauthorized.Message)),
 	)
 }
+
+type ModerateProductPayload struct {
+	ProductUID uuid.UUID        `json:"-"`
+	Status     ModerationStatus `json:"-"`
+	Reason     string           `json:"reason"`
+}
+
+func (p ModerateProductPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Reason, validation.When(p.Status != ModerationListed, validation.Required.Error(ErrorRequiredField.Message)), validation.Length(0, 500)),
+	)
+}
+
+type ListUserTransactionsPayload struct {
+	UserID uint64 `schema:"-"`
+	Limit  int    `schema:"limit"`
+	Offset int    `schema:"offset"`
+}
+
+func (p ListUserTransactionsPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Limit, validation.Min(0), validation.Max(100)),
+		validation.Field(&p.Offset, validation.Min(0)),
+	)
+}
+
+type ListShopSalesPayload struct {
+	ShopUID uuid.UUID `schema:"-"`
+	UserID  uint64    `schema:"-"`
+	Limit   int       `schema:"limit"`
+	Offset  int       `schema:"offset"`
+}
+
+func (p ListShopSalesPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.UserID, validation.Required.Error(ErrorUnauthorized.Message)),
+		validation.Field(&p.Limit, validation.Min(0), validation.Max(100)),
+		validation.Field(&p.Offset, validation.Min(0)),
+	)
+}
@@ -1,8 +1,13 @@
 package product
 
 import (
+	"slices"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
 	bankaccount "github.com/citadel-corp/shopifyx-marketplace/internal/bank_account"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
 	"github.com/google/uuid"
 )
@@ -23,6 +28,7 @@ var (
 	SuccessPurchaseResponse    = Response{Code: 200, Message: "Product purchased successfully"}
 	SuccessUpdateStockResponse = Response{Code: 200, Message: "Stock updated successfully"}
 	SuccessDeleteResponse      = Response{Code: 200, Message: "Product deleted successfully"}
+	SuccessModerateResponse    = Response{Code: 200, Message: "Product moderated successfully"}
 )
 
 type ProductResponse struct {
@@ -35,10 +41,15 @@ type ProductResponse struct {
 	IsPurchasable bool      `json:"isPurchasable"`
 	Price         int       `json:"price"`
 	PurchaseCount int       `json:"purchaseCount"`
+
+	ModerationStatus ModerationStatus `json:"moderationStatus,omitempty"`
+	ModerationReason string           `json:"moderationReason,omitempty"`
 }
 
+// CreateProductResponse only includes the moderation of products that are not
+// listed, which only their seller gets to see.
 func CreateProductResponse(product Product) ProductResponse {
-	return ProductResponse{
+	resp := ProductResponse{
 		UUID:          product.UUID,
 		Name:          product.Name,
 		ImageURL:      product.ImageURL,
@@ -49,6 +60,11 @@ func CreateProductResponse(product Product) ProductResponse {
 		Price:         product.Price,
 		PurchaseCount: product.PurchaseCount,
 	}
+	if !product.IsListed() {
+		resp.ModerationStatus = product.ModerationStatus
+		resp.ModerationReason = product.ModerationReason
+	}
+	return resp
 }
 
 type SellerResponse struct {
@@ -62,17 +78,33 @@ func CreateSellerResponse(user *user.User, bankAccounts []*bankaccount.BankAccou
 		return SellerResponse{}
 	}
 
-	accts := make([]bankaccount.BankAccountResponse, len(bankAccounts))
-	for i, bankAccount := range bankAccounts {
-		if bankAccount != nil {
-			accts[i] = bankaccount.BankAccountResponse{
+	// buyers only get to see accounts the seller proved to own
+	accts := make([]bankaccount.BankAccountResponse, 0, len(bankAccounts))
+	for _, bankAccount := range bankAccounts {
+		if bankAccount != nil && bankAccount.Verification.Status == bankaccount.VerificationVerified {
+			accts = append(accts, bankaccount.BankAccountResponse{
 				BankAccountID:     bankAccount.UUID.String(),
+				BankCode:          bankAccount.BankCode,
 				BankName:          bankAccount.BankName,
 				BankAccountName:   bankAccount.BankAccountName,
-				BankAccountNumber: bankAccount.BankAccountNumber,
-			}
+				BankAccountNumber: bankaccount.MaskAccountNumber(bankAccount.BankAccountNumber),
+				IsPrimary:         bankAccount.IsPrimary,
+
+				VerificationStatus: bankAccount.Verification.Status,
+			})
 		}
 	}
+	// the primary account is the one buyers pay into by default, show it first
+	slices.SortStableFunc(accts, func(a, b bankaccount.BankAccountResponse) int {
+		switch {
+		case a.IsPrimary == b.IsPrimary:
+			return 0
+		case a.IsPrimary:
+			return -1
+		default:
+			return 1
+		}
+	})
 
 	return SellerResponse{
 		Name:             user.Name,
@@ -81,7 +113,138 @@ func CreateSellerResponse(user *user.User, bankAccounts []*bankaccount.BankAccou
 	}
 }
 
+// StorefrontResponse is the public page of a seller. Rating stays null until buyers
+// can rate sellers; bank accounts are only shown with a product.
+type StorefrontResponse struct {
+	Username         string            `json:"username"`
+	Name             string            `json:"name"`
+	JoinedAt         *time.Time        `json:"joinedAt"`
+	ProductSoldTotal int               `json:"productSoldTotal"`
+	Rating           *float64          `json:"rating"`
+	Products         []ProductResponse `json:"products"`
+}
+
+func CreateStorefrontResponse(seller *user.User, products []Product) StorefrontResponse {
+	resp := StorefrontResponse{
+		Username:         seller.Username,
+		Name:             seller.Name,
+		ProductSoldTotal: seller.ProductSoldTotal,
+		Products:         make([]ProductResponse, 0, len(products)),
+	}
+	// users registered before the creation date was recorded have none
+	if seller.CreatedAt.Valid {
+		resp.JoinedAt = &seller.CreatedAt.Time
+	}
+	for _, product := range products {
+		resp.Products = append(resp.Products, CreateProductResponse(product))
+	}
+	return resp
+}
+
 type ProductDetailResponse struct {
 	Product ProductResponse `json:"product"`
 	Seller  SellerResponse  `json:"seller"`
 }
+
+type PurchaseResponse struct {
+	TransactionID        uuid.UUID                 `json:"transactionId"`
+	Amount               int                       `json:"amount"`
+	ShippingFee          int                       `json:"shippingFee"`
+	ShippingAddress      address.Snapshot          `json:"shippingAddress"`
+	PaymentMethod        payment.Method            `json:"paymentMethod"`
+	Status               payment.TransactionStatus `json:"status"`
+	PaymentProofImageURL string                    `json:"paymentProofImageUrl,omitempty"`
+	Payment              *payment.PaymentResponse  `json:"payment,omitempty"`
+	Transfer             *TransferResponse         `json:"transfer,omitempty"`
+}
+
+// TransferResponse tells the buyer where to transfer and what to write in the
+// transfer description so the payment can be reconciled.
+type TransferResponse struct {
+	BankAccount bankaccount.BankAccountResponse `json:"bankAccount"`
+	Reference   string                          `json:"reference"`
+	Amount      int                             `json:"amount"`
+}
+
+func CreatePurchaseResponse(trx *Transaction) PurchaseResponse {
+	resp := PurchaseResponse{
+		TransactionID:        trx.UUID,
+		Amount:               trx.Amount,
+		ShippingFee:          trx.ShippingFee,
+		ShippingAddress:      trx.ShippingAddress,
+		PaymentMethod:        trx.PaymentMethod,
+		Status:               trx.Status,
+		PaymentProofImageURL: trx.PaymentProofImageURL,
+		Payment:              payment.CreatePaymentResponse(trx.Payment),
+	}
+	if trx.PaymentMethod == payment.MethodTransfer && trx.BankAccount != nil && trx.Payment != nil {
+		resp.Transfer = &TransferResponse{
+			BankAccount: bankaccount.BankAccountResponse{
+				BankAccountID:     trx.BankAccount.UUID.String(),
+				BankCode:          trx.BankAccount.BankCode,
+				BankName:          trx.BankAccount.BankName,
+				BankAccountName:   trx.BankAccount.BankAccountName,
+				BankAccountNumber: trx.BankAccount.BankAccountNumber,
+			},
+			Reference: trx.Payment.ChargeID,
+			Amount:    trx.Payment.Amount,
+		}
+	}
+	return resp
+}
+
+// SaleResponse is what the owner and members of a shop see of a purchase.
+type SaleResponse struct {
+	TransactionID        uuid.UUID                 `json:"transactionId"`
+	ProductID            uuid.UUID                 `json:"productId"`
+	Quantity             int                       `json:"quantity"`
+	Amount               int                       `json:"amount"`
+	ShippingFee          int                       `json:"shippingFee"`
+	PaymentMethod        payment.Method            `json:"paymentMethod"`
+	Status               payment.TransactionStatus `json:"status"`
+	PaymentProofImageURL string                    `json:"paymentProofImageUrl,omitempty"`
+	CreatedAt            time.Time                 `json:"createdAt"`
+}
+
+func CreateSaleResponse(trx *Transaction) SaleResponse {
+	return SaleResponse{
+		TransactionID:        trx.UUID,
+		ProductID:            trx.ProductUID,
+		Quantity:             trx.Quantity,
+		Amount:               trx.Amount,
+		ShippingFee:          trx.ShippingFee,
+		PaymentMethod:        trx.PaymentMethod,
+		Status:               trx.Status,
+		PaymentProofImageURL: trx.PaymentProofImageURL,
+		CreatedAt:            trx.CreatedAt,
+	}
+}
+
+// AdminTransactionResponse is what admins see of a purchase.
+type AdminTransactionResponse struct {
+	TransactionID uuid.UUID                 `json:"transactionId"`
+	ProductID     uuid.UUID                 `json:"productId"`
+	BuyerID       uint64                    `json:"buyerId"`
+	SellerID      uint64                    `json:"sellerId"`
+	Quantity      int                       `json:"quantity"`
+	Amount        int                       `json:"amount"`
+	ShippingFee   int                       `json:"shippingFee"`
+	PaymentMethod payment.Method            `json:"paymentMethod"`
+	Status        payment.TransactionStatus `json:"status"`
+	CreatedAt     time.Time                 `json:"createdAt"`
+}
+
+func CreateAdminTransactionResponse(trx *Transaction) AdminTransactionResponse {
+	return AdminTransactionResponse{
+		TransactionID: trx.UUID,
+		ProductID:     trx.ProductUID,
+		BuyerID:       trx.BuyerID,
+		SellerID:      trx.SellerID,
+		Quantity:      trx.Quantity,
+		Amount:        trx.Amount,
+		ShippingFee:   trx.ShippingFee,
+		PaymentMethod: trx.PaymentMethod,
+		Status:        trx.Status,
+		CreatedAt:     trx.CreatedAt,
+	}
+}
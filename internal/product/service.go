@@ -4,39 +4,80 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
 
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
 	bankaccount "github.com/citadel-corp/shopifyx-marketplace/internal/bank_account"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/shipping"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/shop"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
+	"github.com/google/uuid"
 )
 
 type ProductService struct {
-	repository     Repository
-	userRepository user.Repository
-	bankRepository bankaccount.Repository
+	repository         Repository
+	userRepository     user.Repository
+	bankRepository     bankaccount.Repository
+	addressRepository  address.Repository
+	paymentService     payment.Service
+	shippingCalculator shipping.Calculator
+	shopService        shop.Service
 }
 
 type Service interface {
 	Create(ctx context.Context, req CreateProductPayload) Response
 	List(ctx context.Context, req ListProductPayload) Response
+	GetStorefront(ctx context.Context, req ListProductPayload) Response
 	Update(ctx context.Context, req UpdateProductPayload) Response
 	Get(ctx context.Context, req GetProductPayload) Response
 	Purchase(ctx context.Context, req PurchaseProductPayload) Response
 	UpdateStock(ctx context.Context, req UpdateStockPayload) Response
 	Delete(ctx context.Context, req DeleteProductPayload) Response
+	Moderate(ctx context.Context, req ModerateProductPayload) Response
+	ListUserTransactions(ctx context.Context, req ListUserTransactionsPayload) Response
+	ListShopSales(ctx context.Context, req ListShopSalesPayload) Response
 }
 
-func NewService(repository Repository, userRepository user.Repository, bankRepository bankaccount.Repository) Service {
+// maxTransferAttempts is how many transfer references are tried before a purchase fails.
+const maxTransferAttempts = 5
+
+func NewService(repository Repository, userRepository user.Repository, bankRepository bankaccount.Repository,
+	addressRepository address.Repository, paymentService payment.Service, shippingCalculator shipping.Calculator, shopService shop.Service) Service {
 	return &ProductService{
-		repository:     repository,
-		userRepository: userRepository,
-		bankRepository: bankRepository,
+		repository:         repository,
+		userRepository:     userRepository,
+		bankRepository:     bankRepository,
+		addressRepository:  addressRepository,
+		paymentService:     paymentService,
+		shippingCalculator: shippingCalculator,
+		shopService:        shopService,
 	}
 }
 
+// Create adds a product to req.ShopID, or to the default shop of the user when it is
+// not set.
 func (s *ProductService) Create(ctx context.Context, req CreateProductPayload) Response {
 	serviceName := "product.Create"
 
+	var sh *shop.Shop
+	var err error
+	if req.ShopID == uuid.Nil {
+		sh, err = s.shopService.DefaultShop(ctx, req.UserID)
+	} else {
+		sh, err = s.shopService.Authorized(ctx, req.ShopID, req.UserID, shop.PermissionManageProducts)
+	}
+	if err != nil {
+		if resp, ok := shopErrorResponse(err); ok {
+			return resp
+		}
+		slog.Error(fmt.Sprintf("%s: error fetching shop: %v", serviceName, err))
+		return ErrorInternal
+	}
+
 	product := &Product{
 		Name:          req.Name,
 		ImageURL:      req.ImageURL,
@@ -45,12 +86,13 @@ func (s *ProductService) Create(ctx context.Context, req CreateProductPayload) R
 		Tags:          req.Tags,
 		IsPurchasable: req.IsPurchasable,
 		Price:         req.Price,
+		ShopID:        sh.ID,
 		User: user.User{
-			ID: req.UserID,
+			ID: sh.OwnerID,
 		},
 	}
 
-	err := s.repository.Create(ctx, product)
+	err = s.repository.Create(ctx, product)
 	if err != nil {
 		slog.Error(serviceName + ": " + err.Error())
 		return ErrorInternal
@@ -87,6 +129,41 @@ func (s *ProductService) List(ctx context.Context, req ListProductPayload) Respo
 	return resp
 }
 
+// storefrontLimit is how many products a storefront page shows when no limit is given.
+const storefrontLimit = 10
+
+// GetStorefront shows the public profile of the seller req.SellerUsername with a page
+// of their listed products, filtered like List.
+func (s *ProductService) GetStorefront(ctx context.Context, req ListProductPayload) Response {
+	serviceName := "product.GetStorefront"
+
+	seller, err := s.userRepository.GetByUsername(ctx, req.SellerUsername)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return ErrorNotFound
+		}
+		slog.Error(fmt.Sprintf("%s: error fetching seller: %v", serviceName, err))
+		return ErrorInternal
+	}
+	if seller.IsDeleted() || !slices.Contains(seller.Roles, role.Seller) {
+		return ErrorNotFound
+	}
+
+	if req.Limit == 0 {
+		req.Limit = storefrontLimit
+	}
+	products, pagination, err := s.repository.List(ctx, req)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s: error fetching products list: %v", serviceName, err))
+		return ErrorInternal
+	}
+
+	resp := SuccessGetResponse
+	resp.Data = CreateStorefrontResponse(seller, products)
+	resp.Meta = pagination
+	return resp
+}
+
 func (s *ProductService) Update(ctx context.Context, req UpdateProductPayload) Response {
 	serviceName := "product.Update"
 
@@ -100,8 +177,12 @@ func (s *ProductService) Update(ctx context.Context, req UpdateProductPayload) R
 		return ErrorInternal
 	}
 
-	if oldP.User.ID != req.UserID {
-		return ErrorForbidden
+	if resp, ok := s.authorize(ctx, oldP, req.UserID, shop.PermissionManageProducts); !ok {
+		return resp
+	}
+
+	if oldP.ModerationStatus == ModerationRemoved {
+		return ErrorNotFound
 	}
 
 	newP := &Product{
@@ -112,9 +193,6 @@ func (s *ProductService) Update(ctx context.Context, req UpdateProductPayload) R
 		Condition:     req.Condition,
 		Tags:          req.Tags,
 		IsPurchasable: req.IsPurchasable,
-		User: user.User{
-			ID: req.UserID,
-		},
 	}
 
 	err = s.repository.Update(ctx, newP)
@@ -138,13 +216,17 @@ func (s *ProductService) Get(ctx context.Context, req GetProductPayload) Respons
 		return ErrorInternal
 	}
 
+	if !product.IsListed() {
+		return ErrorNotFound
+	}
+
 	user, err := s.userRepository.GetByID(ctx, product.User.ID)
 	if err != nil {
 		slog.Error("%s: error fetching product: %v", serviceName, err)
 		return ErrorInternal
 	}
 
-	accts, err := s.bankRepository.List(ctx, product.User.ID)
+	accts, err := s.bankRepository.List(ctx, product.ShopID)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			slog.Error("%s: error fetching product: %v", serviceName, err)
@@ -188,6 +270,10 @@ func (s *ProductService) Purchase(ctx context.Context, req PurchaseProductPayloa
 
 	req.SellerID = product.User.ID
 
+	if !product.IsListed() {
+		return ErrorNotFound
+	}
+
 	if !product.IsPurchasable {
 		return ErrorNotPurchasable
 	}
@@ -196,28 +282,116 @@ func (s *ProductService) Purchase(ctx context.Context, req PurchaseProductPayloa
 		return ErrorInsufficientStock
 	}
 
-	// check bank account validity
-	acct, err := s.bankRepository.GetByUUID(ctx, req.BankAccountID)
+	// snapshot the shipping address
+	addr, err := s.addressRepository.GetByUUID(ctx, req.AddressID)
 	if err != nil {
-		if errors.Is(err, bankaccount.ErrNotFound) {
+		if errors.Is(err, address.ErrNotFound) {
 			return ErrorBadRequest
 		}
-		slog.Error("%s: error fetching bank: %v", serviceName, err)
+		slog.Error(fmt.Sprintf("%s: error fetching address: %v", serviceName, err))
 		return ErrorInternal
 	}
 
-	if acct.User.ID != req.SellerID {
-		slog.Error("%s: bank does not belong to product owner: %v", serviceName, err)
+	if addr.UserID != req.BuyerID {
 		return ErrorBadRequest
 	}
 
-	err = s.repository.Purchase(ctx, req)
+	trx := &Transaction{
+		UUID:                 uuid.New(),
+		Quantity:             req.Quantity,
+		PaymentMethod:        req.PaymentMethod,
+		ShippingAddress:      addr.Snapshot(),
+		PaymentProofImageURL: req.PaymentProofImageURL,
+	}
+
+	subtotal := product.Price * req.Quantity
+	trx.ShippingFee, err = s.shippingCalculator.Fee(ctx, shipping.FeeRequest{
+		Destination: trx.ShippingAddress,
+		Quantity:    req.Quantity,
+		Subtotal:    subtotal,
+	})
 	if err != nil {
-		slog.Error("%s: error purchasing product: %v", serviceName, err)
+		slog.Error(fmt.Sprintf("%s: error calculating shipping fee: %v", serviceName, err))
 		return ErrorInternal
 	}
+	trx.Amount = subtotal + trx.ShippingFee
+
+	switch req.PaymentMethod {
+	case payment.MethodGateway:
+		trx.Status = payment.TransactionPending
+		trx.Payment, err = s.paymentService.CreateCharge(ctx, payment.ChargeRequest{
+			Reference:   trx.UUID.String(),
+			Amount:      trx.Amount,
+			Description: fmt.Sprintf("%d x %s", req.Quantity, product.Name),
+		})
+		if err != nil {
+			slog.Error(fmt.Sprintf("%s: error creating charge: %v", serviceName, err))
+			return ErrorInternal
+		}
+	default:
+		trx.PaymentMethod = payment.MethodTransfer
+		trx.Status = payment.TransactionPending
+
+		// check bank account validity, buyers who do not pick one pay into the shop's primary account
+		if req.BankAccountID == uuid.Nil {
+			trx.BankAccount, err = s.bankRepository.GetPrimary(ctx, product.ShopID)
+		} else {
+			trx.BankAccount, err = s.bankRepository.GetByUUID(ctx, req.BankAccountID)
+		}
+		if err != nil {
+			if errors.Is(err, bankaccount.ErrNotFound) && req.BankAccountID == uuid.Nil {
+				return ErrorNoBankAccount
+			}
+			if errors.Is(err, bankaccount.ErrNotFound) {
+				return ErrorBadRequest
+			}
+			slog.Error("%s: error fetching bank: %v", serviceName, err)
+			return ErrorInternal
+		}
+
+		if trx.BankAccount.IsArchived() {
+			return ErrorBadRequest
+		}
+		if trx.BankAccount.Verification.Status != bankaccount.VerificationVerified {
+			return ErrorBankAccountUnverified
+		}
+		if trx.BankAccount.ShopID != product.ShopID {
+			slog.Error("%s: bank does not belong to product shop: %v", serviceName, err)
+			return ErrorBadRequest
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		if trx.PaymentMethod == payment.MethodTransfer {
+			trx.Payment, err = s.paymentService.CreateTransfer(ctx, trx.Amount, trx.BankAccount.UUID)
+			if err != nil {
+				slog.Error(fmt.Sprintf("%s: error creating transfer: %v", serviceName, err))
+				return ErrorInternal
+			}
+		}
 
-	return SuccessPurchaseResponse
+		err = s.repository.Purchase(ctx, req, trx)
+		// transfer references and amounts are random, retry the rare collision
+		if errors.Is(err, payment.ErrDuplicateTransfer) && attempt < maxTransferAttempts {
+			continue
+		}
+		if err != nil {
+			slog.Error("%s: error purchasing product: %v", serviceName, err)
+			if trx.PaymentMethod == payment.MethodGateway {
+				// nothing was stored, the charge must not be paid
+				if err := s.paymentService.CancelCharge(ctx, trx.Payment); err != nil {
+					slog.Error(fmt.Sprintf("%s: error canceling charge %s: %v", serviceName, trx.Payment.ChargeID, err))
+				}
+			}
+			return ErrorInternal
+		}
+		break
+	}
+
+	resp := SuccessPurchaseResponse
+	resp.Data = CreatePurchaseResponse(trx)
+
+	return resp
 }
 
 func (s *ProductService) UpdateStock(ctx context.Context, req UpdateStockPayload) Response {
@@ -232,8 +406,12 @@ func (s *ProductService) UpdateStock(ctx context.Context, req UpdateStockPayload
 		return ErrorInternal
 	}
 
-	if p.User.ID != req.UserID {
-		return ErrorForbidden
+	if resp, ok := s.authorize(ctx, p, req.UserID, shop.PermissionManageStock); !ok {
+		return resp
+	}
+
+	if p.ModerationStatus == ModerationRemoved {
+		return ErrorNotFound
 	}
 
 	product := &Product{
@@ -264,8 +442,8 @@ func (s *ProductService) Delete(ctx context.Context, req DeleteProductPayload) R
 		return ErrorInternal
 	}
 
-	if product.User.ID != req.UserID {
-		return ErrorForbidden
+	if resp, ok := s.authorize(ctx, product, req.UserID, shop.PermissionManageProducts); !ok {
+		return resp
 	}
 
 	err = s.repository.Delete(ctx, req.ProductUID)
@@ -279,3 +457,101 @@ func (s *ProductService) Delete(ctx context.Context, req DeleteProductPayload) R
 
 	return SuccessDeleteResponse
 }
+
+// Moderate sets the moderation status of a product on behalf of an admin.
+func (s *ProductService) Moderate(ctx context.Context, req ModerateProductPayload) Response {
+	serviceName := "product.Moderate"
+
+	err := s.repository.Moderate(ctx, req.ProductUID, req.Status, req.Reason)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrorNotFound
+		}
+		slog.Error(fmt.Sprintf("%s: error moderating product: %v", serviceName, err))
+		return ErrorInternal
+	}
+
+	return SuccessModerateResponse
+}
+
+// ListUserTransactions lists the purchases a user made or sold for an admin.
+func (s *ProductService) ListUserTransactions(ctx context.Context, req ListUserTransactionsPayload) Response {
+	serviceName := "product.ListUserTransactions"
+
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+	transactions, pagination, err := s.repository.ListUserTransactions(ctx, req)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s: error fetching transactions: %v", serviceName, err))
+		return ErrorInternal
+	}
+
+	data := make([]AdminTransactionResponse, len(transactions))
+	for i, trx := range transactions {
+		data[i] = CreateAdminTransactionResponse(trx)
+	}
+
+	resp := SuccessListResponse
+	resp.Data = data
+	resp.Meta = pagination
+
+	return resp
+}
+
+// ListShopSales lists the purchases of the products of a shop for its owner and
+// members allowed to view sales.
+func (s *ProductService) ListShopSales(ctx context.Context, req ListShopSalesPayload) Response {
+	serviceName := "product.ListShopSales"
+
+	sh, err := s.shopService.Authorized(ctx, req.ShopUID, req.UserID, shop.PermissionViewSales)
+	if err != nil {
+		if resp, ok := shopErrorResponse(err); ok {
+			return resp
+		}
+		slog.Error(fmt.Sprintf("%s: error fetching shop: %v", serviceName, err))
+		return ErrorInternal
+	}
+
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+	transactions, pagination, err := s.repository.ListShopSales(ctx, sh.ID, req.Limit, req.Offset)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s: error fetching sales: %v", serviceName, err))
+		return ErrorInternal
+	}
+
+	data := make([]SaleResponse, len(transactions))
+	for i, trx := range transactions {
+		data[i] = CreateSaleResponse(trx)
+	}
+
+	resp := SuccessListResponse
+	resp.Data = data
+	resp.Meta = pagination
+	return resp
+}
+
+// authorize checks the user may change the product through the shop selling it.
+func (s *ProductService) authorize(ctx context.Context, product *Product, userID uint64, permission shop.Permission) (Response, bool) {
+	err := s.shopService.Authorize(ctx, product.ShopID, userID, permission)
+	if err != nil {
+		if resp, ok := shopErrorResponse(err); ok {
+			return resp, false
+		}
+		slog.Error(fmt.Sprintf("product.authorize: error fetching shop: %v", err))
+		return ErrorInternal, false
+	}
+	return Response{}, true
+}
+
+func shopErrorResponse(err error) (Response, bool) {
+	switch {
+	case errors.Is(err, shop.ErrNotFound):
+		return ErrorNotFound, true
+	case errors.Is(err, shop.ErrForbidden):
+		return ErrorForbidden, true
+	}
+	return Response{}, false
+}
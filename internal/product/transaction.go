@@ -0,0 +1,32 @@
+package product
+
+import (
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
+	bankaccount "github.com/citadel-corp/shopifyx-marketplace/internal/bank_account"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/google/uuid"
+)
+
+// Transaction is a purchase. Its Amount is what the buyer owes, shipping included.
+type Transaction struct {
+	ID              uint64
+	UUID            uuid.UUID
+	ProductUID      uuid.UUID
+	BuyerID         uint64
+	SellerID        uint64
+	Quantity        int
+	Amount          int
+	ShippingFee     int
+	ShippingAddress address.Snapshot
+	PaymentMethod   payment.Method
+	Status          payment.TransactionStatus
+	// PaymentProofImageURL is the transfer receipt clients uploaded before purchases
+	// were reconciled with bank statements. It is kept for the seller to see, it does
+	// not mark the purchase paid.
+	PaymentProofImageURL string
+	Payment              *payment.Payment
+	BankAccount          *bankaccount.BankAccount
+	CreatedAt            time.Time
+}
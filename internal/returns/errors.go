@@ -0,0 +1,13 @@
+package returns
+
+import "errors"
+
+var (
+	ErrValidationFailed    = errors.New("validation failed")
+	ErrNotFound            = errors.New("return not found")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrForbidden           = errors.New("you are forbidden to make changes to this return")
+	ErrTransactionNotPaid  = errors.New("only paid purchases can be returned")
+	ErrAlreadyRequested    = errors.New("a return was already requested for this purchase")
+	ErrInvalidStatus       = errors.New("return cannot be changed in its current status")
+)
@@ -0,0 +1,272 @@
+package returns
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/audit"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/middleware"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/request"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateReturn(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+
+	var req CreateReturnPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	returnResp, err := h.service.Create(r.Context(), req, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusCreated, response.ResponseBody{
+		Message: "Return requested successfully",
+		Data:    returnResp,
+	})
+}
+
+func (h *Handler) ListReturn(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+
+	var req ListReturnPayload
+
+	newSchema := schema.NewDecoder()
+	newSchema.IgnoreUnknownKeys(true)
+	if err = newSchema.Decode(&req, r.URL.Query()); err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.UserID = userID
+
+	returnResp, err := h.service.List(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    returnResp,
+	})
+}
+
+func (h *Handler) GetReturn(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	uid, err := uuid.Parse(mux.Vars(r)["returnId"])
+	if err != nil {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	returnResp, err := h.service.Get(r.Context(), uid, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    returnResp,
+	})
+}
+
+func (h *Handler) AcceptReturn(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	uid, err := uuid.Parse(mux.Vars(r)["returnId"])
+	if err != nil {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	returnResp, err := h.service.Accept(r.Context(), uid, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Return accepted successfully",
+		Data:    returnResp,
+	})
+}
+
+func (h *Handler) DeclineReturn(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	uid, err := uuid.Parse(mux.Vars(r)["returnId"])
+	if err != nil {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	var req NotePayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	returnResp, err := h.service.Decline(r.Context(), req, uid, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Return declined successfully",
+		Data:    returnResp,
+	})
+}
+
+func (h *Handler) DisputeReturn(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	uid, err := uuid.Parse(mux.Vars(r)["returnId"])
+	if err != nil {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	var req NotePayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	returnResp, err := h.service.Dispute(r.Context(), req, uid, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Return disputed successfully",
+		Data:    returnResp,
+	})
+}
+
+func (h *Handler) ListDisputedReturn(w http.ResponseWriter, r *http.Request) {
+	returnResp, err := h.service.ListDisputed(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    returnResp,
+	})
+}
+
+func (h *Handler) ResolveReturn(w http.ResponseWriter, r *http.Request) {
+	uid, err := uuid.Parse(mux.Vars(r)["returnId"])
+	if err != nil {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	var req ResolveReturnPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	audit.SetReason(r.Context(), req.Note)
+
+	returnResp, err := h.service.Resolve(r.Context(), req, uid)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Return resolved successfully",
+		Data:    returnResp,
+	})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrValidationFailed):
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrTransactionNotFound):
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrForbidden):
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrAlreadyRequested), errors.Is(err, ErrInvalidStatus), errors.Is(err, ErrTransactionNotPaid):
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+	default:
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package returns
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	GetTransaction(ctx context.Context, uid uuid.UUID) (*Transaction, error)
+	Create(ctx context.Context, r *Return) error
+	GetByUUID(ctx context.Context, uid uuid.UUID) (*Return, error)
+	List(ctx context.Context, filter ListReturnPayload) ([]*Return, error)
+	Update(ctx context.Context, r *Return, from Status) error
+	Accept(ctx context.Context, r *Return, from Status) error
+}
+
+type dbRepository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) Repository {
+	return &dbRepository{db: db}
+}
+
+const selectReturnQuery = `
+	SELECT r.id, r.uid, r.buyer_id, r.seller_id, r.reason, r.evidence_image_urls, r.status,
+		COALESCE(r.seller_note, ''), COALESCE(r.buyer_note, ''), COALESCE(r.admin_note, ''), r.created_at, r.updated_at,
+		t.id, t.uid, t.product_id, t.quantity, t.amount, t.status
+	FROM return_requests r
+	INNER JOIN user_transactions t ON t.id = r.transaction_id
+`
+
+// GetTransaction implements Repository.
+func (d *dbRepository) GetTransaction(ctx context.Context, uid uuid.UUID) (*Transaction, error) {
+	getQuery := `
+		SELECT t.id, t.uid, t.product_id, t.user_id, p.user_id, t.quantity, t.amount, t.status
+		FROM user_transactions t
+		INNER JOIN products p ON p.uid = t.product_id
+		WHERE t.uid = $1;
+	`
+	row := d.db.DB().QueryRowContext(ctx, getQuery, uid)
+	t := &Transaction{}
+	err := row.Scan(&t.ID, &t.UUID, &t.ProductUID, &t.BuyerID, &t.SellerID, &t.Quantity, &t.Amount, &t.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Create implements Repository.
+func (d *dbRepository) Create(ctx context.Context, r *Return) error {
+	createQuery := `
+		INSERT INTO return_requests (
+			transaction_id, buyer_id, seller_id, reason, evidence_image_urls, status
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		)
+		RETURNING id, uid, created_at, updated_at;
+	`
+	row := d.db.DB().QueryRowContext(ctx, createQuery, r.Transaction.ID, r.BuyerID, r.SellerID, r.Reason, pq.Array(r.EvidenceImageURLs), r.Status)
+	err := row.Scan(&r.ID, &r.UUID, &r.CreatedAt, &r.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrAlreadyRequested
+	}
+	return err
+}
+
+// GetByUUID implements Repository.
+func (d *dbRepository) GetByUUID(ctx context.Context, uid uuid.UUID) (*Return, error) {
+	row := d.db.DB().QueryRowContext(ctx, selectReturnQuery+" WHERE r.uid = $1;", uid)
+	r, err := scanReturn(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// List implements Repository. Without a user ID every return matching the status is listed.
+func (d *dbRepository) List(ctx context.Context, filter ListReturnPayload) ([]*Return, error) {
+	var (
+		whereStatement string
+		args           []interface{}
+	)
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		switch filter.As {
+		case "buyer":
+			whereStatement = fmt.Sprintf("WHERE r.buyer_id = $%d", len(args))
+		case "seller":
+			whereStatement = fmt.Sprintf("WHERE r.seller_id = $%d", len(args))
+		default:
+			whereStatement = fmt.Sprintf("WHERE (r.buyer_id = $%d OR r.seller_id = $%d)", len(args), len(args))
+		}
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		if whereStatement == "" {
+			whereStatement = fmt.Sprintf("WHERE r.status = $%d", len(args))
+		} else {
+			whereStatement = fmt.Sprintf("%s AND r.status = $%d", whereStatement, len(args))
+		}
+	}
+
+	rows, err := d.db.DB().QueryContext(ctx, fmt.Sprintf("%s %s ORDER BY r.created_at;", selectReturnQuery, whereStatement), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var returns []*Return
+	for rows.Next() {
+		r, err := scanReturn(rows)
+		if err != nil {
+			return nil, err
+		}
+		returns = append(returns, r)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+// Update implements Repository. The update only applies while the return is still
+// in the from status, so two concurrent decisions cannot both succeed.
+func (d *dbRepository) Update(ctx context.Context, r *Return, from Status) error {
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		return updateReturn(ctx, tx, r, from)
+	})
+}
+
+// Accept implements Repository. Accepting puts the stock back, undoes the purchase
+// counters and marks the transaction refunded.
+func (d *dbRepository) Accept(ctx context.Context, r *Return, from Status) error {
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		err := updateReturn(ctx, tx, r, from)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE products
+			SET stock = stock + $1,
+			purchase_count = purchase_count - $1
+			WHERE uid = $2;
+		`, r.Transaction.Quantity, r.Transaction.ProductUID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE users
+			SET product_sold_total = product_sold_total - $1
+			WHERE id = $2;
+		`, r.Transaction.Quantity, r.SellerID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE user_transactions
+			SET status = $1
+			WHERE id = $2;
+		`, payment.TransactionRefunded, r.Transaction.ID)
+		return err
+	})
+}
+
+func updateReturn(ctx context.Context, tx *sql.Tx, r *Return, from Status) error {
+	row := tx.QueryRowContext(ctx, `
+		UPDATE return_requests
+		SET status = $1,
+		seller_note = NULLIF($2, ''),
+		buyer_note = NULLIF($3, ''),
+		admin_note = NULLIF($4, ''),
+		updated_at = current_timestamp
+		WHERE id = $5
+		AND status = $6
+		RETURNING updated_at;
+	`, r.Status, r.SellerNote, r.BuyerNote, r.AdminNote, r.ID, from)
+	err := row.Scan(&r.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidStatus
+	}
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReturn(row scanner) (*Return, error) {
+	r := &Return{}
+	err := row.Scan(&r.ID, &r.UUID, &r.BuyerID, &r.SellerID, &r.Reason, pq.Array(&r.EvidenceImageURLs), &r.Status,
+		&r.SellerNote, &r.BuyerNote, &r.AdminNote, &r.CreatedAt, &r.UpdatedAt,
+		&r.Transaction.ID, &r.Transaction.UUID, &r.Transaction.ProductUID, &r.Transaction.Quantity, &r.Transaction.Amount, &r.Transaction.Status)
+	if err != nil {
+		return nil, err
+	}
+	r.Transaction.BuyerID = r.BuyerID
+	r.Transaction.SellerID = r.SellerID
+	return r, nil
+}
@@ -0,0 +1,57 @@
+package returns
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/google/uuid"
+)
+
+type CreateReturnPayload struct {
+	TransactionID     uuid.UUID `json:"transactionId"`
+	Reason            string    `json:"reason"`
+	EvidenceImageURLs []string  `json:"evidenceImageUrls"`
+}
+
+func (p CreateReturnPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.TransactionID, validation.Required),
+		validation.Field(&p.Reason, validation.Required, validation.Length(10, 500)),
+		validation.Field(&p.EvidenceImageURLs, validation.Length(0, 5), validation.Each(validation.Required, is.URL)),
+	)
+}
+
+type ListReturnPayload struct {
+	// As is either "buyer" or "seller", the side of the returns to list.
+	As     string `schema:"as"`
+	Status Status `schema:"status"`
+	UserID uint64 `schema:"-"`
+}
+
+func (p ListReturnPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.As, validation.In("buyer", "seller")),
+		validation.Field(&p.Status, validation.In(Statuses...)),
+	)
+}
+
+type NotePayload struct {
+	Note string `json:"note"`
+}
+
+func (p NotePayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Note, validation.Required, validation.Length(5, 500)),
+	)
+}
+
+type ResolveReturnPayload struct {
+	// Accept rules in favour of the buyer, otherwise the seller's decline stands.
+	Accept bool   `json:"accept"`
+	Note   string `json:"note"`
+}
+
+func (p ResolveReturnPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Note, validation.Required, validation.Length(5, 500)),
+	)
+}
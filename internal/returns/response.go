@@ -0,0 +1,56 @@
+package returns
+
+import (
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/google/uuid"
+)
+
+type ReturnResponse struct {
+	ReturnID          uuid.UUID `json:"returnId"`
+	TransactionID     uuid.UUID `json:"transactionId"`
+	ProductID         uuid.UUID `json:"productId"`
+	Quantity          int       `json:"quantity"`
+	Amount            int       `json:"amount"`
+	Reason            string    `json:"reason"`
+	EvidenceImageURLs []string  `json:"evidenceImageUrls"`
+	Status            Status    `json:"status"`
+	SellerNote        string    `json:"sellerNote,omitempty"`
+	BuyerNote         string    `json:"buyerNote,omitempty"`
+	AdminNote         string    `json:"adminNote,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	// Refund is only set when the return has just been accepted.
+	Refund *RefundResponse `json:"refund,omitempty"`
+}
+
+type RefundResponse struct {
+	Amount int `json:"amount"`
+	// Manual is true when the buyer paid by bank transfer and the seller has to
+	// transfer the money back.
+	Manual bool           `json:"manual"`
+	Status payment.Status `json:"status"`
+}
+
+func CreateReturnResponse(r *Return) ReturnResponse {
+	evidence := r.EvidenceImageURLs
+	if evidence == nil {
+		evidence = []string{}
+	}
+	return ReturnResponse{
+		ReturnID:          r.UUID,
+		TransactionID:     r.Transaction.UUID,
+		ProductID:         r.Transaction.ProductUID,
+		Quantity:          r.Transaction.Quantity,
+		Amount:            r.Transaction.Amount,
+		Reason:            r.Reason,
+		EvidenceImageURLs: evidence,
+		Status:            r.Status,
+		SellerNote:        r.SellerNote,
+		BuyerNote:         r.BuyerNote,
+		AdminNote:         r.AdminNote,
+		CreatedAt:         r.CreatedAt,
+		UpdatedAt:         r.UpdatedAt,
+	}
+}
@@ -0,0 +1,55 @@
+package returns
+
+import (
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/google/uuid"
+)
+
+// Return is a buyer's request to send back a paid purchase.
+type Return struct {
+	ID                uint64
+	UUID              uuid.UUID
+	Transaction       Transaction
+	BuyerID           uint64
+	SellerID          uint64
+	Reason            string
+	EvidenceImageURLs []string
+	Status            Status
+	SellerNote        string
+	BuyerNote         string
+	AdminNote         string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// Transaction is the purchase a return is opened for.
+type Transaction struct {
+	ID         uint64
+	UUID       uuid.UUID
+	ProductUID uuid.UUID
+	BuyerID    uint64
+	SellerID   uint64
+	Quantity   int
+	Amount     int
+	Status     payment.TransactionStatus
+}
+
+type Status string
+
+const (
+	// StatusRequested waits for the seller to accept or decline.
+	StatusRequested Status = "requested"
+	// StatusRefunding is an accepted return whose refund has not finished. Accepting it
+	// again retries the refund.
+	StatusRefunding Status = "refunding"
+	StatusAccepted  Status = "accepted"
+	StatusDeclined  Status = "declined"
+	// StatusDisputed is a declined return the buyer escalated to the admin queue.
+	StatusDisputed Status = "disputed"
+	// StatusRejected is a disputed return the admin ruled against.
+	StatusRejected Status = "rejected"
+)
+
+var Statuses []interface{} = []interface{}{StatusRequested, StatusRefunding, StatusAccepted, StatusDeclined, StatusDisputed, StatusRejected}
@@ -0,0 +1,234 @@
+package returns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Create(ctx context.Context, req CreateReturnPayload, buyerID uint64) (*ReturnResponse, error)
+	List(ctx context.Context, req ListReturnPayload) ([]ReturnResponse, error)
+	Get(ctx context.Context, uid uuid.UUID, userID uint64) (*ReturnResponse, error)
+	Accept(ctx context.Context, uid uuid.UUID, sellerID uint64) (*ReturnResponse, error)
+	Decline(ctx context.Context, req NotePayload, uid uuid.UUID, sellerID uint64) (*ReturnResponse, error)
+	Dispute(ctx context.Context, req NotePayload, uid uuid.UUID, buyerID uint64) (*ReturnResponse, error)
+	ListDisputed(ctx context.Context) ([]ReturnResponse, error)
+	Resolve(ctx context.Context, req ResolveReturnPayload, uid uuid.UUID) (*ReturnResponse, error)
+}
+
+type returnService struct {
+	repository     Repository
+	paymentService payment.Service
+}
+
+func NewService(repository Repository, paymentService payment.Service) Service {
+	return &returnService{
+		repository:     repository,
+		paymentService: paymentService,
+	}
+}
+
+// Create implements Service.
+func (s *returnService) Create(ctx context.Context, req CreateReturnPayload, buyerID uint64) (*ReturnResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	trx, err := s.repository.GetTransaction(ctx, req.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if trx.BuyerID != buyerID {
+		return nil, ErrForbidden
+	}
+	if trx.Status != payment.TransactionPaid {
+		return nil, ErrTransactionNotPaid
+	}
+
+	r := &Return{
+		Transaction:       *trx,
+		BuyerID:           trx.BuyerID,
+		SellerID:          trx.SellerID,
+		Reason:            req.Reason,
+		EvidenceImageURLs: req.EvidenceImageURLs,
+		Status:            StatusRequested,
+	}
+	err = s.repository.Create(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateReturnResponse(r)
+	return &resp, nil
+}
+
+// List implements Service.
+func (s *returnService) List(ctx context.Context, req ListReturnPayload) ([]ReturnResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	return s.list(ctx, req)
+}
+
+// Get implements Service.
+func (s *returnService) Get(ctx context.Context, uid uuid.UUID, userID uint64) (*ReturnResponse, error) {
+	r, err := s.repository.GetByUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if r.BuyerID != userID && r.SellerID != userID {
+		return nil, ErrForbidden
+	}
+	resp := CreateReturnResponse(r)
+	return &resp, nil
+}
+
+// Accept implements Service.
+func (s *returnService) Accept(ctx context.Context, uid uuid.UUID, sellerID uint64) (*ReturnResponse, error) {
+	r, err := s.repository.GetByUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if r.SellerID != sellerID {
+		return nil, ErrForbidden
+	}
+	if r.Status != StatusRequested && r.Status != StatusRefunding {
+		return nil, ErrInvalidStatus
+	}
+	return s.accept(ctx, r)
+}
+
+// Decline implements Service.
+func (s *returnService) Decline(ctx context.Context, req NotePayload, uid uuid.UUID, sellerID uint64) (*ReturnResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	r, err := s.repository.GetByUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if r.SellerID != sellerID {
+		return nil, ErrForbidden
+	}
+	r.Status = StatusDeclined
+	r.SellerNote = req.Note
+	err = s.repository.Update(ctx, r, StatusRequested)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateReturnResponse(r)
+	return &resp, nil
+}
+
+// Dispute implements Service. A declined return can be escalated to the admin queue once.
+func (s *returnService) Dispute(ctx context.Context, req NotePayload, uid uuid.UUID, buyerID uint64) (*ReturnResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	r, err := s.repository.GetByUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if r.BuyerID != buyerID {
+		return nil, ErrForbidden
+	}
+	r.Status = StatusDisputed
+	r.BuyerNote = req.Note
+	err = s.repository.Update(ctx, r, StatusDeclined)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateReturnResponse(r)
+	return &resp, nil
+}
+
+// ListDisputed implements Service.
+func (s *returnService) ListDisputed(ctx context.Context) ([]ReturnResponse, error) {
+	return s.list(ctx, ListReturnPayload{Status: StatusDisputed})
+}
+
+// Resolve implements Service.
+func (s *returnService) Resolve(ctx context.Context, req ResolveReturnPayload, uid uuid.UUID) (*ReturnResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	r, err := s.repository.GetByUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status == StatusRefunding && req.Accept {
+		return s.accept(ctx, r)
+	}
+	if r.Status != StatusDisputed {
+		return nil, ErrInvalidStatus
+	}
+	r.AdminNote = req.Note
+	if req.Accept {
+		return s.accept(ctx, r)
+	}
+
+	r.Status = StatusRejected
+	err = s.repository.Update(ctx, r, StatusDisputed)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateReturnResponse(r)
+	return &resp, nil
+}
+
+func (s *returnService) list(ctx context.Context, req ListReturnPayload) ([]ReturnResponse, error) {
+	returns, err := s.repository.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]ReturnResponse, len(returns))
+	for i, r := range returns {
+		resp[i] = CreateReturnResponse(r)
+	}
+	return resp, nil
+}
+
+// accept claims the return as refunding, so a concurrent decision cannot refund the
+// buyer as well, refunds them and then restores the stock and counters of the
+// purchase. A return left refunding by a failed refund is accepted again from there.
+func (s *returnService) accept(ctx context.Context, r *Return) (*ReturnResponse, error) {
+	if r.Status != StatusRefunding {
+		from := r.Status
+		r.Status = StatusRefunding
+		err := s.repository.Update(ctx, r, from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	refund := &RefundResponse{
+		Amount: r.Transaction.Amount,
+		Manual: true,
+	}
+	p, err := s.paymentService.Refund(ctx, r.Transaction.ID)
+	switch {
+	case errors.Is(err, payment.ErrNotFound):
+		// purchases made before payments were recorded are refunded by hand
+	case err != nil:
+		return nil, err
+	default:
+		refund.Manual = p.Provider == payment.TransferProvider
+		refund.Status = p.Status
+	}
+
+	r.Status = StatusAccepted
+	err = s.repository.Accept(ctx, r, StatusRefunding)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateReturnResponse(r)
+	resp.Refund = refund
+	return &resp, nil
+}
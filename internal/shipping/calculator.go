@@ -0,0 +1,33 @@
+package shipping
+
+import (
+	"context"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
+)
+
+// Calculator prices the delivery of a purchase.
+type Calculator interface {
+	Fee(ctx context.Context, req FeeRequest) (int, error)
+}
+
+type FeeRequest struct {
+	Destination address.Snapshot
+	Quantity    int
+	// Subtotal is the price of the purchased items without shipping.
+	Subtotal int
+}
+
+// FlatRateCalculator charges the same fee for every purchase.
+type FlatRateCalculator struct {
+	Rate int
+}
+
+func NewFlatRateCalculator(rate int) *FlatRateCalculator {
+	return &FlatRateCalculator{Rate: rate}
+}
+
+// Fee implements Calculator.
+func (c *FlatRateCalculator) Fee(ctx context.Context, req FeeRequest) (int, error) {
+	return c.Rate, nil
+}
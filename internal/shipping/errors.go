@@ -0,0 +1,11 @@
+package shipping
+
+import "errors"
+
+var (
+	ErrValidationFailed = errors.New("validation failed")
+	ErrNotFound         = errors.New("shipment not found")
+	ErrForbidden        = errors.New("you are forbidden to make changes to this shipment")
+	ErrNotPaid          = errors.New("purchase has not been paid")
+	ErrInvalidStatus    = errors.New("shipment cannot be changed in its current status")
+)
@@ -0,0 +1,133 @@
+package shipping
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/middleware"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/request"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) GetShipment(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	uid, err := uuid.Parse(mux.Vars(r)["transactionId"])
+	if err != nil {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	shipmentResp, err := h.service.Get(r.Context(), uid, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    shipmentResp,
+	})
+}
+
+func (h *Handler) ShipPurchase(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	uid, err := uuid.Parse(mux.Vars(r)["transactionId"])
+	if err != nil {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	var req ShipPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	shipmentResp, err := h.service.Ship(r.Context(), req, uid, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Purchase shipped successfully",
+		Data:    shipmentResp,
+	})
+}
+
+func (h *Handler) ConfirmReceipt(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	uid, err := uuid.Parse(mux.Vars(r)["transactionId"])
+	if err != nil {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	shipmentResp, err := h.service.ConfirmReceipt(r.Context(), uid, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Receipt confirmed successfully",
+		Data:    shipmentResp,
+	})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrValidationFailed):
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrNotFound):
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrForbidden):
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrNotPaid), errors.Is(err, ErrInvalidStatus):
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+	default:
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+	}
+}
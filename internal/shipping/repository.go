@@ -0,0 +1,82 @@
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	GetByTransactionUUID(ctx context.Context, uid uuid.UUID) (*Shipment, error)
+	Update(ctx context.Context, shipment *Shipment, from Status) error
+}
+
+type dbRepository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) Repository {
+	return &dbRepository{db: db}
+}
+
+// GetByTransactionUUID implements Repository. Purchases made before shipping
+// existed have no shipment.
+func (d *dbRepository) GetByTransactionUUID(ctx context.Context, uid uuid.UUID) (*Shipment, error) {
+	getQuery := `
+		SELECT t.id, t.uid, t.status, t.user_id, p.user_id, t.shipping_status, t.shipping_address, t.shipping_fee,
+			COALESCE(t.courier, ''), COALESCE(t.tracking_number, ''), t.shipped_at, t.received_at
+		FROM user_transactions t
+		INNER JOIN products p ON p.uid = t.product_id
+		WHERE t.uid = $1 AND t.shipping_status IS NOT NULL;
+	`
+	row := d.db.DB().QueryRowContext(ctx, getQuery, uid)
+	s := &Shipment{}
+	var addr []byte
+	err := row.Scan(&s.TransactionID, &s.TransactionUUID, &s.TransactionStatus, &s.BuyerID, &s.SellerID, &s.Status, &addr, &s.Fee,
+		&s.Courier, &s.TrackingNumber, &s.ShippedAt, &s.ReceivedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(addr, &s.Address)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Update implements Repository. The update only applies while the shipment is in
+// the from status and its purchase is paid.
+func (d *dbRepository) Update(ctx context.Context, shipment *Shipment, from Status) error {
+	updateQuery := `
+		UPDATE user_transactions
+		SET shipping_status = $1,
+		courier = NULLIF($2, ''),
+		tracking_number = NULLIF($3, ''),
+		shipped_at = $4,
+		received_at = $5
+		WHERE id = $6
+		AND shipping_status = $7
+		AND status = $8;
+	`
+	res, err := d.db.DB().ExecContext(ctx, updateQuery, shipment.Status, shipment.Courier, shipment.TrackingNumber,
+		shipment.ShippedAt, shipment.ReceivedAt, shipment.TransactionID, from, payment.TransactionPaid)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvalidStatus
+	}
+	return nil
+}
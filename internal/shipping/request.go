@@ -0,0 +1,15 @@
+package shipping
+
+import validation "github.com/go-ozzo/ozzo-validation/v4"
+
+type ShipPayload struct {
+	Courier        string `json:"courier"`
+	TrackingNumber string `json:"trackingNumber"`
+}
+
+func (p ShipPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Courier, validation.Required, validation.Length(2, 30)),
+		validation.Field(&p.TrackingNumber, validation.Required, validation.Length(5, 50)),
+	)
+}
@@ -0,0 +1,37 @@
+package shipping
+
+import (
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
+	"github.com/google/uuid"
+)
+
+type ShipmentResponse struct {
+	TransactionID  uuid.UUID        `json:"transactionId"`
+	Status         Status           `json:"status"`
+	Address        address.Snapshot `json:"address"`
+	Fee            int              `json:"fee"`
+	Courier        string           `json:"courier,omitempty"`
+	TrackingNumber string           `json:"trackingNumber,omitempty"`
+	ShippedAt      *time.Time       `json:"shippedAt,omitempty"`
+	ReceivedAt     *time.Time       `json:"receivedAt,omitempty"`
+}
+
+func CreateShipmentResponse(s *Shipment) ShipmentResponse {
+	resp := ShipmentResponse{
+		TransactionID:  s.TransactionUUID,
+		Status:         s.Status,
+		Address:        s.Address,
+		Fee:            s.Fee,
+		Courier:        s.Courier,
+		TrackingNumber: s.TrackingNumber,
+	}
+	if s.ShippedAt.Valid {
+		resp.ShippedAt = &s.ShippedAt.Time
+	}
+	if s.ReceivedAt.Valid {
+		resp.ReceivedAt = &s.ReceivedAt.Time
+	}
+	return resp
+}
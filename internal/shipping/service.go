@@ -0,0 +1,85 @@
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Get(ctx context.Context, uid uuid.UUID, userID uint64) (*ShipmentResponse, error)
+	Ship(ctx context.Context, req ShipPayload, uid uuid.UUID, sellerID uint64) (*ShipmentResponse, error)
+	ConfirmReceipt(ctx context.Context, uid uuid.UUID, buyerID uint64) (*ShipmentResponse, error)
+}
+
+type shippingService struct {
+	repository Repository
+}
+
+func NewService(repository Repository) Service {
+	return &shippingService{repository: repository}
+}
+
+// Get implements Service.
+func (s *shippingService) Get(ctx context.Context, uid uuid.UUID, userID uint64) (*ShipmentResponse, error) {
+	shipment, err := s.repository.GetByTransactionUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.BuyerID != userID && shipment.SellerID != userID {
+		return nil, ErrForbidden
+	}
+	resp := CreateShipmentResponse(shipment)
+	return &resp, nil
+}
+
+// Ship implements Service.
+func (s *shippingService) Ship(ctx context.Context, req ShipPayload, uid uuid.UUID, sellerID uint64) (*ShipmentResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	shipment, err := s.repository.GetByTransactionUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.SellerID != sellerID {
+		return nil, ErrForbidden
+	}
+	if shipment.TransactionStatus != payment.TransactionPaid {
+		return nil, ErrNotPaid
+	}
+	shipment.Status = StatusShipped
+	shipment.Courier = req.Courier
+	shipment.TrackingNumber = req.TrackingNumber
+	shipment.ShippedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	err = s.repository.Update(ctx, shipment, StatusAwaitingShipment)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateShipmentResponse(shipment)
+	return &resp, nil
+}
+
+// ConfirmReceipt implements Service.
+func (s *shippingService) ConfirmReceipt(ctx context.Context, uid uuid.UUID, buyerID uint64) (*ShipmentResponse, error) {
+	shipment, err := s.repository.GetByTransactionUUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.BuyerID != buyerID {
+		return nil, ErrForbidden
+	}
+	shipment.Status = StatusReceived
+	shipment.ReceivedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	err = s.repository.Update(ctx, shipment, StatusShipped)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateShipmentResponse(shipment)
+	return &resp, nil
+}
@@ -0,0 +1,33 @@
+package shipping
+
+import (
+	"database/sql"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/address"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/payment"
+	"github.com/google/uuid"
+)
+
+// Shipment is the delivery of a purchase, stored on its user_transactions row.
+type Shipment struct {
+	TransactionID     uint64
+	TransactionUUID   uuid.UUID
+	TransactionStatus payment.TransactionStatus
+	BuyerID           uint64
+	SellerID          uint64
+	Status            Status
+	Address           address.Snapshot
+	Fee               int
+	Courier           string
+	TrackingNumber    string
+	ShippedAt         sql.NullTime
+	ReceivedAt        sql.NullTime
+}
+
+type Status string
+
+const (
+	StatusAwaitingShipment Status = "awaiting_shipment"
+	StatusShipped          Status = "shipped"
+	StatusReceived         Status = "received"
+)
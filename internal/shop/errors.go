@@ -0,0 +1,14 @@
+package shop
+
+import "errors"
+
+var (
+	ErrValidationFailed    = errors.New("validation failed")
+	ErrNotFound            = errors.New("shop not found")
+	ErrForbidden           = errors.New("you are forbidden to make changes to this shop")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrMemberNotFound      = errors.New("shop member not found")
+	ErrAlreadyMember       = errors.New("user is already a member of this shop")
+	ErrInvitationNotFound  = errors.New("shop invitation not found")
+	ErrOwnerCannotBeMember = errors.New("the shop owner cannot be invited")
+)
@@ -0,0 +1,281 @@
+package shop
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	Create(ctx context.Context, shop *Shop) error
+	GetDefault(ctx context.Context, ownerID uint64) (*Shop, error)
+	GetOrCreateDefault(ctx context.Context, ownerID uint64, name string) (*Shop, error)
+	GetByID(ctx context.Context, id uint64) (*Shop, error)
+	GetByUUID(ctx context.Context, uid uuid.UUID) (*Shop, error)
+	ListMemberships(ctx context.Context, userID uint64) ([]*Membership, error)
+	GetMember(ctx context.Context, shopID, userID uint64) (*Member, error)
+	ListMembers(ctx context.Context, shopID uint64) ([]*Member, error)
+	CreateMember(ctx context.Context, member *Member) error
+	AcceptInvitation(ctx context.Context, shopID, userID uint64) error
+	UpdatePermissions(ctx context.Context, shopID, userID uint64, permissions []Permission) error
+	DeleteMember(ctx context.Context, shopID, userID uint64) error
+}
+
+type dbRepository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) Repository {
+	return &dbRepository{db: db}
+}
+
+// Create implements Repository.
+func (d *dbRepository) Create(ctx context.Context, shop *Shop) error {
+	createQuery := `
+		INSERT INTO shops (
+			name, owner_id
+		) VALUES (
+			$1, $2
+		)
+		RETURNING id, uid, is_default, created_at;
+	`
+	row := d.db.DB().QueryRowContext(ctx, createQuery, shop.Name, shop.OwnerID)
+	return row.Scan(&shop.ID, &shop.UUID, &shop.IsDefault, &shop.CreatedAt)
+}
+
+// GetDefault implements Repository.
+func (d *dbRepository) GetDefault(ctx context.Context, ownerID uint64) (*Shop, error) {
+	getQuery := `
+		SELECT id, uid, name, owner_id, is_default, created_at
+		FROM shops
+		WHERE owner_id = $1 AND is_default;
+	`
+	return d.get(ctx, getQuery, ownerID)
+}
+
+// GetOrCreateDefault implements Repository. The default shop of a seller is created
+// named name the first time it is needed.
+func (d *dbRepository) GetOrCreateDefault(ctx context.Context, ownerID uint64, name string) (*Shop, error) {
+	createQuery := `
+		INSERT INTO shops (
+			name, owner_id, is_default
+		) VALUES (
+			$1, $2, true
+		)
+		ON CONFLICT (owner_id) WHERE is_default DO NOTHING;
+	`
+	_, err := d.db.DB().ExecContext(ctx, createQuery, name, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return d.GetDefault(ctx, ownerID)
+}
+
+// GetByID implements Repository.
+func (d *dbRepository) GetByID(ctx context.Context, id uint64) (*Shop, error) {
+	getQuery := `
+		SELECT id, uid, name, owner_id, is_default, created_at
+		FROM shops
+		WHERE id = $1;
+	`
+	return d.get(ctx, getQuery, id)
+}
+
+// GetByUUID implements Repository.
+func (d *dbRepository) GetByUUID(ctx context.Context, uid uuid.UUID) (*Shop, error) {
+	getQuery := `
+		SELECT id, uid, name, owner_id, is_default, created_at
+		FROM shops
+		WHERE uid = $1;
+	`
+	return d.get(ctx, getQuery, uid)
+}
+
+func (d *dbRepository) get(ctx context.Context, query string, args ...any) (*Shop, error) {
+	s := &Shop{}
+	err := d.db.DB().QueryRowContext(ctx, query, args...).Scan(&s.ID, &s.UUID, &s.Name, &s.OwnerID, &s.IsDefault, &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListMemberships implements Repository. It lists the shops the user owns, works in
+// or is invited to, oldest first.
+func (d *dbRepository) ListMemberships(ctx context.Context, userID uint64) ([]*Membership, error) {
+	listQuery := `
+		SELECT s.id, s.uid, s.name, s.owner_id, s.is_default, s.created_at,
+			COALESCE(m.permissions, '{}'), COALESCE(m.status, 'active'), m.invited_by, COALESCE(m.invited_at, s.created_at), m.joined_at
+		FROM shops s
+		LEFT JOIN shop_members m ON m.shop_id = s.id AND m.user_id = $1
+		WHERE s.owner_id = $1 OR m.user_id IS NOT NULL
+		ORDER BY s.id;
+	`
+	rows, err := d.db.DB().QueryContext(ctx, listQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var memberships []*Membership
+	for rows.Next() {
+		m := &Membership{}
+		var permissions []string
+		err := rows.Scan(&m.Shop.ID, &m.Shop.UUID, &m.Shop.Name, &m.Shop.OwnerID, &m.Shop.IsDefault, &m.Shop.CreatedAt,
+			pq.Array(&permissions), &m.Member.Status, &m.Member.InvitedBy, &m.Member.InvitedAt, &m.Member.JoinedAt)
+		if err != nil {
+			return nil, err
+		}
+		m.Member.ShopID = m.Shop.ID
+		m.Member.UserID = userID
+		m.Member.Permissions = permissionsFromStrings(permissions)
+		if m.IsOwner() {
+			m.Member.Permissions = AllPermissions
+		}
+		memberships = append(memberships, m)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// GetMember implements Repository. Owners are not members of their own shops.
+func (d *dbRepository) GetMember(ctx context.Context, shopID, userID uint64) (*Member, error) {
+	getQuery := `
+		SELECT m.shop_id, m.user_id, u.username, u.name, m.permissions, m.status, m.invited_by, m.invited_at, m.joined_at
+		FROM shop_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.shop_id = $1 AND m.user_id = $2;
+	`
+	m, err := scanMember(d.db.DB().QueryRowContext(ctx, getQuery, shopID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMemberNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ListMembers implements Repository. Invited users are listed as well.
+func (d *dbRepository) ListMembers(ctx context.Context, shopID uint64) ([]*Member, error) {
+	listQuery := `
+		SELECT m.shop_id, m.user_id, u.username, u.name, m.permissions, m.status, m.invited_by, m.invited_at, m.joined_at
+		FROM shop_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.shop_id = $1
+		ORDER BY m.invited_at;
+	`
+	rows, err := d.db.DB().QueryContext(ctx, listQuery, shopID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var members []*Member
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMember(row scanner) (*Member, error) {
+	m := &Member{}
+	var permissions []string
+	err := row.Scan(&m.ShopID, &m.UserID, &m.Username, &m.Name,
+		pq.Array(&permissions), &m.Status, &m.InvitedBy, &m.InvitedAt, &m.JoinedAt)
+	if err != nil {
+		return nil, err
+	}
+	m.Permissions = permissionsFromStrings(permissions)
+	return m, nil
+}
+
+// CreateMember implements Repository. The member starts invited.
+func (d *dbRepository) CreateMember(ctx context.Context, member *Member) error {
+	createQuery := `
+		INSERT INTO shop_members (
+			shop_id, user_id, permissions, invited_by
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		RETURNING status, invited_at;
+	`
+	row := d.db.DB().QueryRowContext(ctx, createQuery, member.ShopID, member.UserID, pq.Array(permissionStrings(member.Permissions)), member.InvitedBy)
+	err := row.Scan(&member.Status, &member.InvitedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrAlreadyMember
+	}
+	return err
+}
+
+// AcceptInvitation implements Repository.
+func (d *dbRepository) AcceptInvitation(ctx context.Context, shopID, userID uint64) error {
+	acceptQuery := `
+		UPDATE shop_members
+		SET status = 'active',
+		joined_at = current_timestamp
+		WHERE shop_id = $1 AND user_id = $2 AND status = 'invited';
+	`
+	return d.exec(ctx, ErrInvitationNotFound, acceptQuery, shopID, userID)
+}
+
+// UpdatePermissions implements Repository.
+func (d *dbRepository) UpdatePermissions(ctx context.Context, shopID, userID uint64, permissions []Permission) error {
+	updateQuery := `
+		UPDATE shop_members
+		SET permissions = $3
+		WHERE shop_id = $1 AND user_id = $2;
+	`
+	return d.exec(ctx, ErrMemberNotFound, updateQuery, shopID, userID, pq.Array(permissionStrings(permissions)))
+}
+
+// DeleteMember implements Repository.
+func (d *dbRepository) DeleteMember(ctx context.Context, shopID, userID uint64) error {
+	deleteQuery := `
+		DELETE FROM shop_members
+		WHERE shop_id = $1 AND user_id = $2;
+	`
+	return d.exec(ctx, ErrMemberNotFound, deleteQuery, shopID, userID)
+}
+
+// exec runs a statement that has to change a row, returning notFound otherwise.
+func (d *dbRepository) exec(ctx context.Context, notFound error, query string, args ...any) error {
+	res, err := d.db.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package shop
+
+import (
+	"errors"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type CreateShopPayload struct {
+	Name string `json:"name"`
+}
+
+func (p CreateShopPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Name, validation.Required, validation.Length(5, 50)),
+	)
+}
+
+type InviteMemberPayload struct {
+	Username    string       `json:"username"`
+	Permissions []Permission `json:"permissions"`
+}
+
+func (p InviteMemberPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Username, validation.Required, validation.Length(5, 15)),
+		validation.Field(&p.Permissions, validation.Each(validation.By(validPermission))),
+	)
+}
+
+type UpdateMemberPayload struct {
+	Permissions []Permission `json:"permissions"`
+}
+
+func (p UpdateMemberPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Permissions, validation.NotNil, validation.Each(validation.By(validPermission))),
+	)
+}
+
+func validPermission(value interface{}) error {
+	if p, _ := value.(Permission); !p.Valid() {
+		return errors.New("must be a valid permission")
+	}
+	return nil
+}
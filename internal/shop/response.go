@@ -0,0 +1,56 @@
+package shop
+
+import "time"
+
+type ShopResponse struct {
+	ShopID      string       `json:"shopId"`
+	Name        string       `json:"name"`
+	IsDefault   bool         `json:"isDefault"`
+	IsOwner     bool         `json:"isOwner"`
+	Status      MemberStatus `json:"status"`
+	Permissions []Permission `json:"permissions"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
+
+type MemberResponse struct {
+	Username    string       `json:"username"`
+	Name        string       `json:"name"`
+	Status      MemberStatus `json:"status"`
+	Permissions []Permission `json:"permissions"`
+	InvitedAt   time.Time    `json:"invitedAt"`
+	JoinedAt    *time.Time   `json:"joinedAt,omitempty"`
+}
+
+func CreateShopResponse(m *Membership) *ShopResponse {
+	return &ShopResponse{
+		ShopID:      m.Shop.UUID.String(),
+		Name:        m.Shop.Name,
+		IsDefault:   m.Shop.IsDefault,
+		IsOwner:     m.IsOwner(),
+		Status:      m.Member.Status,
+		Permissions: nonNil(m.Member.Permissions),
+		CreatedAt:   m.Shop.CreatedAt,
+	}
+}
+
+func CreateMemberResponse(m *Member) *MemberResponse {
+	resp := &MemberResponse{
+		Username:    m.Username,
+		Name:        m.Name,
+		Status:      m.Status,
+		Permissions: nonNil(m.Permissions),
+		InvitedAt:   m.InvitedAt,
+	}
+	if m.JoinedAt.Valid {
+		resp.JoinedAt = &m.JoinedAt.Time
+	}
+	return resp
+}
+
+// nonNil makes members without permissions show an empty list instead of null.
+func nonNil(permissions []Permission) []Permission {
+	if permissions == nil {
+		return []Permission{}
+	}
+	return permissions
+}
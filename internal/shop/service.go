@@ -0,0 +1,278 @@
+package shop
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/user"
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Create(ctx context.Context, req CreateShopPayload, userID uint64) (*ShopResponse, error)
+	List(ctx context.Context, userID uint64) ([]*ShopResponse, error)
+	ListMembers(ctx context.Context, shopUID uuid.UUID, userID uint64) ([]*MemberResponse, error)
+	Invite(ctx context.Context, req InviteMemberPayload, shopUID uuid.UUID, userID uint64) (*MemberResponse, error)
+	AcceptInvitation(ctx context.Context, shopUID uuid.UUID, userID uint64) error
+	UpdateMember(ctx context.Context, req UpdateMemberPayload, shopUID uuid.UUID, username string, userID uint64) (*MemberResponse, error)
+	RemoveMember(ctx context.Context, shopUID uuid.UUID, username string, userID uint64) error
+	Authorize(ctx context.Context, shopID, userID uint64, permission Permission) error
+	Authorized(ctx context.Context, shopUID uuid.UUID, userID uint64, permission Permission) (*Shop, error)
+	DefaultShop(ctx context.Context, userID uint64) (*Shop, error)
+}
+
+type shopService struct {
+	repository     Repository
+	userRepository user.Repository
+}
+
+func NewService(repository Repository, userRepository user.Repository) Service {
+	return &shopService{repository: repository, userRepository: userRepository}
+}
+
+// Create implements Service.
+func (s *shopService) Create(ctx context.Context, req CreateShopPayload, userID uint64) (*ShopResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	shop := &Shop{
+		Name:    req.Name,
+		OwnerID: userID,
+	}
+	err = s.repository.Create(ctx, shop)
+	if err != nil {
+		return nil, err
+	}
+	return CreateShopResponse(ownerMembership(shop)), nil
+}
+
+// List implements Service. Shops the user is only invited to are listed too, so they
+// can accept.
+func (s *shopService) List(ctx context.Context, userID uint64) ([]*ShopResponse, error) {
+	memberships, err := s.repository.ListMemberships(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*ShopResponse, len(memberships))
+	for i, membership := range memberships {
+		resp[i] = CreateShopResponse(membership)
+	}
+	return resp, nil
+}
+
+// ListMembers implements Service. The owner and members who joined see who works in
+// the shop.
+func (s *shopService) ListMembers(ctx context.Context, shopUID uuid.UUID, userID uint64) ([]*MemberResponse, error) {
+	shop, err := s.repository.GetByUUID(ctx, shopUID)
+	if err != nil {
+		return nil, err
+	}
+	if shop.OwnerID != userID {
+		member, err := s.repository.GetMember(ctx, shop.ID, userID)
+		if errors.Is(err, ErrMemberNotFound) {
+			return nil, ErrForbidden
+		}
+		if err != nil {
+			return nil, err
+		}
+		if member.Status != MemberActive {
+			return nil, ErrForbidden
+		}
+	}
+	members, err := s.repository.ListMembers(ctx, shop.ID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*MemberResponse, len(members))
+	for i, member := range members {
+		resp[i] = CreateMemberResponse(member)
+	}
+	return resp, nil
+}
+
+// Invite implements Service. Only the owner invites, the invited user has to accept
+// before the permissions apply.
+func (s *shopService) Invite(ctx context.Context, req InviteMemberPayload, shopUID uuid.UUID, userID uint64) (*MemberResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	shop, err := s.getOwned(ctx, shopUID, userID)
+	if err != nil {
+		return nil, err
+	}
+	invitee, err := s.getUser(ctx, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if invitee.ID == shop.OwnerID {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, ErrOwnerCannotBeMember)
+	}
+	member := &Member{
+		ShopID:      shop.ID,
+		UserID:      invitee.ID,
+		Username:    invitee.Username,
+		Name:        invitee.Name,
+		Permissions: req.Permissions,
+		InvitedBy:   sql.NullInt64{Int64: int64(userID), Valid: true},
+	}
+	err = s.repository.CreateMember(ctx, member)
+	if err != nil {
+		return nil, err
+	}
+	return CreateMemberResponse(member), nil
+}
+
+// AcceptInvitation implements Service.
+func (s *shopService) AcceptInvitation(ctx context.Context, shopUID uuid.UUID, userID uint64) error {
+	shop, err := s.repository.GetByUUID(ctx, shopUID)
+	if err != nil {
+		return err
+	}
+	return s.repository.AcceptInvitation(ctx, shop.ID, userID)
+}
+
+// UpdateMember implements Service. Only the owner changes permissions.
+func (s *shopService) UpdateMember(ctx context.Context, req UpdateMemberPayload, shopUID uuid.UUID, username string, userID uint64) (*MemberResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	shop, err := s.getOwned(ctx, shopUID, userID)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.getMember(ctx, shop.ID, username)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repository.UpdatePermissions(ctx, shop.ID, member.UserID, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	member.Permissions = req.Permissions
+	return CreateMemberResponse(member), nil
+}
+
+// RemoveMember implements Service. The owner removes members and withdraws
+// invitations, members leave or decline an invitation by removing themselves.
+func (s *shopService) RemoveMember(ctx context.Context, shopUID uuid.UUID, username string, userID uint64) error {
+	shop, err := s.repository.GetByUUID(ctx, shopUID)
+	if err != nil {
+		return err
+	}
+	member, err := s.getMember(ctx, shop.ID, username)
+	if err != nil {
+		return err
+	}
+	if shop.OwnerID != userID && member.UserID != userID {
+		return ErrForbidden
+	}
+	return s.repository.DeleteMember(ctx, shop.ID, member.UserID)
+}
+
+// Authorize implements Service. It fails with ErrForbidden unless the user owns the
+// shop or joined it with permission.
+func (s *shopService) Authorize(ctx context.Context, shopID, userID uint64, permission Permission) error {
+	shop, err := s.repository.GetByID(ctx, shopID)
+	if err != nil {
+		return err
+	}
+	return s.authorize(ctx, shop, userID, permission)
+}
+
+// Authorized implements Service. It returns the shop the user acts for after checking
+// the permission, which is their default shop when shopUID is not set.
+func (s *shopService) Authorized(ctx context.Context, shopUID uuid.UUID, userID uint64, permission Permission) (*Shop, error) {
+	if shopUID == uuid.Nil {
+		return s.repository.GetDefault(ctx, userID)
+	}
+	shop, err := s.repository.GetByUUID(ctx, shopUID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.authorize(ctx, shop, userID, permission)
+	if err != nil {
+		return nil, err
+	}
+	return shop, nil
+}
+
+// DefaultShop implements Service. The default shop is created named after the user
+// the first time they sell.
+func (s *shopService) DefaultShop(ctx context.Context, userID uint64) (*Shop, error) {
+	u, err := s.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetOrCreateDefault(ctx, userID, u.Name)
+}
+
+func (s *shopService) authorize(ctx context.Context, shop *Shop, userID uint64, permission Permission) error {
+	if shop.OwnerID == userID {
+		return nil
+	}
+	member, err := s.repository.GetMember(ctx, shop.ID, userID)
+	if errors.Is(err, ErrMemberNotFound) {
+		return ErrForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if !member.Can(permission) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *shopService) getOwned(ctx context.Context, shopUID uuid.UUID, userID uint64) (*Shop, error) {
+	shop, err := s.repository.GetByUUID(ctx, shopUID)
+	if err != nil {
+		return nil, err
+	}
+	if shop.OwnerID != userID {
+		return nil, ErrForbidden
+	}
+	return shop, nil
+}
+
+func (s *shopService) getUser(ctx context.Context, username string) (*user.User, error) {
+	u, err := s.userRepository.GetByUsername(ctx, username)
+	if errors.Is(err, user.ErrUserNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.IsDeleted() {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (s *shopService) getMember(ctx context.Context, shopID uint64, username string) (*Member, error) {
+	u, err := s.getUser(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrMemberNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetMember(ctx, shopID, u.ID)
+}
+
+// ownerMembership is how the owner sees a shop they just created.
+func ownerMembership(shop *Shop) *Membership {
+	return &Membership{
+		Shop: *shop,
+		Member: Member{
+			ShopID:      shop.ID,
+			UserID:      shop.OwnerID,
+			Permissions: AllPermissions,
+			Status:      MemberActive,
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package shop
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Shop is where a seller lists products and receives payments. Its owner may let
+// other users help run it as members.
+type Shop struct {
+	ID        uint64
+	UUID      uuid.UUID
+	Name      string
+	OwnerID   uint64
+	IsDefault bool
+	CreatedAt time.Time
+}
+
+// Permission is something a member may do in a shop. The owner may do everything.
+type Permission string
+
+const (
+	PermissionManageProducts     Permission = "manage_products"
+	PermissionManageStock        Permission = "manage_stock"
+	PermissionViewSales          Permission = "view_sales"
+	PermissionManageBankAccounts Permission = "manage_bank_accounts"
+)
+
+var AllPermissions = []Permission{
+	PermissionManageProducts,
+	PermissionManageStock,
+	PermissionViewSales,
+	PermissionManageBankAccounts,
+}
+
+func (p Permission) Valid() bool {
+	return slices.Contains(AllPermissions, p)
+}
+
+// permissionsFromStrings keeps the valid permissions of names as stored.
+func permissionsFromStrings(names []string) []Permission {
+	permissions := make([]Permission, 0, len(names))
+	for _, name := range names {
+		if p := Permission(name); p.Valid() {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+func permissionStrings(permissions []Permission) []string {
+	names := make([]string, len(permissions))
+	for i, p := range permissions {
+		names[i] = string(p)
+	}
+	return names
+}
+
+// MemberStatus tells whether an invited user joined the shop yet.
+type MemberStatus string
+
+const (
+	MemberInvited MemberStatus = "invited"
+	MemberActive  MemberStatus = "active"
+)
+
+type Member struct {
+	ShopID      uint64
+	UserID      uint64
+	Username    string
+	Name        string
+	Permissions []Permission
+	Status      MemberStatus
+	InvitedBy   sql.NullInt64
+	InvitedAt   time.Time
+	JoinedAt    sql.NullTime
+}
+
+// Can reports whether the member joined the shop and was given permission.
+func (m *Member) Can(permission Permission) bool {
+	return m.Status == MemberActive && slices.Contains(m.Permissions, permission)
+}
+
+// Membership is a shop as seen by one of its users. Owners are listed with every
+// permission.
+type Membership struct {
+	Shop   Shop
+	Member Member
+}
+
+func (m *Membership) IsOwner() bool {
+	return m.Shop.OwnerID == m.Member.UserID
+}
@@ -1,10 +1,50 @@
 package user
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
-	ErrUserNotFound          = errors.New("user not found")
-	ErrWrongPassword         = errors.New("wrong password")
-	ErrUsernameAlreadyExists = errors.New("username already exists")
-	ErrValidationFailed      = errors.New("validation failed")
+	ErrUserNotFound            = errors.New("user not found")
+	ErrInvalidCredentials      = errors.New("invalid username or password")
+	ErrWrongPassword           = errors.New("current password is wrong")
+	ErrTooManyAttempts         = errors.New("too many failed logins")
+	ErrUsernameAlreadyExists   = errors.New("username already exists")
+	ErrEmailAlreadyExists      = errors.New("email already exists")
+	ErrNoEmail                 = errors.New("user has no email address")
+	ErrEmailAlreadyVerified    = errors.New("email address is already verified")
+	ErrInvalidToken            = errors.New("invalid or expired token")
+	ErrAPITokenNotFound        = errors.New("api token not found")
+	ErrInvalidAPIToken         = errors.New("invalid api token")
+	ErrTooManyAPITokens        = errors.New("too many api tokens, revoke one first")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrInvalidCode             = errors.New("invalid code")
+	ErrUnknownProvider         = errors.New("unknown login provider")
+	ErrInvalidLoginState       = errors.New("invalid or expired login state")
+	ErrIdentityNotFound        = errors.New("identity not found")
+	ErrIdentityAlreadyLinked   = errors.New("provider account is already linked to a user")
+	ErrProviderLoginFailed     = errors.New("login at the provider failed")
+	ErrValidationFailed        = errors.New("validation failed")
+	ErrInvalidRefreshToken     = errors.New("invalid refresh token")
+	ErrSessionNotFound         = errors.New("session not found")
+	ErrRefreshTokenReused      = errors.New("refresh token was already used, all tokens of this login are revoked")
+	ErrUserSuspended           = errors.New("user is suspended")
+	ErrOpenOrders              = errors.New("user has unfinished purchases, sales or returns")
 )
+
+// ThrottledError refuses a login because of earlier failures. It matches
+// ErrTooManyAttempts.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%v, try again in %v", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
@@ -2,10 +2,20 @@ package user
 
 import (
 	"errors"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 
+	"github.com/citadel-corp/shopifyx-marketplace/internal/audit"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/middleware"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/request"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/response"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
 )
 
 type Handler struct {
@@ -27,8 +37,9 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	req.ClientInfo = clientInfo(r, req.Device)
 	userResp, err := h.service.Create(r.Context(), req)
-	if errors.Is(err, ErrUsernameAlreadyExists) {
+	if errors.Is(err, ErrUsernameAlreadyExists) || errors.Is(err, ErrEmailAlreadyExists) {
 		response.JSON(w, http.StatusConflict, response.ResponseBody{
 			Message: "User already exists",
 			Error:   err.Error(),
@@ -66,7 +77,288 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	req.ClientInfo = clientInfo(r, req.Device)
 	userResp, err := h.service.Login(r.Context(), req)
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		writeTooManyRequests(w, throttled)
+		return
+	}
+	if errors.Is(err, ErrUserSuspended) {
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrInvalidCredentials) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrValidationFailed) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	message := "User logged successfully"
+	if userResp.TwoFactorRequired {
+		message = "Second factor required"
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: message,
+		Data:    userResp,
+	})
+}
+
+func (h *Handler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req LoginTwoFactorPayload
+
+	err := request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.ClientInfo = clientInfo(r, req.Device)
+
+	userResp, err := h.service.LoginTwoFactor(r.Context(), req)
+	if errors.Is(err, ErrUserSuspended) {
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+		return
+	}
+	writeTwoFactorResponse(w, "User logged successfully", userResp, err)
+}
+
+func (h *Handler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	providersResp, err := h.service.ListProviders(r.Context())
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Login providers fetched successfully",
+		Data:    providersResp,
+	})
+}
+
+func (h *Handler) BeginOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	loginResp, err := h.service.BeginOIDCLogin(r.Context(), mux.Vars(r)["provider"])
+	writeOIDCResponse(w, "Login started successfully", loginResp, err)
+}
+
+func (h *Handler) FinishOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	var req OIDCCallbackPayload
+
+	err := request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Provider = mux.Vars(r)["provider"]
+	req.ClientInfo = clientInfo(r, req.Device)
+
+	userResp, err := h.service.FinishOIDCLogin(r.Context(), req)
+	writeOIDCResponse(w, "User logged successfully", userResp, err)
+}
+
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenPayload
+
+	err := request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	tokenResp, err := h.service.RefreshToken(r.Context(), req)
+	if errors.Is(err, ErrUserSuspended) {
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+		response.JSON(w, http.StatusUnauthorized, response.ResponseBody{
+			Message: "Unauthorized",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrValidationFailed) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "Token refreshed successfully",
+		Data:    tokenResp,
+	})
+}
+
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req LogoutPayload
+
+	err := request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	err = h.service.Logout(r.Context(), req)
+	if errors.Is(err, ErrInvalidRefreshToken) {
+		response.JSON(w, http.StatusUnauthorized, response.ResponseBody{
+			Message: "Unauthorized",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrValidationFailed) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "User logged out successfully",
+	})
+}
+
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	principal, err := middleware.GetPrincipal(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	// tokens issued by this service always carry a valid session ID
+	currentSessionID, _ := uuid.Parse(principal.SessionID)
+
+	sessionResp, err := h.service.ListSessions(r.Context(), principal.UserID, currentSessionID)
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    sessionResp,
+	})
+}
+
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	params := mux.Vars(r)
+	sessionID, err := uuid.Parse(params["sessionId"])
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   ErrSessionNotFound.Error(),
+		})
+		return
+	}
+
+	err = h.service.RevokeSession(r.Context(), userID, sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "session revoked successfully",
+	})
+}
+
+func (h *Handler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+
+	err = h.service.RevokeAllSessions(r.Context(), userID)
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "all sessions revoked successfully",
+	})
+}
+
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+
+	profileResp, err := h.service.GetProfile(r.Context(), userID)
 	if errors.Is(err, ErrUserNotFound) {
 		response.JSON(w, http.StatusNotFound, response.ResponseBody{
 			Message: "Not found",
@@ -74,13 +366,586 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	if errors.Is(err, ErrWrongPassword) {
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    profileResp,
+	})
+}
+
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	var req UpdateProfilePayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	profileResp, err := h.service.UpdateProfile(r.Context(), req, userID)
+	if errors.Is(err, ErrValidationFailed) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "profile updated successfully",
+		Data:    profileResp,
+	})
+}
+
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	principal, err := middleware.GetPrincipal(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	// tokens issued by this service always carry a valid session ID
+	currentSessionID, _ := uuid.Parse(principal.SessionID)
+	var req ChangePasswordPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.IPAddress = clientInfo(r, "").IPAddress
+
+	err = h.service.ChangePassword(r.Context(), req, principal.UserID, currentSessionID)
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		writeTooManyRequests(w, throttled)
+		return
+	}
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrWrongPassword) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "password changed successfully",
+	})
+}
+
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	principal, err := middleware.GetPrincipal(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	var req DeleteAccountPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.IPAddress = clientInfo(r, "").IPAddress
+
+	err = h.service.DeleteAccount(r.Context(), req, principal.UserID)
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		writeTooManyRequests(w, throttled)
+		return
+	}
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrWrongPassword) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrOpenOrders) {
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "account deleted successfully",
+	})
+}
+
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	var req ChangeEmailPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.IPAddress = clientInfo(r, "").IPAddress
+
+	profileResp, err := h.service.ChangeEmail(r.Context(), req, userID)
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		writeTooManyRequests(w, throttled)
+		return
+	}
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrWrongPassword) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrEmailAlreadyExists) {
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "email changed successfully, check your inbox to verify it",
+		Data:    profileResp,
+	})
+}
+
+func (h *Handler) SendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+
+	err = h.service.SendEmailVerification(r.Context(), userID)
+	if errors.Is(err, ErrNoEmail) || errors.Is(err, ErrEmailAlreadyVerified) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusAccepted, response.ResponseBody{
+		Message: "verification email sent",
+	})
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailPayload
+
+	err := request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = h.service.VerifyEmail(r.Context(), req)
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrInvalidToken) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "email verified successfully",
+	})
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordPayload
+
+	err := request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = h.service.ForgotPassword(r.Context(), req)
+	if errors.Is(err, ErrValidationFailed) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusAccepted, response.ResponseBody{
+		Message: "if the address belongs to a verified account, a reset email is on its way",
+	})
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordPayload
+
+	err := request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = h.service.ResetPassword(r.Context(), req)
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrInvalidToken) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "password reset successfully",
+	})
+}
+
+func (h *Handler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	var req CreateAPITokenPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tokenResp, err := h.service.CreateAPIToken(r.Context(), req, userID)
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrTooManyAPITokens) {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusCreated, response.ResponseBody{
+		Message: "api token created successfully, store it now, it cannot be shown again",
+		Data:    tokenResp,
+	})
+}
+
+func (h *Handler) ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+
+	tokenResp, err := h.service.ListAPITokens(r.Context(), userID)
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "success",
+		Data:    tokenResp,
+	})
+}
+
+func (h *Handler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	params := mux.Vars(r)
+	tokenID, err := strconv.ParseUint(params["tokenId"], 10, 64)
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   ErrAPITokenNotFound.Error(),
+		})
+		return
+	}
+
+	err = h.service.RevokeAPIToken(r.Context(), userID, tokenID)
+	if errors.Is(err, ErrAPITokenNotFound) {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response.JSON(w, http.StatusOK, response.ResponseBody{
+		Message: "api token revoked successfully",
+	})
+}
+
+func (h *Handler) BeginTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+
+	enrollmentResp, err := h.service.BeginTOTP(r.Context(), userID)
+	writeTwoFactorResponse(w, "scan the URI with an authenticator app and confirm a code", enrollmentResp, err)
+}
+
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, err := middleware.GetPrincipal(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	// tokens issued by this service always carry a valid session ID
+	sessionID, _ := uuid.Parse(principal.SessionID)
+	var req TwoFactorCodePayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.IPAddress = clientInfo(r, "").IPAddress
+
+	codesResp, err := h.service.ConfirmTOTP(r.Context(), req, principal.UserID, sessionID)
+	writeTwoFactorResponse(w, "two-factor authentication enabled, store the recovery codes now", codesResp, err)
+}
+
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	var req DisableTwoFactorPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.IPAddress = clientInfo(r, "").IPAddress
+
+	err = h.service.DisableTOTP(r.Context(), req, userID)
+	writeTwoFactorResponse(w, "two-factor authentication disabled", nil, err)
+}
+
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	var req TwoFactorCodePayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.IPAddress = clientInfo(r, "").IPAddress
+
+	codesResp, err := h.service.RegenerateRecoveryCodes(r.Context(), req, userID)
+	writeTwoFactorResponse(w, "recovery codes replaced, store them now", codesResp, err)
+}
+
+func (h *Handler) VerifySecondFactor(w http.ResponseWriter, r *http.Request) {
+	principal, err := middleware.GetPrincipal(r)
+	if err != nil {
+		slog.Error(err.Error())
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{})
+		return
+	}
+	// tokens issued by this service always carry a valid session ID
+	sessionID, _ := uuid.Parse(principal.SessionID)
+	var req TwoFactorCodePayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.IPAddress = clientInfo(r, "").IPAddress
+
+	err = h.service.VerifySecondFactor(r.Context(), req, principal.UserID, sessionID)
+	writeTwoFactorResponse(w, "second factor verified", nil, err)
+}
+
+func (h *Handler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	var req SearchUsersPayload
+
+	newSchema := schema.NewDecoder()
+	newSchema.IgnoreUnknownKeys(true)
+	if err := newSchema.Decode(&req, r.URL.Query()); err != nil {
 		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
 			Message: "Bad request",
 			Error:   err.Error(),
 		})
 		return
 	}
+
+	userResp, err := h.service.Search(r.Context(), req)
 	if errors.Is(err, ErrValidationFailed) {
 		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
 			Message: "Bad request",
@@ -96,7 +961,176 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	response.JSON(w, http.StatusOK, response.ResponseBody{
-		Message: "User logged successfully",
+		Message: "success",
 		Data:    userResp,
 	})
 }
+
+func (h *Handler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   ErrUserNotFound.Error(),
+		})
+		return
+	}
+
+	var req SuspendUserPayload
+
+	err = request.DecodeJSON(w, r, &req)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Failed to decode JSON",
+			Error:   err.Error(),
+		})
+		return
+	}
+	audit.SetReason(r.Context(), req.Reason)
+
+	userResp, err := h.service.Suspend(r.Context(), req, userID)
+	writeAdminUserResponse(w, "User suspended successfully", userResp, err)
+}
+
+func (h *Handler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   ErrUserNotFound.Error(),
+		})
+		return
+	}
+
+	userResp, err := h.service.Unsuspend(r.Context(), userID)
+	writeAdminUserResponse(w, "User unsuspended successfully", userResp, err)
+}
+
+func writeAdminUserResponse(w http.ResponseWriter, message string, userResp *AdminUserResponse, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrValidationFailed):
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+	default:
+		response.JSON(w, http.StatusOK, response.ResponseBody{
+			Message: message,
+			Data:    userResp,
+		})
+	}
+}
+
+// writeTwoFactorResponse answers the requests that take a second factor code.
+func writeTwoFactorResponse(w http.ResponseWriter, message string, data any, err error) {
+	var throttled *ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		writeTooManyRequests(w, throttled)
+	case errors.Is(err, ErrValidationFailed), errors.Is(err, ErrInvalidCode), errors.Is(err, ErrInvalidToken),
+		errors.Is(err, ErrWrongPassword), errors.Is(err, ErrTwoFactorNotEnabled):
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrTwoFactorAlreadyEnabled):
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrUserNotFound):
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+	default:
+		response.JSON(w, http.StatusOK, response.ResponseBody{
+			Message: message,
+			Data:    data,
+		})
+	}
+}
+
+func writeOIDCResponse(w http.ResponseWriter, message string, data any, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownProvider):
+		response.JSON(w, http.StatusNotFound, response.ResponseBody{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrValidationFailed), errors.Is(err, ErrInvalidLoginState):
+		response.JSON(w, http.StatusBadRequest, response.ResponseBody{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrProviderLoginFailed):
+		response.JSON(w, http.StatusUnauthorized, response.ResponseBody{
+			Message: "Unauthorized",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrUserSuspended):
+		response.JSON(w, http.StatusForbidden, response.ResponseBody{
+			Message: "Forbidden",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrIdentityAlreadyLinked):
+		response.JSON(w, http.StatusConflict, response.ResponseBody{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+	case errors.Is(err, oidc.ErrDiscoveryFailed):
+		response.JSON(w, http.StatusBadGateway, response.ResponseBody{
+			Message: "Bad gateway",
+			Error:   err.Error(),
+		})
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, response.ResponseBody{
+			Message: "Internal server error",
+			Error:   err.Error(),
+		})
+	default:
+		response.JSON(w, http.StatusOK, response.ResponseBody{
+			Message: message,
+			Data:    data,
+		})
+	}
+}
+
+// writeTooManyRequests answers a throttled request, with the seconds to wait in the
+// Retry-After header.
+func writeTooManyRequests(w http.ResponseWriter, throttled *ThrottledError) {
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	response.JSONWithHeaders(w, http.StatusTooManyRequests, response.ResponseBody{
+		Message: "Too many requests",
+		Error:   throttled.Error(),
+	}, http.Header{"Retry-After": []string{strconv.Itoa(retryAfter)}})
+}
+
+// clientInfo describes the client of r for its session. The IP address is the one the
+// connection comes from, forwarding headers can be set by anyone.
+func clientInfo(r *http.Request, device string) ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return ClientInfo{
+		Device:    device,
+		UserAgent: r.UserAgent(),
+		IPAddress: ip,
+	}
+}
@@ -0,0 +1,109 @@
+package user
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc"
+)
+
+// oidcLoginTTL is how long a user has to sign in at the provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// Identity links a user to their account at an OpenID provider. Subject is the
+// provider's stable id of the account, the email may change at the provider.
+type Identity struct {
+	Provider    string
+	Subject     string
+	UserID      uint64
+	Email       sql.NullString
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+// LoginState remembers a started OpenID Connect login until the provider redirects
+// back. The state sent to the provider is only stored as a hash.
+type LoginState struct {
+	StateHash    []byte
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+}
+
+// newLoginState returns the state to send to the provider, its record and the PKCE
+// code challenge.
+func newLoginState(provider string) (string, *LoginState, string, error) {
+	state, err := randomToken()
+	if err != nil {
+		return "", nil, "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", nil, "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", nil, "", err
+	}
+	return state, &LoginState{
+		StateHash:    hashToken(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}, challenge, nil
+}
+
+// usernameBase picks the start of a username for a new user from the claims, keeping
+// letters, digits, dots, dashes and underscores.
+func usernameBase(claims *oidc.IDTokenClaims) string {
+	candidate := claims.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_') {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, candidate)
+	if base == "" {
+		base = "user"
+	}
+	return truncate(base, 10)
+}
+
+// newUsername appends a random suffix to base so the username is 5 to 15 long and
+// unlikely to be taken.
+func newUsername(base string) (string, error) {
+	const digits = 5
+	n, err := rand.Int(rand.Reader, big.NewInt(100000))
+	if err != nil {
+		return "", err
+	}
+	suffix := n.String()
+	return base + strings.Repeat("0", digits-len(suffix)) + suffix, nil
+}
+
+// displayName picks the name of a new user from the claims. Names too short for a
+// user fall back to the username.
+func displayName(claims *oidc.IDTokenClaims, username string) string {
+	name := strings.TrimSpace(claims.Name)
+	if len([]rune(name)) < 5 {
+		return username
+	}
+	return truncate(name, 50)
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
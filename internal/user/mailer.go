@@ -0,0 +1,62 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/mail"
+)
+
+// Mailer writes the account emails. With an app URL the tokens are sent as links
+// to its /verify-email and /reset-password pages, otherwise as they are.
+type Mailer struct {
+	sender          mail.Sender
+	appURL          string
+	verificationTTL time.Duration
+	resetTTL        time.Duration
+}
+
+func NewMailer(sender mail.Sender, appURL string, verificationTTL, resetTTL time.Duration) *Mailer {
+	return &Mailer{
+		sender:          sender,
+		appURL:          strings.TrimRight(appURL, "/"),
+		verificationTTL: verificationTTL,
+		resetTTL:        resetTTL,
+	}
+}
+
+func (m *Mailer) SendEmailVerification(ctx context.Context, user *User, to, token string) error {
+	return m.sender.Send(ctx, mail.Message{
+		To:      to,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nconfirm that this is your email address:\n\n%s\n\nThe %s is valid for %v.\n",
+			user.Name, m.link("/verify-email", token), m.noun(), m.verificationTTL),
+	})
+}
+
+func (m *Mailer) SendPasswordReset(ctx context.Context, user *User, to, token string) error {
+	return m.sender.Send(ctx, mail.Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of %s. If it was you, choose a new one:\n\n%s\n\n"+
+			"The %s is valid for %v. If it was not you, ignore this email, your password stays as it is.\n",
+			user.Name, user.Username, m.link("/reset-password", token), m.noun(), m.resetTTL),
+	})
+}
+
+func (m *Mailer) link(path, token string) string {
+	if m.appURL == "" {
+		return token
+	}
+	return m.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (m *Mailer) noun() string {
+	if m.appURL == "" {
+		return "code"
+	}
+	return "link"
+}
@@ -0,0 +1,364 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/jwt"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc/oidctest"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/password"
+)
+
+// memoryRepository keeps what an OpenID Connect login touches in memory. Calls to
+// anything else panic on the nil Repository.
+type memoryRepository struct {
+	Repository
+
+	mu            sync.Mutex
+	users         []*User
+	identities    []*Identity
+	states        []*LoginState
+	totps         map[uint64]*TOTP
+	tokens        []*OneTimeToken
+	sessions      []*Session
+	refreshTokens []*RefreshToken
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{totps: map[uint64]*TOTP{}}
+}
+
+func (m *memoryRepository) CreateLoginState(ctx context.Context, state *LoginState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states = append(m.states, state)
+	return nil
+}
+
+func (m *memoryRepository) UseLoginState(ctx context.Context, stateHash []byte) (*LoginState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, st := range m.states {
+		if bytes.Equal(st.StateHash, stateHash) {
+			m.states = append(m.states[:i], m.states[i+1:]...)
+			if time.Now().After(st.ExpiresAt) {
+				return nil, ErrInvalidLoginState
+			}
+			return st, nil
+		}
+	}
+	return nil, ErrInvalidLoginState
+}
+
+func (m *memoryRepository) LoginIdentity(ctx context.Context, provider, subject string, email sql.NullString) (*Identity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, identity := range m.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			identity.Email = email
+			return identity, nil
+		}
+	}
+	return nil, ErrIdentityNotFound
+}
+
+func (m *memoryRepository) CreateIdentity(ctx context.Context, identity *Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.identities = append(m.identities, identity)
+	return nil
+}
+
+func (m *memoryRepository) CreateWithIdentity(ctx context.Context, user *User, identity *Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username == user.Username {
+			return ErrUsernameAlreadyExists
+		}
+		if user.Email.Valid && u.Email == user.Email {
+			return ErrEmailAlreadyExists
+		}
+	}
+	user.ID = uint64(len(m.users) + 1)
+	m.users = append(m.users, user)
+	identity.UserID = user.ID
+	m.identities = append(m.identities, identity)
+	return nil
+}
+
+func (m *memoryRepository) addUser(user *User) *User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user.ID = uint64(len(m.users) + 1)
+	m.users = append(m.users, user)
+	return user
+}
+
+func (m *memoryRepository) GetByID(ctx context.Context, id uint64) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (m *memoryRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email.Valid && u.Email.String == email {
+			return u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (m *memoryRepository) GetTOTP(ctx context.Context, userID uint64) (*TOTP, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.totps[userID]
+	if !ok {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return t, nil
+}
+
+func (m *memoryRepository) CreateOneTimeToken(ctx context.Context, token *OneTimeToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens = append(m.tokens, token)
+	return nil
+}
+
+func (m *memoryRepository) CreateSession(ctx context.Context, session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions = append(m.sessions, session)
+	return nil
+}
+
+func (m *memoryRepository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshTokens = append(m.refreshTokens, token)
+	return nil
+}
+
+// newOIDCService returns a service signing in at a fresh oidctest server named "test".
+func newOIDCService(t *testing.T) (Service, *memoryRepository, *oidctest.Server) {
+	t.Helper()
+	jwt.SetKeySet(jwt.NewSecretKeySet([]byte("test")))
+	server, err := oidctest.Start("client", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	repository := newMemoryRepository()
+	providers := oidc.NewRegistry(oidc.NewProvider(server.Config("test", "http://localhost/callback"), nil))
+	service := NewService(repository, NewSessionCache(repository, time.Minute), nil,
+		password.NewManager(password.NewBcryptHasher(4)), nil,
+		NewTwoFactor(repository, nil, "test", time.Minute), providers, time.Minute, time.Hour)
+	return service, repository, server
+}
+
+// signIn begins a login and follows the authorization URL, returning what the
+// provider redirected back with.
+func signIn(t *testing.T, service Service) OIDCCallbackPayload {
+	t.Helper()
+	begin, err := service.BeginOIDCLogin(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := client.Get(begin.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return OIDCCallbackPayload{
+		Provider: "test",
+		Code:     location.Query().Get("code"),
+		State:    location.Query().Get("state"),
+	}
+}
+
+func TestFinishOIDCLoginStateReuse(t *testing.T) {
+	service, _, _ := newOIDCService(t)
+	callback := signIn(t, service)
+
+	_, err := service.FinishOIDCLogin(context.Background(), callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = service.FinishOIDCLogin(context.Background(), callback)
+	if !errors.Is(err, ErrInvalidLoginState) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidLoginState)
+	}
+}
+
+func TestFinishOIDCLoginUnknownState(t *testing.T) {
+	service, _, _ := newOIDCService(t)
+	callback := signIn(t, service)
+	callback.State = "other state"
+
+	_, err := service.FinishOIDCLogin(context.Background(), callback)
+	if !errors.Is(err, ErrInvalidLoginState) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidLoginState)
+	}
+}
+
+func TestFinishOIDCLoginProviderFailure(t *testing.T) {
+	service, _, server := newOIDCService(t)
+	server.SetClaims(map[string]any{"aud": "other-client"})
+	callback := signIn(t, service)
+
+	_, err := service.FinishOIDCLogin(context.Background(), callback)
+	if !errors.Is(err, ErrProviderLoginFailed) {
+		t.Errorf("err = %v, want %v", err, ErrProviderLoginFailed)
+	}
+}
+
+func TestFinishOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name             string
+		providerVerified bool
+		userVerified     bool
+		wantLinked       bool
+	}{
+		{name: "both verified", providerVerified: true, userVerified: true, wantLinked: true},
+		{name: "provider unverified", providerVerified: false, userVerified: true, wantLinked: false},
+		{name: "user unverified", providerVerified: true, userVerified: false, wantLinked: false},
+		{name: "neither verified", providerVerified: false, userVerified: false, wantLinked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repository, server := newOIDCService(t)
+			existing := repository.addUser(&User{
+				Username:        "existing",
+				Name:            "Existing User",
+				Email:           sql.NullString{String: "user@example.com", Valid: true},
+				EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: tt.userVerified},
+			})
+			server.SetUser(oidctest.User{
+				Subject:       "subject",
+				Email:         "user@example.com",
+				EmailVerified: tt.providerVerified,
+				Name:          "Provider User",
+			})
+
+			resp, err := service.FinishOIDCLogin(context.Background(), signIn(t, service))
+			if err != nil {
+				t.Fatal(err)
+			}
+			identity, err := repository.LoginIdentity(context.Background(), "test", "subject", sql.NullString{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if linked := identity.UserID == existing.ID; linked != tt.wantLinked {
+				t.Errorf("linked = %v, want %v", linked, tt.wantLinked)
+			}
+			if linked := resp.Username == existing.Username; linked != tt.wantLinked {
+				t.Errorf("signed in as %q, linked = %v, want %v", resp.Username, linked, tt.wantLinked)
+			}
+		})
+	}
+}
+
+func TestFinishOIDCLoginKeepsIdentity(t *testing.T) {
+	service, repository, _ := newOIDCService(t)
+
+	first, err := service.FinishOIDCLogin(context.Background(), signIn(t, service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.FinishOIDCLogin(context.Background(), signIn(t, service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Username != second.Username {
+		t.Errorf("second login as %q, want %q", second.Username, first.Username)
+	}
+	if len(repository.users) != 1 {
+		t.Errorf("%d users created, want 1", len(repository.users))
+	}
+}
+
+func TestFinishOIDCLoginTwoFactorChallenge(t *testing.T) {
+	service, repository, server := newOIDCService(t)
+	existing := repository.addUser(&User{
+		Username:        "existing",
+		Name:            "Existing User",
+		Email:           sql.NullString{String: "user@example.com", Valid: true},
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	repository.totps[existing.ID] = &TOTP{
+		UserID:      existing.ID,
+		ConfirmedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	server.SetUser(oidctest.User{
+		Subject:       "subject",
+		Email:         "user@example.com",
+		EmailVerified: true,
+	})
+
+	resp, err := service.FinishOIDCLogin(context.Background(), signIn(t, service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.TwoFactorRequired || resp.ChallengeToken == "" {
+		t.Errorf("response = %+v, want a two-factor challenge", resp)
+	}
+	if resp.AccessToken != "" || resp.RefreshToken != "" {
+		t.Error("tokens issued before the second factor")
+	}
+	if len(repository.sessions) != 0 {
+		t.Errorf("%d sessions started, want 0", len(repository.sessions))
+	}
+	if len(repository.tokens) != 1 || repository.tokens[0].Purpose != PurposeLoginChallenge || repository.tokens[0].UserID != existing.ID {
+		t.Errorf("challenge tokens = %+v", repository.tokens)
+	}
+}
+
+func TestFinishOIDCLoginPendingTwoFactor(t *testing.T) {
+	service, repository, server := newOIDCService(t)
+	existing := repository.addUser(&User{
+		Username:        "existing",
+		Name:            "Existing User",
+		Email:           sql.NullString{String: "user@example.com", Valid: true},
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	repository.totps[existing.ID] = &TOTP{UserID: existing.ID}
+	server.SetUser(oidctest.User{
+		Subject:       "subject",
+		Email:         "user@example.com",
+		EmailVerified: true,
+	})
+
+	resp, err := service.FinishOIDCLogin(context.Background(), signIn(t, service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TwoFactorRequired || resp.AccessToken == "" {
+		t.Errorf("response = %+v, want tokens", resp)
+	}
+}
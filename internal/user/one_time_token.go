@@ -0,0 +1,40 @@
+package user
+
+import (
+	"time"
+)
+
+type TokenPurpose string
+
+const (
+	PurposeEmailVerification TokenPurpose = "email_verification"
+	PurposePasswordReset     TokenPurpose = "password_reset"
+	PurposeLoginChallenge    TokenPurpose = "login_challenge"
+)
+
+// OneTimeToken is mailed to a user to prove they own their email address. Like
+// refresh tokens only a hash is stored; a token works once, before ExpiresAt, and
+// only for the address it was sent to.
+type OneTimeToken struct {
+	ID        uint64
+	UserID    uint64
+	Purpose   TokenPurpose
+	TokenHash []byte
+	Email     string
+	ExpiresAt time.Time
+}
+
+// newOneTimeToken returns the token to mail and its record.
+func newOneTimeToken(userID uint64, purpose TokenPurpose, email string, ttl time.Duration) (string, *OneTimeToken, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &OneTimeToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
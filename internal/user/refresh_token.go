@@ -0,0 +1,56 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is an opaque, long-lived token that is exchanged for a new access
+// token. Every refresh replaces it with a new token of the same family, so a token
+// that is presented twice was stolen and the whole family is revoked.
+type RefreshToken struct {
+	ID        uint64
+	FamilyID  uuid.UUID
+	UserID    uint64
+	TokenHash []byte
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	RevokedAt sql.NullTime
+}
+
+func (t *RefreshToken) Expired(now time.Time) bool {
+	return now.After(t.ExpiresAt)
+}
+
+// newRefreshToken returns the token to hand out and its record, which only keeps a hash.
+func newRefreshToken(userID uint64, familyID uuid.UUID, ttl time.Duration) (string, *RefreshToken, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &RefreshToken{
+		FamilyID:  familyID,
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// randomToken returns 32 random bytes, URL safe encoded.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
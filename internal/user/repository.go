@@ -4,15 +4,68 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/db"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/scope"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
 )
 
 type Repository interface {
 	Create(ctx context.Context, user *User) error
 	GetByUsername(ctx context.Context, username string) (*User, error)
 	GetByID(ctx context.Context, id uint64) (*User, error)
+	GrantRole(ctx context.Context, username string, r role.Role) error
+	RevokeRole(ctx context.Context, username string, r role.Role) error
+	Search(ctx context.Context, filter SearchUsersPayload) ([]*User, error)
+	Suspend(ctx context.Context, id uint64, reason string) error
+	Unsuspend(ctx context.Context, id uint64) error
+	IsSuspended(ctx context.Context, id uint64) (bool, error)
+	UpdatePassword(ctx context.Context, id uint64, from, to string) error
+	GetProfile(ctx context.Context, id uint64) (*Profile, error)
+	UpdateName(ctx context.Context, id uint64, name string) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	SetEmail(ctx context.Context, id uint64, email string) error
+	VerifyEmail(ctx context.Context, id uint64, email string) error
+	CreateOneTimeToken(ctx context.Context, token *OneTimeToken) error
+	UseOneTimeToken(ctx context.Context, tokenHash []byte, purpose TokenPurpose) (*OneTimeToken, error)
+	CreateAPIToken(ctx context.Context, token *APIToken) error
+	ListAPITokens(ctx context.Context, userID uint64) ([]*APIToken, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash []byte) (*APIToken, error)
+	TouchAPIToken(ctx context.Context, id uint64) error
+	RevokeAPIToken(ctx context.Context, userID, id uint64) error
+	GetOneTimeToken(ctx context.Context, tokenHash []byte, purpose TokenPurpose) (*OneTimeToken, error)
+	GetTOTP(ctx context.Context, userID uint64) (*TOTP, error)
+	SaveTOTP(ctx context.Context, t *TOTP) error
+	ConfirmTOTP(ctx context.Context, userID uint64, step int64) error
+	UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID uint64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uint64, codeHashes [][]byte) error
+	UseRecoveryCode(ctx context.Context, userID uint64, codeHash []byte) (bool, error)
+	MarkSecondFactor(ctx context.Context, sessionID uuid.UUID) error
+	CreateLoginState(ctx context.Context, state *LoginState) error
+	UseLoginState(ctx context.Context, stateHash []byte) (*LoginState, error)
+	LoginIdentity(ctx context.Context, provider, subject string, email sql.NullString) (*Identity, error)
+	CreateIdentity(ctx context.Context, identity *Identity) error
+	CreateWithIdentity(ctx context.Context, user *User, identity *Identity) error
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash []byte) (*RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, used *RefreshToken, next *RefreshToken) error
+	CreateSession(ctx context.Context, session *Session) error
+	ListSessions(ctx context.Context, userID uint64) ([]*Session, error)
+	GetSession(ctx context.Context, id uuid.UUID) (*Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID) (revoked bool, err error)
+	RevokeSession(ctx context.Context, id uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uint64, except uuid.UUID) ([]uuid.UUID, error)
+	Anonymize(ctx context.Context, id uint64, username, hashedPassword string) ([]uuid.UUID, error)
+	GetLoginFailures(ctx context.Context, keys []string) ([]*LoginFailure, error)
+	RecordLoginFailure(ctx context.Context, key string, at time.Time, windowStart time.Time) error
+	ClearLoginFailures(ctx context.Context, key string) error
+	PruneLoginFailures(ctx context.Context, before time.Time) error
 }
 
 type dbRepository struct {
@@ -25,64 +78,1083 @@ func NewRepository(db *db.DB) Repository {
 
 // Create implements Repository.
 func (d *dbRepository) Create(ctx context.Context, user *User) error {
-	createUserQuery := `
-		INSERT INTO users (
-			username, name, hashed_password
+	return createUser(ctx, d.db.DB(), user)
+}
+
+// userColumns are the columns scanUser reads.
+const userColumns = `id, username, name, product_sold_total, hashed_password, roles, email, email_verified_at,
+	suspended_at, suspension_reason, created_at, deleted_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (*User, error) {
+	u := &User{}
+	var roles []string
+	err := row.Scan(&u.ID, &u.Username, &u.Name, &u.ProductSoldTotal, &u.HashedPassword, pq.Array(&roles),
+		&u.Email, &u.EmailVerifiedAt, &u.SuspendedAt, &u.SuspensionReason, &u.CreatedAt, &u.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	u.Roles = role.FromStrings(roles)
+	return u, nil
+}
+
+// GetByUsernameAndHashedPassword implements Repository.
+func (d *dbRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	getUserQuery := `SELECT ` + userColumns + ` FROM users
+		WHERE username = $1;
+	`
+	u, err := scanUser(d.db.DB().QueryRowContext(ctx, getUserQuery, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (d *dbRepository) GetByID(ctx context.Context, id uint64) (*User, error) {
+	getUserQuery := `SELECT ` + userColumns + ` FROM users
+		WHERE id = $1;
+	`
+	u, err := scanUser(d.db.DB().QueryRowContext(ctx, getUserQuery, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Search implements Repository.
+func (d *dbRepository) Search(ctx context.Context, filter SearchUsersPayload) ([]*User, error) {
+	searchQuery := `SELECT ` + userColumns + ` FROM users
+		WHERE ($1 = '' OR lower(username) LIKE CONCAT('%', $1::text, '%') OR lower(name) LIKE CONCAT('%', $1::text, '%'))
+		AND (NOT $2 OR suspended_at IS NOT NULL)
+		ORDER BY id
+		LIMIT $3 OFFSET $4;
+	`
+	rows, err := d.db.DB().QueryContext(ctx, searchQuery, strings.ToLower(filter.Search), filter.Suspended, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []*User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Suspend implements Repository.
+func (d *dbRepository) Suspend(ctx context.Context, id uint64, reason string) error {
+	suspendQuery := `
+		UPDATE users
+		SET suspended_at = COALESCE(suspended_at, current_timestamp),
+		suspension_reason = $2
+		WHERE id = $1;
+	`
+	return d.execUser(ctx, suspendQuery, id, reason)
+}
+
+// Unsuspend implements Repository.
+func (d *dbRepository) Unsuspend(ctx context.Context, id uint64) error {
+	unsuspendQuery := `
+		UPDATE users
+		SET suspended_at = NULL,
+		suspension_reason = ''
+		WHERE id = $1;
+	`
+	return d.execUser(ctx, unsuspendQuery, id)
+}
+
+// IsSuspended implements Repository.
+func (d *dbRepository) IsSuspended(ctx context.Context, id uint64) (bool, error) {
+	var suspended bool
+	err := d.db.DB().QueryRowContext(ctx, `SELECT suspended_at IS NOT NULL FROM users WHERE id = $1;`, id).Scan(&suspended)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrUserNotFound
+	}
+	return suspended, err
+}
+
+// UpdatePassword implements Repository. It only replaces the hash if it still is
+// from, so a password changed in the meantime is not overwritten.
+func (d *dbRepository) UpdatePassword(ctx context.Context, id uint64, from, to string) error {
+	updateQuery := `
+		UPDATE users
+		SET hashed_password = $3
+		WHERE id = $1 AND hashed_password = $2;
+	`
+	return d.execUser(ctx, updateQuery, id, from, to)
+}
+
+// GetProfile implements Repository. Removed products and archived bank accounts
+// are not counted.
+func (d *dbRepository) GetProfile(ctx context.Context, id uint64) (*Profile, error) {
+	getProfileQuery := `SELECT ` + userColumns + `,
+			(SELECT COUNT(*) FROM products WHERE products.user_id = users.id AND products.moderation_status <> 'removed'),
+			(SELECT COUNT(*) FROM bank_accounts WHERE bank_accounts.user_id = users.id AND bank_accounts.archived_at IS NULL),
+			EXISTS (SELECT 1 FROM user_totp WHERE user_totp.user_id = users.id AND user_totp.confirmed_at IS NOT NULL)
+		FROM users
+		WHERE id = $1;
+	`
+	p := &Profile{}
+	var roles []string
+	err := d.db.DB().QueryRowContext(ctx, getProfileQuery, id).Scan(&p.ID, &p.Username, &p.Name, &p.ProductSoldTotal, &p.HashedPassword,
+		pq.Array(&roles), &p.Email, &p.EmailVerifiedAt, &p.SuspendedAt, &p.SuspensionReason, &p.CreatedAt, &p.ProductCount, &p.BankAccountCount, &p.TwoFactorEnabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Roles = role.FromStrings(roles)
+	return p, nil
+}
+
+// UpdateName implements Repository.
+func (d *dbRepository) UpdateName(ctx context.Context, id uint64, name string) error {
+	return d.execUser(ctx, `UPDATE users SET name = $2 WHERE id = $1;`, id, name)
+}
+
+// GetByEmail implements Repository. Addresses are matched case insensitively.
+func (d *dbRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	getUserQuery := `SELECT ` + userColumns + ` FROM users
+		WHERE lower(email) = lower($1);
+	`
+	u, err := scanUser(d.db.DB().QueryRowContext(ctx, getUserQuery, email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// SetEmail implements Repository. The new address is unverified.
+func (d *dbRepository) SetEmail(ctx context.Context, id uint64, email string) error {
+	setEmailQuery := `
+		UPDATE users
+		SET email = $2,
+		email_verified_at = NULL
+		WHERE id = $1;
+	`
+	err := d.execUser(ctx, setEmailQuery, id, email)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrEmailAlreadyExists
+	}
+	return err
+}
+
+// VerifyEmail implements Repository. It returns ErrUserNotFound if the address of the
+// user is no longer email.
+func (d *dbRepository) VerifyEmail(ctx context.Context, id uint64, email string) error {
+	verifyEmailQuery := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, current_timestamp)
+		WHERE id = $1 AND lower(email) = lower($2);
+	`
+	return d.execUser(ctx, verifyEmailQuery, id, email)
+}
+
+// CreateOneTimeToken implements Repository. Earlier unused tokens of the user for
+// the same purpose stop working, only the latest mail is valid.
+func (d *dbRepository) CreateOneTimeToken(ctx context.Context, token *OneTimeToken) error {
+	invalidateQuery := `
+		UPDATE user_tokens
+		SET used_at = current_timestamp
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
+	`
+	createQuery := `
+		INSERT INTO user_tokens (
+			user_id, purpose, token_hash, email, expires_at
 		) VALUES (
-			$1, $2, $3
+			$1, $2, $3, $4, $5
 		)
 		RETURNING id;
 	`
-	row := d.db.DB().QueryRowContext(ctx, createUserQuery, user.Username, user.Name, user.HashedPassword)
-	var id uint64
-	err := row.Scan(&id)
-	var pgErr *pgconn.PgError
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, invalidateQuery, token.UserID, token.Purpose)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, createQuery, token.UserID, token.Purpose, token.TokenHash, token.Email, token.ExpiresAt).
+			Scan(&token.ID)
+	})
+}
+
+// UseOneTimeToken implements Repository. Marking the token used and reading it is one
+// statement, so concurrent requests cannot both use it.
+func (d *dbRepository) UseOneTimeToken(ctx context.Context, tokenHash []byte, purpose TokenPurpose) (*OneTimeToken, error) {
+	useTokenQuery := `
+		UPDATE user_tokens
+		SET used_at = current_timestamp
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > current_timestamp
+		RETURNING id, user_id, purpose, token_hash, email, expires_at;
+	`
+	t := &OneTimeToken{}
+	err := d.db.DB().QueryRowContext(ctx, useTokenQuery, tokenHash, purpose).
+		Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.Email, &t.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
 	if err != nil {
-		if errors.As(err, &pgErr) {
-			switch pgErr.Code {
-			case "23505":
-				return ErrUsernameAlreadyExists
-			default:
+		return nil, err
+	}
+	return t, nil
+}
+
+// apiTokenColumns are the columns scanAPIToken reads.
+const apiTokenColumns = `id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at, revoked_at`
+
+func scanAPIToken(row scanner) (*APIToken, error) {
+	t := &APIToken{}
+	var scopes []string
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, pq.Array(&scopes), &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt, &t.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.Scopes = scope.FromStrings(scopes)
+	return t, nil
+}
+
+// CreateAPIToken implements Repository.
+func (d *dbRepository) CreateAPIToken(ctx context.Context, token *APIToken) error {
+	createQuery := `
+		INSERT INTO api_tokens (
+			user_id, name, token_hash, scopes, expires_at
+		) VALUES (
+			$1, $2, $3, $4, $5
+		)
+		RETURNING id, created_at;
+	`
+	return d.db.DB().QueryRowContext(ctx, createQuery, token.UserID, token.Name, token.TokenHash,
+		pq.Array(scope.Strings(token.Scopes)), token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+}
+
+// ListAPITokens implements Repository. Revoked tokens are left out.
+func (d *dbRepository) ListAPITokens(ctx context.Context, userID uint64) ([]*APIToken, error) {
+	listQuery := `SELECT ` + apiTokenColumns + ` FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC;
+	`
+	rows, err := d.db.DB().QueryContext(ctx, listQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tokens []*APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetAPITokenByHash implements Repository.
+func (d *dbRepository) GetAPITokenByHash(ctx context.Context, tokenHash []byte) (*APIToken, error) {
+	getQuery := `SELECT ` + apiTokenColumns + ` FROM api_tokens
+		WHERE token_hash = $1;
+	`
+	t, err := scanAPIToken(d.db.DB().QueryRowContext(ctx, getQuery, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPITokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TouchAPIToken implements Repository. The last use is recorded at most once a
+// minute, integrations call in bursts.
+func (d *dbRepository) TouchAPIToken(ctx context.Context, id uint64) error {
+	touchQuery := `
+		UPDATE api_tokens
+		SET last_used_at = current_timestamp
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < current_timestamp - interval '1 minute');
+	`
+	_, err := d.db.DB().ExecContext(ctx, touchQuery, id)
+	return err
+}
+
+// RevokeAPIToken implements Repository.
+func (d *dbRepository) RevokeAPIToken(ctx context.Context, userID, id uint64) error {
+	revokeQuery := `
+		UPDATE api_tokens
+		SET revoked_at = current_timestamp
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+	`
+	res, err := d.db.DB().ExecContext(ctx, revokeQuery, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// GetOneTimeToken implements Repository. Unlike UseOneTimeToken it leaves the token
+// usable.
+func (d *dbRepository) GetOneTimeToken(ctx context.Context, tokenHash []byte, purpose TokenPurpose) (*OneTimeToken, error) {
+	getTokenQuery := `
+		SELECT id, user_id, purpose, token_hash, email, expires_at
+		FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > current_timestamp;
+	`
+	t := &OneTimeToken{}
+	err := d.db.DB().QueryRowContext(ctx, getTokenQuery, tokenHash, purpose).
+		Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.Email, &t.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetTOTP implements Repository.
+func (d *dbRepository) GetTOTP(ctx context.Context, userID uint64) (*TOTP, error) {
+	getQuery := `
+		SELECT user_id, secret_ciphertext, secret_data_key, secret_key_id, confirmed_at, last_used_step
+		FROM user_totp
+		WHERE user_id = $1;
+	`
+	t := &TOTP{}
+	err := d.db.DB().QueryRowContext(ctx, getQuery, userID).Scan(&t.UserID, &t.Secret.Ciphertext, &t.Secret.DataKey, &t.Secret.KeyID,
+		&t.ConfirmedAt, &t.LastUsedStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SaveTOTP implements Repository. It replaces a pending TOTP of the user, but never
+// a confirmed one.
+func (d *dbRepository) SaveTOTP(ctx context.Context, t *TOTP) error {
+	saveQuery := `
+		INSERT INTO user_totp (
+			user_id, secret_ciphertext, secret_data_key, secret_key_id
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret_ciphertext = EXCLUDED.secret_ciphertext,
+		secret_data_key = EXCLUDED.secret_data_key,
+		secret_key_id = EXCLUDED.secret_key_id,
+		last_used_step = 0,
+		created_at = current_timestamp
+		WHERE user_totp.confirmed_at IS NULL;
+	`
+	res, err := d.db.DB().ExecContext(ctx, saveQuery, t.UserID, t.Secret.Ciphertext, t.Secret.DataKey, t.Secret.KeyID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTwoFactorAlreadyEnabled
+	}
+	return nil
+}
+
+// ConfirmTOTP implements Repository.
+func (d *dbRepository) ConfirmTOTP(ctx context.Context, userID uint64, step int64) error {
+	confirmQuery := `
+		UPDATE user_totp
+		SET confirmed_at = current_timestamp,
+		last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL;
+	`
+	res, err := d.db.DB().ExecContext(ctx, confirmQuery, userID, step)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTwoFactorAlreadyEnabled
+	}
+	return nil
+}
+
+// UseTOTPStep implements Repository. It reports false if step is not after the last
+// used one, also when a concurrent request used it first.
+func (d *dbRepository) UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error) {
+	useStepQuery := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2;
+	`
+	return d.execAffected(ctx, useStepQuery, userID, step)
+}
+
+// DeleteTOTP implements Repository. The recovery codes go with it.
+func (d *dbRepository) DeleteTOTP(ctx context.Context, userID uint64) error {
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1;`, userID)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1;`, userID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrTwoFactorNotEnabled
+		}
+		return nil
+	})
+}
+
+// ReplaceRecoveryCodes implements Repository.
+func (d *dbRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint64, codeHashes [][]byte) error {
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1;`, userID)
+		if err != nil {
+			return err
+		}
+		for _, h := range codeHashes {
+			_, err = tx.ExecContext(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2);`, userID, h)
+			if err != nil {
 				return err
 			}
 		}
+		return nil
+	})
+}
+
+// UseRecoveryCode implements Repository.
+func (d *dbRepository) UseRecoveryCode(ctx context.Context, userID uint64, codeHash []byte) (bool, error) {
+	useCodeQuery := `
+		UPDATE user_recovery_codes
+		SET used_at = current_timestamp
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+	`
+	return d.execAffected(ctx, useCodeQuery, userID, codeHash)
+}
+
+// MarkSecondFactor implements Repository.
+func (d *dbRepository) MarkSecondFactor(ctx context.Context, sessionID uuid.UUID) error {
+	_, err := d.db.DB().ExecContext(ctx, `UPDATE sessions SET second_factor_at = current_timestamp WHERE id = $1;`, sessionID)
+	return err
+}
+
+// CreateLoginState implements Repository. Abandoned logins are cleared on the way.
+func (d *dbRepository) CreateLoginState(ctx context.Context, state *LoginState) error {
+	pruneQuery := `DELETE FROM oidc_login_states WHERE expires_at < current_timestamp;`
+	createQuery := `
+		INSERT INTO oidc_login_states (
+			state_hash, provider, code_verifier, nonce, expires_at
+		) VALUES (
+			$1, $2, $3, $4, $5
+		);
+	`
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, pruneQuery)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, createQuery, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.ExpiresAt)
 		return err
+	})
+}
+
+// UseLoginState implements Repository. A state works once.
+func (d *dbRepository) UseLoginState(ctx context.Context, stateHash []byte) (*LoginState, error) {
+	useStateQuery := `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1
+		RETURNING state_hash, provider, code_verifier, nonce, expires_at;
+	`
+	st := &LoginState{}
+	err := d.db.DB().QueryRowContext(ctx, useStateQuery, stateHash).
+		Scan(&st.StateHash, &st.Provider, &st.CodeVerifier, &st.Nonce, &st.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidLoginState
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(st.ExpiresAt) {
+		return nil, ErrInvalidLoginState
+	}
+	return st, nil
+}
+
+// identityColumns are the columns scanIdentity reads.
+const identityColumns = `provider, subject, user_id, email, created_at, last_login_at`
+
+func scanIdentity(row scanner) (*Identity, error) {
+	i := &Identity{}
+	err := row.Scan(&i.Provider, &i.Subject, &i.UserID, &i.Email, &i.CreatedAt, &i.LastLoginAt)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// LoginIdentity implements Repository. It records the login and the email the
+// provider has now.
+func (d *dbRepository) LoginIdentity(ctx context.Context, provider, subject string, email sql.NullString) (*Identity, error) {
+	loginQuery := `
+		UPDATE user_identities
+		SET last_login_at = current_timestamp,
+		email = $3
+		WHERE provider = $1 AND subject = $2
+		RETURNING ` + identityColumns + `;
+	`
+	i, err := scanIdentity(d.db.DB().QueryRowContext(ctx, loginQuery, provider, subject, email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// CreateIdentity implements Repository.
+func (d *dbRepository) CreateIdentity(ctx context.Context, identity *Identity) error {
+	return createIdentity(ctx, d.db.DB(), identity)
+}
+
+// CreateWithIdentity implements Repository. The user is only created together with
+// the identity.
+func (d *dbRepository) CreateWithIdentity(ctx context.Context, user *User, identity *Identity) error {
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		err := createUser(ctx, tx, user)
+		if err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return createIdentity(ctx, tx, identity)
+	})
+}
+
+// execAffected runs query and reports whether it changed any row.
+func (d *dbRepository) execAffected(ctx context.Context, query string, args ...any) (bool, error) {
+	res, err := d.db.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (d *dbRepository) execUser(ctx context.Context, query string, args ...any) error {
+	res, err := d.db.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
 	}
-	user.ID = id
 	return nil
 }
 
-// GetByUsernameAndHashedPassword implements Repository.
-func (d *dbRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
-	getUserQuery := `
-		SELECT id, username, name, hashed_password FROM users
+// GrantRole implements Repository.
+func (d *dbRepository) GrantRole(ctx context.Context, username string, r role.Role) error {
+	grantQuery := `
+		UPDATE users
+		SET roles = CASE WHEN $2 = ANY(roles) THEN roles ELSE array_append(roles, $2) END
 		WHERE username = $1;
 	`
-	row := d.db.DB().QueryRowContext(ctx, getUserQuery, username)
-	u := &User{}
-	err := row.Scan(&u.ID, &u.Username, &u.Name, &u.HashedPassword)
+	return d.updateRoles(ctx, grantQuery, username, r)
+}
+
+// RevokeRole implements Repository.
+func (d *dbRepository) RevokeRole(ctx context.Context, username string, r role.Role) error {
+	revokeQuery := `
+		UPDATE users
+		SET roles = array_remove(roles, $2)
+		WHERE username = $1;
+	`
+	return d.updateRoles(ctx, revokeQuery, username, r)
+}
+
+func (d *dbRepository) updateRoles(ctx context.Context, query string, username string, r role.Role) error {
+	return d.execUser(ctx, query, username, string(r))
+}
+
+// CreateRefreshToken implements Repository.
+func (d *dbRepository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	return createRefreshToken(ctx, d.db.DB(), token)
+}
+
+// GetRefreshToken implements Repository.
+func (d *dbRepository) GetRefreshToken(ctx context.Context, tokenHash []byte) (*RefreshToken, error) {
+	getQuery := `
+		SELECT id, family_id, user_id, token_hash, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1;
+	`
+	row := d.db.DB().QueryRowContext(ctx, getQuery, tokenHash)
+	t := &RefreshToken{}
+	err := row.Scan(&t.ID, &t.FamilyID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.RevokedAt)
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrUserNotFound
+		return nil, ErrInvalidRefreshToken
 	}
 	if err != nil {
 		return nil, err
 	}
-	return u, nil
+	return t, nil
 }
 
-func (d *dbRepository) GetByID(ctx context.Context, id uint64) (*User, error) {
-	getUserQuery := `
-		SELECT id, username, name, product_sold_total, hashed_password FROM users
+// RotateRefreshToken implements Repository. It fails with ErrRefreshTokenReused when
+// used was already exchanged, e.g. by a concurrent refresh.
+func (d *dbRepository) RotateRefreshToken(ctx context.Context, used *RefreshToken, next *RefreshToken) error {
+	useQuery := `
+		UPDATE refresh_tokens
+		SET used_at = current_timestamp
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL;
+	`
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, useQuery, used.ID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+		return createRefreshToken(ctx, tx, next)
+	})
+}
+
+// CreateSession implements Repository.
+func (d *dbRepository) CreateSession(ctx context.Context, session *Session) error {
+	createQuery := `
+		INSERT INTO sessions (
+			id, user_id, device, user_agent, ip_address, second_factor_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		)
+		RETURNING created_at, last_seen_at;
+	`
+	row := d.db.DB().QueryRowContext(ctx, createQuery, session.ID, session.UserID, session.Device, session.UserAgent, session.IPAddress,
+		session.SecondFactorAt)
+	return row.Scan(&session.CreatedAt, &session.LastSeenAt)
+}
+
+// ListSessions implements Repository. Revoked sessions are left out.
+func (d *dbRepository) ListSessions(ctx context.Context, userID uint64) ([]*Session, error) {
+	listQuery := `
+		SELECT id, user_id, device, user_agent, ip_address, created_at, last_seen_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC;
+	`
+	rows, err := d.db.DB().QueryContext(ctx, listQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := make([]*Session, 0)
+	for rows.Next() {
+		s := &Session{}
+		err := rows.Scan(&s.ID, &s.UserID, &s.Device, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// GetSession implements Repository.
+func (d *dbRepository) GetSession(ctx context.Context, id uuid.UUID) (*Session, error) {
+	getQuery := `
+		SELECT id, user_id, device, user_agent, ip_address, created_at, last_seen_at, revoked_at, second_factor_at
+		FROM sessions
 		WHERE id = $1;
 	`
-	row := d.db.DB().QueryRowContext(ctx, getUserQuery, id)
-	u := &User{}
-	err := row.Scan(&u.ID, &u.Username, &u.Name, &u.ProductSoldTotal, &u.HashedPassword)
+	row := d.db.DB().QueryRowContext(ctx, getQuery, id)
+	s := &Session{}
+	err := row.Scan(&s.ID, &s.UserID, &s.Device, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt, &s.SecondFactorAt)
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrUserNotFound
+		return nil, ErrSessionNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	return u, nil
+	return s, nil
+}
+
+// TouchSession implements Repository. It records the session as seen and reports
+// whether it is revoked; unknown sessions count as revoked.
+func (d *dbRepository) TouchSession(ctx context.Context, id uuid.UUID) (bool, error) {
+	touchQuery := `
+		UPDATE sessions
+		SET last_seen_at = current_timestamp
+		WHERE id = $1
+		RETURNING revoked_at IS NOT NULL;
+	`
+	var revoked bool
+	err := d.db.DB().QueryRowContext(ctx, touchQuery, id).Scan(&revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// RevokeSession implements Repository. The refresh tokens of the session are revoked with it.
+func (d *dbRepository) RevokeSession(ctx context.Context, id uuid.UUID) error {
+	revokeQuery := `
+		UPDATE sessions
+		SET revoked_at = current_timestamp
+		WHERE id = $1 AND revoked_at IS NULL;
+	`
+	return d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, revokeQuery, id)
+		if err != nil {
+			return err
+		}
+		return revokeRefreshTokens(ctx, tx, []uuid.UUID{id})
+	})
+}
+
+// RevokeAllSessions implements Repository. It returns the IDs of the sessions it revoked.
+func (d *dbRepository) RevokeAllSessions(ctx context.Context, userID uint64, except uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		ids, err = revokeSessions(ctx, tx, userID, except)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// revokeSessions revokes the sessions of the user but except, with their refresh tokens.
+func revokeSessions(ctx context.Context, tx *sql.Tx, userID uint64, except uuid.UUID) ([]uuid.UUID, error) {
+	revokeQuery := `
+		UPDATE sessions
+		SET revoked_at = current_timestamp
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+		RETURNING id;
+	`
+	rows, err := tx.QueryContext(ctx, revokeQuery, userID, except)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, revokeRefreshTokens(ctx, tx, ids)
+}
+
+func revokeRefreshTokens(ctx context.Context, tx *sql.Tx, familyIDs []uuid.UUID) error {
+	revokeQuery := `
+		UPDATE refresh_tokens
+		SET revoked_at = current_timestamp
+		WHERE family_id = ANY($1) AND revoked_at IS NULL;
+	`
+	_, err := tx.ExecContext(ctx, revokeQuery, pq.Array(familyIDs))
+	return err
+}
+
+// Anonymize implements Repository. It strips the user of personal data and of every way
+// to log in, unlists their products and archives their bank accounts, in place of
+// deleting the row, which would cascade into the purchase records of others. It
+// returns ErrOpenOrders while purchases or returns of the user are unfinished, and the
+// IDs of the sessions it revoked otherwise.
+func (d *dbRepository) Anonymize(ctx context.Context, id uint64, username, hashedPassword string) ([]uuid.UUID, error) {
+	openOrdersQuery := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_transactions t
+			JOIN products p ON p.uid = t.product_id
+			WHERE (t.user_id = $1 OR p.user_id = $1)
+			AND (t.status = 'pending' OR (t.status = 'paid' AND t.shipping_status IN ('awaiting_shipment', 'shipped')))
+		) OR EXISTS (
+			SELECT 1 FROM return_requests
+			WHERE (buyer_id = $1 OR seller_id = $1) AND status IN ('requested', 'disputed')
+		);
+	`
+	anonymizeQuery := `
+		UPDATE users
+		SET username = $2,
+		name = 'Deleted user',
+		hashed_password = $3,
+		email = NULL,
+		email_verified_at = NULL,
+		roles = '{}',
+		deleted_at = current_timestamp
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+	unlistProductsQuery := `
+		UPDATE products
+		SET moderation_status = 'unlisted',
+		moderation_reason = 'seller account deleted',
+		moderated_at = current_timestamp,
+		is_purchaseable = false
+		WHERE user_id = $1 AND moderation_status = 'listed';
+	`
+	// accounts purchases refer to are archived, like when their owner deletes them
+	deleteBankAccountsQuery := `
+		DELETE FROM bank_accounts b
+		WHERE user_id = $1
+		AND NOT EXISTS (SELECT 1 FROM user_transactions WHERE bank_account_id = b.uid);
+	`
+	archiveBankAccountsQuery := `
+		UPDATE bank_accounts
+		SET archived_at = COALESCE(archived_at, current_timestamp),
+		is_primary = false
+		WHERE user_id = $1;
+	`
+	revokeAPITokensQuery := `
+		UPDATE api_tokens
+		SET revoked_at = current_timestamp
+		WHERE user_id = $1 AND revoked_at IS NULL;
+	`
+	deleteQueries := []string{
+		`DELETE FROM addresses WHERE user_id = $1;`,
+		`DELETE FROM user_tokens WHERE user_id = $1;`,
+		`DELETE FROM user_totp WHERE user_id = $1;`,
+		`DELETE FROM user_recovery_codes WHERE user_id = $1;`,
+		`DELETE FROM user_identities WHERE user_id = $1;`,
+		`DELETE FROM shop_members WHERE user_id = $1 OR shop_id IN (SELECT id FROM shops WHERE owner_id = $1);`,
+	}
+	var ids []uuid.UUID
+	err := d.db.StartTx(ctx, func(tx *sql.Tx) error {
+		var openOrders bool
+		err := tx.QueryRowContext(ctx, openOrdersQuery, id).Scan(&openOrders)
+		if err != nil {
+			return err
+		}
+		if openOrders {
+			return ErrOpenOrders
+		}
+		res, err := tx.ExecContext(ctx, anonymizeQuery, id, username, hashedPassword)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrUsernameAlreadyExists
+		}
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		for _, query := range append([]string{unlistProductsQuery, deleteBankAccountsQuery, archiveBankAccountsQuery, revokeAPITokensQuery}, deleteQueries...) {
+			_, err = tx.ExecContext(ctx, query, id)
+			if err != nil {
+				return err
+			}
+		}
+		ids, err = revokeSessions(ctx, tx, id, uuid.Nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetLoginFailures implements Repository.
+func (d *dbRepository) GetLoginFailures(ctx context.Context, keys []string) ([]*LoginFailure, error) {
+	getQuery := `
+		SELECT key, failures, last_failed_at
+		FROM login_failures
+		WHERE key = ANY($1);
+	`
+	rows, err := d.db.DB().QueryContext(ctx, getQuery, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var failures []*LoginFailure
+	for rows.Next() {
+		f := &LoginFailure{}
+		err := rows.Scan(&f.Key, &f.Failures, &f.LastFailedAt)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// RecordLoginFailure implements Repository. Failures from before windowStart are
+// forgotten and counting starts over.
+func (d *dbRepository) RecordLoginFailure(ctx context.Context, key string, at time.Time, windowStart time.Time) error {
+	recordQuery := `
+		INSERT INTO login_failures (
+			key, failures, last_failed_at
+		) VALUES (
+			$1, 1, $2
+		)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE WHEN login_failures.last_failed_at < $3 THEN 1 ELSE login_failures.failures + 1 END,
+		last_failed_at = $2;
+	`
+	_, err := d.db.DB().ExecContext(ctx, recordQuery, key, at, windowStart)
+	return err
+}
+
+// ClearLoginFailures implements Repository.
+func (d *dbRepository) ClearLoginFailures(ctx context.Context, key string) error {
+	_, err := d.db.DB().ExecContext(ctx, `DELETE FROM login_failures WHERE key = $1;`, key)
+	return err
+}
+
+// PruneLoginFailures implements Repository.
+func (d *dbRepository) PruneLoginFailures(ctx context.Context, before time.Time) error {
+	_, err := d.db.DB().ExecContext(ctx, `DELETE FROM login_failures WHERE last_failed_at < $1;`, before)
+	return err
+}
+
+func createUser(ctx context.Context, q queryRower, user *User) error {
+	createUserQuery := `
+		INSERT INTO users (
+			username, name, hashed_password, roles, email, email_verified_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		)
+		RETURNING id;
+	`
+	if user.Roles == nil {
+		user.Roles = role.Default
+	}
+	row := q.QueryRowContext(ctx, createUserQuery, user.Username, user.Name, user.HashedPassword, pq.Array(role.Strings(user.Roles)),
+		user.Email, user.EmailVerifiedAt)
+	var id uint64
+	err := row.Scan(&id)
+	var pgErr *pgconn.PgError
+	if err != nil {
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				if pgErr.ConstraintName == "users_email" {
+					return ErrEmailAlreadyExists
+				}
+				return ErrUsernameAlreadyExists
+			default:
+				return err
+			}
+		}
+		return err
+	}
+	user.ID = id
+	return nil
+}
+
+func createIdentity(ctx context.Context, q queryRower, identity *Identity) error {
+	createQuery := `
+		INSERT INTO user_identities (
+			provider, subject, user_id, email
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		RETURNING created_at, last_login_at;
+	`
+	err := q.QueryRowContext(ctx, createQuery, identity.Provider, identity.Subject, identity.UserID, identity.Email).
+		Scan(&identity.CreatedAt, &identity.LastLoginAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrIdentityAlreadyLinked
+	}
+	return err
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func createRefreshToken(ctx context.Context, q queryRower, token *RefreshToken) error {
+	createQuery := `
+		INSERT INTO refresh_tokens (
+			family_id, user_id, token_hash, expires_at
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		RETURNING id;
+	`
+	return q.QueryRowContext(ctx, createQuery, token.FamilyID, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.ID)
 }
@@ -1,29 +1,269 @@
 package user
 
-import validation "github.com/go-ozzo/ozzo-validation/v4"
+import (
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/password"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/scope"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// ClientInfo describes the device a session is started from. The handler fills in
+// the user agent and IP address.
+type ClientInfo struct {
+	Device    string `json:"device"`
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
+}
 
 type CreateUserPayload struct {
 	Username string `json:"username"`
 	Name     string `json:"name"`
 	Password string `json:"password"`
+	Email    string `json:"email"`
+	ClientInfo
 }
 
 func (p CreateUserPayload) Validate() error {
 	return validation.ValidateStruct(&p,
 		validation.Field(&p.Username, validation.Required, validation.Length(5, 15)),
 		validation.Field(&p.Name, validation.Required, validation.Length(5, 50)),
-		validation.Field(&p.Password, validation.Required, validation.Length(5, 15)),
+		validation.Field(&p.Password, validation.Required, validation.By(newPassword)),
+		validation.Field(&p.Email, validation.Length(0, 254), is.EmailFormat),
+		validation.Field(&p.Device, validation.Length(0, 100)),
 	)
 }
 
 type LoginPayload struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	ClientInfo
 }
 
 func (p LoginPayload) Validate() error {
 	return validation.ValidateStruct(&p,
 		validation.Field(&p.Username, validation.Required, validation.Length(5, 15)),
-		validation.Field(&p.Password, validation.Required, validation.Length(5, 15)),
+		// passwords set under an older policy have to keep working
+		validation.Field(&p.Password, validation.Required, validation.Length(1, password.MaxLength)),
+		validation.Field(&p.Device, validation.Length(0, 100)),
+	)
+}
+
+// newPassword applies the password policy to a password that is being set.
+func newPassword(value interface{}) error {
+	s, _ := value.(string)
+	return password.Check(s)
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (p RefreshTokenPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.RefreshToken, validation.Required),
+	)
+}
+
+type LogoutPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (p LogoutPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.RefreshToken, validation.Required),
+	)
+}
+
+type SearchUsersPayload struct {
+	Search    string `schema:"search"`
+	Suspended bool   `schema:"suspended"`
+	Limit     int    `schema:"limit"`
+	Offset    int    `schema:"offset"`
+}
+
+func (p SearchUsersPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Search, validation.Length(0, 50)),
+		validation.Field(&p.Limit, validation.Min(0), validation.Max(100)),
+		validation.Field(&p.Offset, validation.Min(0)),
+	)
+}
+
+type SuspendUserPayload struct {
+	Reason string `json:"reason"`
+}
+
+func (p SuspendUserPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Reason, validation.Required, validation.Length(5, 500)),
+	)
+}
+
+type UpdateProfilePayload struct {
+	Name string `json:"name"`
+}
+
+func (p UpdateProfilePayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Name, validation.Required, validation.Length(5, 50)),
+	)
+}
+
+// ChangePasswordPayload changes the password of the requesting user. The handler
+// fills in the IP address, wrong current passwords are throttled like logins.
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	IPAddress       string `json:"-"`
+}
+
+func (p ChangePasswordPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CurrentPassword, validation.Required, validation.Length(1, password.MaxLength)),
+		validation.Field(&p.NewPassword, validation.Required, validation.By(newPassword)),
+	)
+}
+
+// DeleteAccountPayload confirms deleting the account of the requesting user with
+// their password.
+type DeleteAccountPayload struct {
+	CurrentPassword string `json:"currentPassword"`
+	IPAddress       string `json:"-"`
+}
+
+func (p DeleteAccountPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CurrentPassword, validation.Required, validation.Length(1, password.MaxLength)),
+	)
+}
+
+// ChangeEmailPayload sets the email address of the requesting user. Password resets
+// go to that address, so it takes the current password like ChangePasswordPayload.
+type ChangeEmailPayload struct {
+	Email           string `json:"email"`
+	CurrentPassword string `json:"currentPassword"`
+	IPAddress       string `json:"-"`
+}
+
+func (p ChangeEmailPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Email, validation.Required, validation.Length(0, 254), is.EmailFormat),
+		validation.Field(&p.CurrentPassword, validation.Required, validation.Length(1, password.MaxLength)),
+	)
+}
+
+type VerifyEmailPayload struct {
+	Token string `json:"token"`
+}
+
+func (p VerifyEmailPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Token, validation.Required, validation.Length(1, 100)),
+	)
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email"`
+}
+
+func (p ForgotPasswordPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Email, validation.Required, validation.Length(0, 254), is.EmailFormat),
+	)
+}
+
+type ResetPasswordPayload struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+func (p ResetPasswordPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Token, validation.Required, validation.Length(1, 100)),
+		validation.Field(&p.NewPassword, validation.Required, validation.By(newPassword)),
+	)
+}
+
+// CreateAPITokenPayload creates an API token, without ExpiresAt it works until revoked.
+type CreateAPITokenPayload struct {
+	Name      string        `json:"name"`
+	Scopes    []scope.Scope `json:"scopes"`
+	ExpiresAt *time.Time    `json:"expiresAt"`
+}
+
+func (p CreateAPITokenPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Name, validation.Required, validation.Length(1, 50)),
+		validation.Field(&p.Scopes, validation.Required, validation.Each(validation.By(validScope))),
+		validation.Field(&p.ExpiresAt, validation.Min(time.Now()).Error("must be in the future")),
+	)
+}
+
+func validScope(value interface{}) error {
+	s, _ := value.(scope.Scope)
+	if !s.Valid() {
+		return validation.NewError("validation_invalid_scope", "must be a known scope")
+	}
+	return nil
+}
+
+// LoginTwoFactorPayload is the second step of a login with two factors. Code is a TOTP
+// code or a recovery code.
+type LoginTwoFactorPayload struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+	ClientInfo
+}
+
+func (p LoginTwoFactorPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.ChallengeToken, validation.Required, validation.Length(1, 100)),
+		validation.Field(&p.Code, validation.Required, validation.Length(1, 20)),
+		validation.Field(&p.Device, validation.Length(0, 100)),
+	)
+}
+
+// TwoFactorCodePayload carries a TOTP code or a recovery code, the handler fills in
+// the IP address for throttling.
+type TwoFactorCodePayload struct {
+	Code      string `json:"code"`
+	IPAddress string `json:"-"`
+}
+
+func (p TwoFactorCodePayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Code, validation.Required, validation.Length(1, 20)),
+	)
+}
+
+type DisableTwoFactorPayload struct {
+	CurrentPassword string `json:"currentPassword"`
+	Code            string `json:"code"`
+	IPAddress       string `json:"-"`
+}
+
+func (p DisableTwoFactorPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CurrentPassword, validation.Required, validation.Length(1, password.MaxLength)),
+		validation.Field(&p.Code, validation.Required, validation.Length(1, 20)),
+	)
+}
+
+// OIDCCallbackPayload finishes a login at a provider with what it redirected back
+// with. The handler fills in Provider from the path.
+type OIDCCallbackPayload struct {
+	Provider string `json:"-"`
+	Code     string `json:"code"`
+	State    string `json:"state"`
+	ClientInfo
+}
+
+func (p OIDCCallbackPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Code, validation.Required, validation.Length(1, 2048)),
+		validation.Field(&p.State, validation.Required, validation.Length(1, 100)),
+		validation.Field(&p.Device, validation.Length(0, 100)),
 	)
 }
@@ -1,7 +1,155 @@
 package user
 
+import (
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/scope"
+	"github.com/google/uuid"
+)
+
+// UserResponse answers a login. With two factors the password step only carries
+// TwoFactorRequired and the ChallengeToken for the code step.
 type UserResponse struct {
-	Username    string `json:"username"`
-	Name        string `json:"name"`
-	AccessToken string `json:"accessToken"`
+	Username          string `json:"username"`
+	Name              string `json:"name"`
+	AccessToken       string `json:"accessToken"`
+	RefreshToken      string `json:"refreshToken"`
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
+}
+
+// TokenResponse carries a new token pair, ExpiresIn is the lifetime of the access token in seconds.
+type TokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+type SessionResponse struct {
+	SessionID  uuid.UUID `json:"sessionId"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+}
+
+func CreateSessionResponse(session *Session, currentSessionID uuid.UUID) *SessionResponse {
+	return &SessionResponse{
+		SessionID:  session.ID,
+		Device:     session.Device,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		Current:    session.ID == currentSessionID,
+	}
+}
+
+// AdminUserResponse is what admins see of a user.
+type AdminUserResponse struct {
+	UserID           uint64      `json:"userId"`
+	Username         string      `json:"username"`
+	Name             string      `json:"name"`
+	Roles            []role.Role `json:"roles"`
+	ProductSoldTotal int         `json:"productSoldTotal"`
+	Suspended        bool        `json:"suspended"`
+	SuspendedAt      *time.Time  `json:"suspendedAt,omitempty"`
+	SuspensionReason string      `json:"suspensionReason,omitempty"`
+}
+
+func CreateAdminUserResponse(user *User) *AdminUserResponse {
+	resp := &AdminUserResponse{
+		UserID:           user.ID,
+		Username:         user.Username,
+		Name:             user.Name,
+		Roles:            user.Roles,
+		ProductSoldTotal: user.ProductSoldTotal,
+		Suspended:        user.IsSuspended(),
+		SuspensionReason: user.SuspensionReason,
+	}
+	if user.IsSuspended() {
+		resp.SuspendedAt = &user.SuspendedAt.Time
+	}
+	return resp
+}
+
+type ProfileResponse struct {
+	Username         string     `json:"username"`
+	Name             string     `json:"name"`
+	Email            string     `json:"email,omitempty"`
+	EmailVerified    bool       `json:"emailVerified"`
+	TwoFactorEnabled bool       `json:"twoFactorEnabled"`
+	ProductSoldTotal int        `json:"productSoldTotal"`
+	ProductCount     int        `json:"productCount"`
+	BankAccountCount int        `json:"bankAccountCount"`
+	CreatedAt        *time.Time `json:"createdAt"`
+}
+
+func CreateProfileResponse(profile *Profile) *ProfileResponse {
+	resp := &ProfileResponse{
+		Username:         profile.Username,
+		Name:             profile.Name,
+		Email:            profile.Email.String,
+		EmailVerified:    profile.EmailVerified(),
+		TwoFactorEnabled: profile.TwoFactorEnabled,
+		ProductSoldTotal: profile.ProductSoldTotal,
+		ProductCount:     profile.ProductCount,
+		BankAccountCount: profile.BankAccountCount,
+	}
+	// users registered before the creation date was recorded have none
+	if profile.CreatedAt.Valid {
+		resp.CreatedAt = &profile.CreatedAt.Time
+	}
+	return resp
+}
+
+type APITokenResponse struct {
+	ID         uint64        `json:"id"`
+	Name       string        `json:"name"`
+	Scopes     []scope.Scope `json:"scopes"`
+	ExpiresAt  *time.Time    `json:"expiresAt"`
+	LastUsedAt *time.Time    `json:"lastUsedAt"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	// Token is only set when the token is created, it cannot be read again.
+	Token string `json:"token,omitempty"`
+}
+
+func CreateAPITokenResponse(token *APIToken) *APITokenResponse {
+	resp := &APITokenResponse{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+	}
+	if token.ExpiresAt.Valid {
+		resp.ExpiresAt = &token.ExpiresAt.Time
+	}
+	if token.LastUsedAt.Valid {
+		resp.LastUsedAt = &token.LastUsedAt.Time
+	}
+	return resp
+}
+
+// TOTPEnrollmentResponse is shown once to set up an authenticator app, from the URI as
+// a QR code or by typing the secret.
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// OIDCLoginResponse sends the user to their provider to sign in.
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+}
+
+// ProvidersResponse lists the providers users can sign in with.
+type ProvidersResponse struct {
+	Providers []string `json:"providers"`
 }
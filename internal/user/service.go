@@ -2,24 +2,81 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/jwt"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/oidc"
 	"github.com/citadel-corp/shopifyx-marketplace/internal/common/password"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/totp"
+	"github.com/google/uuid"
 )
 
 type Service interface {
 	Create(ctx context.Context, req CreateUserPayload) (*UserResponse, error)
 	Login(ctx context.Context, req LoginPayload) (*UserResponse, error)
+	RefreshToken(ctx context.Context, req RefreshTokenPayload) (*TokenResponse, error)
+	Logout(ctx context.Context, req LogoutPayload) error
+	ListSessions(ctx context.Context, userID uint64, currentSessionID uuid.UUID) ([]*SessionResponse, error)
+	RevokeSession(ctx context.Context, userID uint64, sessionID uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uint64) error
+	GetProfile(ctx context.Context, userID uint64) (*ProfileResponse, error)
+	UpdateProfile(ctx context.Context, req UpdateProfilePayload, userID uint64) (*ProfileResponse, error)
+	ChangePassword(ctx context.Context, req ChangePasswordPayload, userID uint64, currentSessionID uuid.UUID) error
+	DeleteAccount(ctx context.Context, req DeleteAccountPayload, userID uint64) error
+	ChangeEmail(ctx context.Context, req ChangeEmailPayload, userID uint64) (*ProfileResponse, error)
+	SendEmailVerification(ctx context.Context, userID uint64) error
+	VerifyEmail(ctx context.Context, req VerifyEmailPayload) error
+	ForgotPassword(ctx context.Context, req ForgotPasswordPayload) error
+	ResetPassword(ctx context.Context, req ResetPasswordPayload) error
+	CreateAPIToken(ctx context.Context, req CreateAPITokenPayload, userID uint64) (*APITokenResponse, error)
+	ListAPITokens(ctx context.Context, userID uint64) ([]*APITokenResponse, error)
+	RevokeAPIToken(ctx context.Context, userID, tokenID uint64) error
+	LoginTwoFactor(ctx context.Context, req LoginTwoFactorPayload) (*UserResponse, error)
+	BeginTOTP(ctx context.Context, userID uint64) (*TOTPEnrollmentResponse, error)
+	ConfirmTOTP(ctx context.Context, req TwoFactorCodePayload, userID uint64, sessionID uuid.UUID) (*RecoveryCodesResponse, error)
+	DisableTOTP(ctx context.Context, req DisableTwoFactorPayload, userID uint64) error
+	RegenerateRecoveryCodes(ctx context.Context, req TwoFactorCodePayload, userID uint64) (*RecoveryCodesResponse, error)
+	VerifySecondFactor(ctx context.Context, req TwoFactorCodePayload, userID uint64, sessionID uuid.UUID) error
+	ListProviders(ctx context.Context) (*ProvidersResponse, error)
+	BeginOIDCLogin(ctx context.Context, provider string) (*OIDCLoginResponse, error)
+	FinishOIDCLogin(ctx context.Context, req OIDCCallbackPayload) (*UserResponse, error)
+	Search(ctx context.Context, req SearchUsersPayload) ([]*AdminUserResponse, error)
+	Suspend(ctx context.Context, req SuspendUserPayload, userID uint64) (*AdminUserResponse, error)
+	Unsuspend(ctx context.Context, userID uint64) (*AdminUserResponse, error)
 }
 
 type userService struct {
-	repository Repository
+	repository      Repository
+	sessions        *SessionCache
+	throttle        *LoginThrottle
+	passwords       *password.Manager
+	mailer          *Mailer
+	twoFactor       *TwoFactor
+	providers       *oidc.Registry
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
 }
 
-func NewService(repository Repository) Service {
-	return &userService{repository: repository}
+func NewService(repository Repository, sessions *SessionCache, throttle *LoginThrottle, passwords *password.Manager,
+	mailer *Mailer, twoFactor *TwoFactor, providers *oidc.Registry, accessTokenTTL, refreshTokenTTL time.Duration) Service {
+	return &userService{
+		repository:      repository,
+		sessions:        sessions,
+		throttle:        throttle,
+		passwords:       passwords,
+		mailer:          mailer,
+		twoFactor:       twoFactor,
+		providers:       providers,
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+	}
 }
 
 func (s *userService) Create(ctx context.Context, req CreateUserPayload) (*UserResponse, error) {
@@ -27,7 +84,7 @@ func (s *userService) Create(ctx context.Context, req CreateUserPayload) (*UserR
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
 	}
-	hashedPassword, err := password.Hash(req.Password)
+	hashedPassword, err := s.passwords.Hash(req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -35,20 +92,28 @@ func (s *userService) Create(ctx context.Context, req CreateUserPayload) (*UserR
 		Username:       req.Username,
 		Name:           req.Name,
 		HashedPassword: hashedPassword,
+		Email:          sql.NullString{String: req.Email, Valid: req.Email != ""},
 	}
 	err = s.repository.Create(ctx, user)
 	if err != nil {
 		return nil, err
 	}
-	// create access token with signed jwt
-	accessToken, err := jwt.Sign(time.Minute*2, fmt.Sprint(user.ID))
+	tokens, err := s.startSession(ctx, user, req.ClientInfo, false)
 	if err != nil {
 		return nil, err
 	}
+	// the user is registered either way, they can ask for another mail
+	if user.Email.Valid {
+		err = s.sendEmailVerification(ctx, user)
+		if err != nil {
+			slog.Error(fmt.Sprintf("cannot send email verification to user %d: %v", user.ID, err))
+		}
+	}
 	return &UserResponse{
-		Username:    req.Username,
-		Name:        req.Name,
-		AccessToken: accessToken,
+		Username:     req.Username,
+		Name:         req.Name,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
@@ -57,25 +122,945 @@ func (s *userService) Login(ctx context.Context, req LoginPayload) (*UserRespons
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
 	}
+	retryAfter, err := s.throttle.Check(ctx, req.Username, req.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		return nil, &ThrottledError{RetryAfter: retryAfter}
+	}
+
+	// unknown usernames and wrong passwords look the same, in the answer and in time
 	user, err := s.repository.GetByUsername(ctx, req.Username)
+	if errors.Is(err, ErrUserNotFound) {
+		err = s.passwords.MatchesNothing(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.loginFailed(ctx, req)
+	}
 	if err != nil {
 		return nil, err
 	}
-	match, err := password.Matches(req.Password, user.HashedPassword)
+	match, err := s.passwords.Matches(req.Password, user.HashedPassword)
 	if err != nil {
 		return nil, err
 	}
 	if !match {
-		return nil, ErrWrongPassword
+		return nil, s.loginFailed(ctx, req)
 	}
-	// create access token with signed jwt
-	accessToken, err := jwt.Sign(time.Minute*2, fmt.Sprint(user.ID))
+	if s.passwords.NeedsRehash(user.HashedPassword) {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+	// only told after the password matched so it does not reveal who is suspended
+	if user.IsSuspended() {
+		return nil, ErrUserSuspended
+	}
+	twoFactor, err := s.twoFactor.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	// failures are only cleared after the second factor, or knowing the password would
+	// allow guessing codes without end
+	if twoFactor {
+		return s.challengeLogin(ctx, user)
+	}
+	err = s.throttle.Succeed(ctx, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.startSession(ctx, user, req.ClientInfo, false)
+	if err != nil {
+		return nil, err
+	}
+	return &UserResponse{
+		Username:     user.Username,
+		Name:         user.Name,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// challengeLogin answers the password step of a login with two factors. The challenge
+// token is exchanged for tokens by LoginTwoFactor.
+func (s *userService) challengeLogin(ctx context.Context, user *User) (*UserResponse, error) {
+	token, record, err := newOneTimeToken(user.ID, PurposeLoginChallenge, "", s.twoFactor.challengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repository.CreateOneTimeToken(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	return &UserResponse{
+		Username:          user.Username,
+		Name:              user.Name,
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+	}, nil
+}
+
+// LoginTwoFactor implements Service.
+func (s *userService) LoginTwoFactor(ctx context.Context, req LoginTwoFactorPayload) (*UserResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	// the challenge stays valid for another code until it was answered right
+	challenge, err := s.repository.GetOneTimeToken(ctx, hashToken(req.ChallengeToken), PurposeLoginChallenge)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repository.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkSecondFactor(ctx, user, req.Code, req.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.repository.UseOneTimeToken(ctx, challenge.TokenHash, PurposeLoginChallenge)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsSuspended() {
+		return nil, ErrUserSuspended
+	}
+	err = s.throttle.Succeed(ctx, user.Username)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.startSession(ctx, user, req.ClientInfo, true)
 	if err != nil {
 		return nil, err
 	}
 	return &UserResponse{
-		Username:    user.Username,
-		Name:        user.Name,
-		AccessToken: accessToken,
+		Username:     user.Username,
+		Name:         user.Name,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
+
+// checkSecondFactor verifies a TOTP or recovery code of user. Wrong codes are
+// throttled like failed logins.
+func (s *userService) checkSecondFactor(ctx context.Context, user *User, code, ip string) error {
+	retryAfter, err := s.throttle.Check(ctx, user.Username, ip)
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &ThrottledError{RetryAfter: retryAfter}
+	}
+	err = s.twoFactor.Verify(ctx, user.ID, code)
+	if errors.Is(err, ErrInvalidCode) {
+		failErr := s.throttle.Fail(ctx, user.Username, ip)
+		if failErr != nil {
+			return failErr
+		}
+	}
+	return err
+}
+
+// BeginTOTP implements Service.
+func (s *userService) BeginTOTP(ctx context.Context, userID uint64) (*TOTPEnrollmentResponse, error) {
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, uri, err := s.twoFactor.Begin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollmentResponse{
+		Secret: totp.EncodeSecret(secret),
+		URI:    uri,
+	}, nil
+}
+
+// ConfirmTOTP implements Service. The session confirming counts as having given the
+// second factor.
+func (s *userService) ConfirmTOTP(ctx context.Context, req TwoFactorCodePayload, userID uint64, sessionID uuid.UUID) (*RecoveryCodesResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	retryAfter, err := s.throttle.Check(ctx, user.Username, req.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		return nil, &ThrottledError{RetryAfter: retryAfter}
+	}
+	codes, err := s.twoFactor.Confirm(ctx, userID, req.Code)
+	if errors.Is(err, ErrInvalidCode) {
+		failErr := s.throttle.Fail(ctx, user.Username, req.IPAddress)
+		if failErr != nil {
+			return nil, failErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = s.repository.MarkSecondFactor(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP implements Service. It takes the password and a code, a stolen access
+// token alone cannot turn the second factor off.
+func (s *userService) DisableTOTP(ctx context.Context, req DisableTwoFactorPayload, userID uint64) error {
+	err := req.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = s.checkCurrentPassword(ctx, user, req.CurrentPassword, req.IPAddress)
+	if err != nil {
+		return err
+	}
+	err = s.checkSecondFactor(ctx, user, req.Code, req.IPAddress)
+	if err != nil {
+		return err
+	}
+	return s.twoFactor.Disable(ctx, userID)
+}
+
+// RegenerateRecoveryCodes implements Service. The earlier codes stop working.
+func (s *userService) RegenerateRecoveryCodes(ctx context.Context, req TwoFactorCodePayload, userID uint64) (*RecoveryCodesResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkSecondFactor(ctx, user, req.Code, req.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := s.twoFactor.RegenerateRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// VerifySecondFactor implements Service. It refreshes the second factor of the
+// session, for changes that need a fresh one.
+func (s *userService) VerifySecondFactor(ctx context.Context, req TwoFactorCodePayload, userID uint64, sessionID uuid.UUID) error {
+	err := req.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = s.checkSecondFactor(ctx, user, req.Code, req.IPAddress)
+	if err != nil {
+		return err
+	}
+	return s.repository.MarkSecondFactor(ctx, sessionID)
+}
+
+// maxUsernameAttempts is how often a new user of a provider gets another random
+// username before the login fails.
+const maxUsernameAttempts = 5
+
+// ListProviders implements Service.
+func (s *userService) ListProviders(ctx context.Context) (*ProvidersResponse, error) {
+	return &ProvidersResponse{Providers: s.providers.Names()}, nil
+}
+
+// BeginOIDCLogin implements Service.
+func (s *userService) BeginOIDCLogin(ctx context.Context, providerName string) (*OIDCLoginResponse, error) {
+	provider, ok := s.providers.Get(providerName)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	state, record, codeChallenge, err := newLoginState(provider.Name())
+	if err != nil {
+		return nil, err
+	}
+	authorizationURL, err := provider.AuthCodeURL(ctx, state, record.Nonce, codeChallenge)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repository.CreateLoginState(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCLoginResponse{AuthorizationURL: authorizationURL}, nil
+}
+
+// FinishOIDCLogin implements Service.
+func (s *userService) FinishOIDCLogin(ctx context.Context, req OIDCCallbackPayload) (*UserResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	provider, ok := s.providers.Get(req.Provider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	state, err := s.repository.UseLoginState(ctx, hashToken(req.State))
+	if err != nil {
+		return nil, err
+	}
+	// a login started at one provider cannot be finished with another's code
+	if state.Provider != provider.Name() {
+		return nil, ErrInvalidLoginState
+	}
+	claims, err := provider.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrDiscoveryFailed) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrProviderLoginFailed, err)
+	}
+	user, err := s.oidcUser(ctx, provider.Name(), claims)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsSuspended() {
+		return nil, ErrUserSuspended
+	}
+	// the provider only stands in for the password, a second factor is still asked for
+	twoFactor, err := s.twoFactor.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor {
+		return s.challengeLogin(ctx, user)
+	}
+	tokens, err := s.startSession(ctx, user, req.ClientInfo, false)
+	if err != nil {
+		return nil, err
+	}
+	return &UserResponse{
+		Username:     user.Username,
+		Name:         user.Name,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// oidcUser finds the user of a provider account. An account signing in for the first
+// time is linked to the user with the same email when both sides verified it, and
+// gets a new user otherwise.
+func (s *userService) oidcUser(ctx context.Context, provider string, claims *oidc.IDTokenClaims) (*User, error) {
+	email := sql.NullString{String: claims.Email, Valid: claims.Email != ""}
+	identity, err := s.repository.LoginIdentity(ctx, provider, claims.Subject, email)
+	if err == nil {
+		return s.repository.GetByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return nil, err
+	}
+	identity = &Identity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    email,
+	}
+	if email.Valid && bool(claims.EmailVerified) {
+		user, err := s.repository.GetByEmail(ctx, claims.Email)
+		if err != nil && !errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		// an unverified address could have been typed in by anyone
+		if err == nil && user.EmailVerified() {
+			identity.UserID = user.ID
+			err = s.repository.CreateIdentity(ctx, identity)
+			if err != nil {
+				return nil, err
+			}
+			return user, nil
+		}
+	}
+	return s.createOIDCUser(ctx, claims, identity)
+}
+
+// createOIDCUser creates the user of a new provider account with a random username.
+// The password is random too, the user can set one with a password reset.
+func (s *userService) createOIDCUser(ctx context.Context, claims *oidc.IDTokenClaims, identity *Identity) (*User, error) {
+	randomPassword, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.passwords.Hash(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+	user := &User{HashedPassword: hashedPassword}
+	if identity.Email.Valid && len(claims.Email) <= 254 {
+		user.Email = identity.Email
+		user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: bool(claims.EmailVerified)}
+	}
+	base := usernameBase(claims)
+	for attempt := 0; attempt < maxUsernameAttempts; attempt++ {
+		user.Username, err = newUsername(base)
+		if err != nil {
+			return nil, err
+		}
+		user.Name = displayName(claims, user.Username)
+		err = s.repository.CreateWithIdentity(ctx, user, identity)
+		switch {
+		case errors.Is(err, ErrUsernameAlreadyExists):
+			continue
+		case errors.Is(err, ErrEmailAlreadyExists):
+			// another user has the address without having verified it
+			user.Email = sql.NullString{}
+			user.EmailVerifiedAt = sql.NullTime{}
+			continue
+		case err != nil:
+			return nil, err
+		}
+		return user, nil
+	}
+	return nil, err
+}
+
+// rehashPassword moves the password of user onto the preferred hasher. It only
+// logs failures, the login succeeded either way.
+func (s *userService) rehashPassword(ctx context.Context, user *User, plaintextPassword string) {
+	hashedPassword, err := s.passwords.Hash(plaintextPassword)
+	if err == nil {
+		err = s.repository.UpdatePassword(ctx, user.ID, user.HashedPassword, hashedPassword)
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("cannot rehash password of user %d: %v", user.ID, err))
+		return
+	}
+	user.HashedPassword = hashedPassword
+}
+
+// loginFailed records a failed login and returns the error to answer with.
+func (s *userService) loginFailed(ctx context.Context, req LoginPayload) error {
+	err := s.throttle.Fail(ctx, req.Username, req.IPAddress)
+	if err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// RefreshToken implements Service.
+func (s *userService) RefreshToken(ctx context.Context, req RefreshTokenPayload) (*TokenResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	token, err := s.repository.GetRefreshToken(ctx, hashToken(req.RefreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if token.RevokedAt.Valid || token.Expired(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if token.UsedAt.Valid {
+		return nil, s.revokeReusedFamily(ctx, token)
+	}
+
+	// roles are read again so grants and revocations apply from the next refresh
+	user, err := s.repository.GetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsSuspended() {
+		return nil, ErrUserSuspended
+	}
+	tokens, err := s.issueTokens(ctx, user, token.FamilyID, token)
+	if errors.Is(err, ErrRefreshTokenReused) {
+		return nil, s.revokeReusedFamily(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Logout implements Service.
+func (s *userService) Logout(ctx context.Context, req LogoutPayload) error {
+	err := req.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	token, err := s.repository.GetRefreshToken(ctx, hashToken(req.RefreshToken))
+	if err != nil {
+		return err
+	}
+	return s.revokeSession(ctx, token.FamilyID)
+}
+
+// ListSessions implements Service.
+func (s *userService) ListSessions(ctx context.Context, userID uint64, currentSessionID uuid.UUID) ([]*SessionResponse, error) {
+	sessions, err := s.repository.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*SessionResponse, len(sessions))
+	for i, session := range sessions {
+		resp[i] = CreateSessionResponse(session, currentSessionID)
+	}
+	return resp, nil
+}
+
+// RevokeSession implements Service.
+func (s *userService) RevokeSession(ctx context.Context, userID uint64, sessionID uuid.UUID) error {
+	session, err := s.repository.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// other users' sessions do not exist as far as the caller is concerned
+	if session.UserID != userID || session.RevokedAt.Valid {
+		return ErrSessionNotFound
+	}
+	return s.revokeSession(ctx, sessionID)
+}
+
+// RevokeAllSessions implements Service.
+func (s *userService) RevokeAllSessions(ctx context.Context, userID uint64) error {
+	ids, err := s.repository.RevokeAllSessions(ctx, userID, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	s.sessions.MarkRevoked(ids...)
+	return nil
+}
+
+// GetProfile implements Service.
+func (s *userService) GetProfile(ctx context.Context, userID uint64) (*ProfileResponse, error) {
+	profile, err := s.repository.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return CreateProfileResponse(profile), nil
+}
+
+// UpdateProfile implements Service.
+func (s *userService) UpdateProfile(ctx context.Context, req UpdateProfilePayload, userID uint64) (*ProfileResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	err = s.repository.UpdateName(ctx, userID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetProfile(ctx, userID)
+}
+
+// ChangePassword implements Service. Every session but the current one is revoked,
+// whoever knew the old password is logged out.
+func (s *userService) ChangePassword(ctx context.Context, req ChangePasswordPayload, userID uint64, currentSessionID uuid.UUID) error {
+	err := req.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = s.checkCurrentPassword(ctx, user, req.CurrentPassword, req.IPAddress)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.passwords.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	err = s.repository.UpdatePassword(ctx, userID, user.HashedPassword, hashedPassword)
+	// the password was changed by a concurrent request after it was checked
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrWrongPassword
+	}
+	if err != nil {
+		return err
+	}
+	ids, err := s.repository.RevokeAllSessions(ctx, userID, currentSessionID)
+	if err != nil {
+		return err
+	}
+	s.sessions.MarkRevoked(ids...)
+	return nil
+}
+
+// DeleteAccount implements Service. The user is anonymized rather than deleted and
+// cannot log in again, every session and API token of theirs stops working.
+func (s *userService) DeleteAccount(ctx context.Context, req DeleteAccountPayload, userID uint64) error {
+	err := req.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = s.checkCurrentPassword(ctx, user, req.CurrentPassword, req.IPAddress)
+	if err != nil {
+		return err
+	}
+	username, err := deletedUsername()
+	if err != nil {
+		return err
+	}
+	// nobody knows the new password, so the old one stops working with the username
+	randomPassword, err := randomToken()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := s.passwords.Hash(randomPassword)
+	if err != nil {
+		return err
+	}
+	ids, err := s.repository.Anonymize(ctx, userID, username, hashedPassword)
+	if err != nil {
+		return err
+	}
+	s.sessions.MarkRevoked(ids...)
+	// failures of the freed username must not hold back whoever takes it next
+	return s.throttle.Succeed(ctx, user.Username)
+}
+
+// checkCurrentPassword confirms a change with the password of user. Wrong passwords
+// are throttled like failed logins.
+func (s *userService) checkCurrentPassword(ctx context.Context, user *User, plaintextPassword, ip string) error {
+	retryAfter, err := s.throttle.Check(ctx, user.Username, ip)
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &ThrottledError{RetryAfter: retryAfter}
+	}
+	match, err := s.passwords.Matches(plaintextPassword, user.HashedPassword)
+	if err != nil {
+		return err
+	}
+	if !match {
+		err = s.throttle.Fail(ctx, user.Username, ip)
+		if err != nil {
+			return err
+		}
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// ChangeEmail implements Service. The new address is unverified until the user follows
+// the mail sent to it.
+func (s *userService) ChangeEmail(ctx context.Context, req ChangeEmailPayload, userID uint64) (*ProfileResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkCurrentPassword(ctx, user, req.CurrentPassword, req.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repository.SetEmail(ctx, userID, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	user.Email = sql.NullString{String: req.Email, Valid: true}
+	err = s.sendEmailVerification(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetProfile(ctx, userID)
+}
+
+// SendEmailVerification implements Service.
+func (s *userService) SendEmailVerification(ctx context.Context, userID uint64) error {
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.Email.Valid {
+		return ErrNoEmail
+	}
+	if user.EmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendEmailVerification(ctx, user)
+}
+
+func (s *userService) sendEmailVerification(ctx context.Context, user *User) error {
+	token, record, err := newOneTimeToken(user.ID, PurposeEmailVerification, user.Email.String, s.mailer.verificationTTL)
+	if err != nil {
+		return err
+	}
+	err = s.repository.CreateOneTimeToken(ctx, record)
+	if err != nil {
+		return err
+	}
+	return s.mailer.SendEmailVerification(ctx, user, user.Email.String, token)
+}
+
+// VerifyEmail implements Service.
+func (s *userService) VerifyEmail(ctx context.Context, req VerifyEmailPayload) error {
+	err := req.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	token, err := s.repository.UseOneTimeToken(ctx, hashToken(req.Token), PurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	err = s.repository.VerifyEmail(ctx, token.UserID, token.Email)
+	// the address was changed after the mail was sent
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrInvalidToken
+	}
+	return err
+}
+
+// ForgotPassword implements Service. It answers the same whether or not a user has the
+// address, so it cannot be used to find out who is registered. Only verified addresses
+// get a mail.
+func (s *userService) ForgotPassword(ctx context.Context, req ForgotPasswordPayload) error {
+	err := req.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	user, err := s.repository.GetByEmail(ctx, req.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerified() {
+		return nil
+	}
+	token, record, err := newOneTimeToken(user.ID, PurposePasswordReset, user.Email.String, s.mailer.resetTTL)
+	if err != nil {
+		return err
+	}
+	err = s.repository.CreateOneTimeToken(ctx, record)
+	if err != nil {
+		return err
+	}
+	err = s.mailer.SendPasswordReset(ctx, user, user.Email.String, token)
+	if err != nil {
+		slog.Error(fmt.Sprintf("cannot send password reset to user %d: %v", user.ID, err))
+	}
+	return nil
+}
+
+// ResetPassword implements Service. Every session of the user is revoked and their
+// failed logins are forgotten.
+func (s *userService) ResetPassword(ctx context.Context, req ResetPasswordPayload) error {
+	err := req.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	token, err := s.repository.UseOneTimeToken(ctx, hashToken(req.Token), PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	user, err := s.repository.GetByID(ctx, token.UserID)
+	if err != nil {
+		return err
+	}
+	// the address was changed after the mail was sent
+	if !strings.EqualFold(user.Email.String, token.Email) {
+		return ErrInvalidToken
+	}
+	hashedPassword, err := s.passwords.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	err = s.repository.UpdatePassword(ctx, user.ID, user.HashedPassword, hashedPassword)
+	if err != nil {
+		return err
+	}
+	ids, err := s.repository.RevokeAllSessions(ctx, user.ID, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	s.sessions.MarkRevoked(ids...)
+	return s.throttle.Succeed(ctx, user.Username)
+}
+
+// CreateAPIToken implements Service. The response is the only time the token is shown.
+func (s *userService) CreateAPIToken(ctx context.Context, req CreateAPITokenPayload, userID uint64) (*APITokenResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	existing, err := s.repository.ListAPITokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxAPITokens {
+		return nil, ErrTooManyAPITokens
+	}
+	var expiresAt sql.NullTime
+	if req.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+	}
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	token, record, err := newAPIToken(userID, req.Name, slices.Compact(scopes), expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repository.CreateAPIToken(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	resp := CreateAPITokenResponse(record)
+	resp.Token = token
+	return resp, nil
+}
+
+// ListAPITokens implements Service.
+func (s *userService) ListAPITokens(ctx context.Context, userID uint64) ([]*APITokenResponse, error) {
+	tokens, err := s.repository.ListAPITokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*APITokenResponse, len(tokens))
+	for i, t := range tokens {
+		resp[i] = CreateAPITokenResponse(t)
+	}
+	return resp, nil
+}
+
+// RevokeAPIToken implements Service.
+func (s *userService) RevokeAPIToken(ctx context.Context, userID, tokenID uint64) error {
+	return s.repository.RevokeAPIToken(ctx, userID, tokenID)
+}
+
+// Search implements Service.
+func (s *userService) Search(ctx context.Context, req SearchUsersPayload) ([]*AdminUserResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+	users, err := s.repository.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*AdminUserResponse, len(users))
+	for i, user := range users {
+		resp[i] = CreateAdminUserResponse(user)
+	}
+	return resp, nil
+}
+
+// Suspend implements Service. Suspended users cannot log in or refresh tokens and
+// their access tokens are refused, but their sessions are kept so unsuspending
+// restores them.
+func (s *userService) Suspend(ctx context.Context, req SuspendUserPayload, userID uint64) (*AdminUserResponse, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	err = s.repository.Suspend(ctx, userID, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	s.sessions.MarkSuspended(userID, true)
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return CreateAdminUserResponse(user), nil
+}
+
+// Unsuspend implements Service.
+func (s *userService) Unsuspend(ctx context.Context, userID uint64) (*AdminUserResponse, error) {
+	err := s.repository.Unsuspend(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.sessions.MarkSuspended(userID, false)
+	user, err := s.repository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return CreateAdminUserResponse(user), nil
+}
+
+// startSession records a new login and issues its first tokens.
+func (s *userService) startSession(ctx context.Context, user *User, client ClientInfo, secondFactor bool) (*TokenResponse, error) {
+	session := &Session{
+		ID:             uuid.New(),
+		UserID:         user.ID,
+		Device:         client.Device,
+		UserAgent:      client.UserAgent,
+		IPAddress:      client.IPAddress,
+		SecondFactorAt: sql.NullTime{Time: time.Now(), Valid: secondFactor},
+	}
+	err := s.repository.CreateSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, session.ID, nil)
+}
+
+func (s *userService) revokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	err := s.repository.RevokeSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	s.sessions.MarkRevoked(sessionID)
+	return nil
+}
+
+// issueTokens signs an access token for the session and creates a refresh token in
+// its family, replacing previous when the tokens are refreshed.
+func (s *userService) issueTokens(ctx context.Context, user *User, sessionID uuid.UUID, previous *RefreshToken) (*TokenResponse, error) {
+	// create access token with signed jwt
+	accessToken, err := jwt.Sign(s.accessTokenTTL, fmt.Sprint(user.ID), sessionID.String(), role.Strings(user.Roles))
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, record, err := newRefreshToken(user.ID, sessionID, s.refreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil {
+		err = s.repository.CreateRefreshToken(ctx, record)
+	} else {
+		err = s.repository.RotateRefreshToken(ctx, previous, record)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// revokeReusedFamily handles a refresh token that is presented again after it was
+// exchanged: either the user or an attacker holds a copy, so the session and every
+// token of the family cannot be trusted anymore.
+func (s *userService) revokeReusedFamily(ctx context.Context, token *RefreshToken) error {
+	err := s.revokeSession(ctx, token.FamilyID)
+	if err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
@@ -0,0 +1,143 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login on one device. Its ID is the family of its refresh tokens and
+// the sid claim of its access tokens. SecondFactorAt is when the user last gave a
+// second factor in it.
+type Session struct {
+	ID             uuid.UUID
+	UserID         uint64
+	Device         string
+	UserAgent      string
+	IPAddress      string
+	CreatedAt      time.Time
+	LastSeenAt     time.Time
+	RevokedAt      sql.NullTime
+	SecondFactorAt sql.NullTime
+}
+
+// SessionCache answers whether a session is revoked or a user suspended from
+// memory, and only asks the database, which also records the session as seen, once
+// per ttl. A session revoked or a user suspended through another replica is refused
+// after at most ttl.
+type SessionCache struct {
+	repository Repository
+	ttl        time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]sessionCacheEntry
+	suspended map[uint64]suspensionCacheEntry
+	prunedAt  time.Time
+}
+
+type sessionCacheEntry struct {
+	revoked   bool
+	checkedAt time.Time
+}
+
+type suspensionCacheEntry struct {
+	suspended bool
+	checkedAt time.Time
+}
+
+func NewSessionCache(repository Repository, ttl time.Duration) *SessionCache {
+	return &SessionCache{
+		repository: repository,
+		ttl:        ttl,
+		entries:    make(map[string]sessionCacheEntry),
+		suspended:  make(map[uint64]suspensionCacheEntry),
+	}
+}
+
+// IsSessionRevoked implements middleware.SessionChecker.
+func (c *SessionCache) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[sessionID]
+	c.mu.Unlock()
+	if ok && (entry.revoked || now.Sub(entry.checkedAt) < c.ttl) {
+		return entry.revoked, nil
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return true, nil
+	}
+	revoked, err := c.repository.TouchSession(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[sessionID] = sessionCacheEntry{revoked: revoked, checkedAt: now}
+	c.prune(now)
+	return revoked, nil
+}
+
+// IsUserSuspended implements middleware.SuspensionChecker. Unlike a revoked session
+// a suspension can be lifted, so every answer is only kept for ttl.
+func (c *SessionCache) IsUserSuspended(ctx context.Context, userID uint64) (bool, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.suspended[userID]
+	c.mu.Unlock()
+	if ok && now.Sub(entry.checkedAt) < c.ttl {
+		return entry.suspended, nil
+	}
+
+	suspended, err := c.repository.IsSuspended(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.suspended[userID] = suspensionCacheEntry{suspended: suspended, checkedAt: now}
+	c.prune(now)
+	return suspended, nil
+}
+
+// prune drops entries that are no longer used so the maps do not grow forever. c.mu
+// must be held.
+func (c *SessionCache) prune(now time.Time) {
+	if now.Sub(c.prunedAt) <= time.Hour {
+		return
+	}
+	for key, e := range c.entries {
+		if now.Sub(e.checkedAt) > time.Hour {
+			delete(c.entries, key)
+		}
+	}
+	for key, e := range c.suspended {
+		if now.Sub(e.checkedAt) > time.Hour {
+			delete(c.suspended, key)
+		}
+	}
+	c.prunedAt = now
+}
+
+// MarkRevoked makes revocations done by this replica effective immediately.
+func (c *SessionCache) MarkRevoked(sessionIDs ...uuid.UUID) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range sessionIDs {
+		c.entries[id.String()] = sessionCacheEntry{revoked: true, checkedAt: now}
+	}
+}
+
+// MarkSuspended makes suspensions done by this replica effective immediately.
+func (c *SessionCache) MarkSuspended(userID uint64, suspended bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.suspended[userID] = suspensionCacheEntry{suspended: suspended, checkedAt: time.Now()}
+}
@@ -0,0 +1,136 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginFailure counts the failed logins of a username or an IP address. Failures
+// more than a window apart start counting from one again.
+type LoginFailure struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+}
+
+// ThrottlePolicy decides how long logins for a key are refused after it failed.
+// The first FreeAttempts failures cost nothing, every further failure doubles the
+// wait starting at BaseDelay up to MaxDelay, and from LockoutAttempts failures on
+// the key is locked for LockoutDuration. Failures are forgotten after Window.
+type ThrottlePolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAttempts int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+// Delay is how long after the last of failures logins are refused.
+func (p ThrottlePolicy) Delay(failures int) time.Duration {
+	if p.LockoutAttempts > 0 && failures >= p.LockoutAttempts {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// window is how long failures are remembered, at least as long as a lockout lasts.
+func (p ThrottlePolicy) window() time.Duration {
+	return max(p.Window, p.LockoutDuration, p.MaxDelay)
+}
+
+// LoginThrottle slows down password guessing against one username and password
+// spraying from one IP address. Failures are kept in the database so every replica
+// applies the same limits.
+type LoginThrottle struct {
+	repository     Repository
+	usernamePolicy ThrottlePolicy
+	ipPolicy       ThrottlePolicy
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+func NewLoginThrottle(repository Repository, usernamePolicy, ipPolicy ThrottlePolicy) *LoginThrottle {
+	return &LoginThrottle{
+		repository:     repository,
+		usernamePolicy: usernamePolicy,
+		ipPolicy:       ipPolicy,
+	}
+}
+
+// Check returns how long logins for username from ip are still refused, or zero.
+func (t *LoginThrottle) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	usernameKey, ipKey := throttleKeys(username, ip)
+	failures, err := t.repository.GetLoginFailures(ctx, []string{usernameKey, ipKey})
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var wait time.Duration
+	for _, f := range failures {
+		policy := t.usernamePolicy
+		if f.Key == ipKey {
+			policy = t.ipPolicy
+		}
+		if now.Sub(f.LastFailedAt) > policy.window() {
+			continue
+		}
+		wait = max(wait, f.LastFailedAt.Add(policy.Delay(f.Failures)).Sub(now))
+	}
+	return wait, nil
+}
+
+// Fail records a failed login for username from ip.
+func (t *LoginThrottle) Fail(ctx context.Context, username, ip string) error {
+	usernameKey, ipKey := throttleKeys(username, ip)
+	now := time.Now()
+	err := t.repository.RecordLoginFailure(ctx, usernameKey, now, now.Add(-t.usernamePolicy.window()))
+	if err != nil {
+		return err
+	}
+	err = t.repository.RecordLoginFailure(ctx, ipKey, now, now.Add(-t.ipPolicy.window()))
+	if err != nil {
+		return err
+	}
+	t.prune(ctx, now)
+	return nil
+}
+
+// Succeed forgets the failures of username. Those of the IP address are kept, a
+// successful login to an account of one's own says nothing about the others.
+func (t *LoginThrottle) Succeed(ctx context.Context, username string) error {
+	usernameKey, _ := throttleKeys(username, "")
+	return t.repository.ClearLoginFailures(ctx, usernameKey)
+}
+
+// prune deletes failures that are no longer remembered, at most once an hour.
+func (t *LoginThrottle) prune(ctx context.Context, now time.Time) {
+	t.mu.Lock()
+	if now.Sub(t.prunedAt) <= time.Hour {
+		t.mu.Unlock()
+		return
+	}
+	t.prunedAt = now
+	t.mu.Unlock()
+
+	before := now.Add(-max(t.usernamePolicy.window(), t.ipPolicy.window()))
+	if err := t.repository.PruneLoginFailures(ctx, before); err != nil {
+		slog.Error(fmt.Sprintf("cannot prune login failures: %v", err))
+	}
+}
+
+func throttleKeys(username, ip string) (string, string) {
+	return "username:" + strings.ToLower(username), "ip:" + ip
+}
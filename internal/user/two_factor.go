@@ -0,0 +1,225 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/encryption"
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/totp"
+	"github.com/google/uuid"
+)
+
+const (
+	// totpSkew is how many 30 second steps a code may be early or late.
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes a user gets, each usable once.
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of base32 characters of a recovery code, 50 bits.
+	recoveryCodeLength = 10
+)
+
+// TOTP is the authenticator app of a user. It is pending until the user confirms
+// it with a first code, only confirmed TOTPs are asked for at login. The secret is
+// kept sealed like bank account numbers.
+type TOTP struct {
+	UserID       uint64
+	Secret       encryption.Sealed
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
+}
+
+func (t *TOTP) Confirmed() bool {
+	return t.ConfirmedAt.Valid
+}
+
+// TwoFactor manages the second factor of users: their TOTP secret, recovery codes
+// and the challenge tokens that connect the two steps of a login.
+type TwoFactor struct {
+	repository   Repository
+	keyring      *encryption.Keyring
+	issuer       string
+	challengeTTL time.Duration
+}
+
+func NewTwoFactor(repository Repository, keyring *encryption.Keyring, issuer string, challengeTTL time.Duration) *TwoFactor {
+	return &TwoFactor{
+		repository:   repository,
+		keyring:      keyring,
+		issuer:       issuer,
+		challengeTTL: challengeTTL,
+	}
+}
+
+// Enabled reports whether the user has a confirmed TOTP.
+func (f *TwoFactor) Enabled(ctx context.Context, userID uint64) (bool, error) {
+	t, err := f.repository.GetTOTP(ctx, userID)
+	if errors.Is(err, ErrTwoFactorNotEnabled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.Confirmed(), nil
+}
+
+// Begin creates a new pending secret for user, replacing an earlier pending one, and
+// returns it with its otpauth URI.
+func (f *TwoFactor) Begin(ctx context.Context, user *User) ([]byte, string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	sealed, err := f.keyring.Seal(secret)
+	if err != nil {
+		return nil, "", err
+	}
+	err = f.repository.SaveTOTP(ctx, &TOTP{UserID: user.ID, Secret: *sealed})
+	if err != nil {
+		return nil, "", err
+	}
+	return secret, totp.URI(f.issuer, user.Username, secret), nil
+}
+
+// Confirm enables the pending TOTP of the user if code matches it, and returns their
+// first recovery codes.
+func (f *TwoFactor) Confirm(ctx context.Context, userID uint64, code string) ([]string, error) {
+	t, err := f.repository.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Confirmed() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	step, ok, err := f.validate(t, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	err = f.repository.ConfirmTOTP(ctx, userID, step)
+	if err != nil {
+		return nil, err
+	}
+	return f.RegenerateRecoveryCodes(ctx, userID)
+}
+
+// Verify checks code, a TOTP code or an unused recovery code, against the confirmed
+// TOTP of the user. Every code works once.
+func (f *TwoFactor) Verify(ctx context.Context, userID uint64, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) != totp.Digits {
+		used, err := f.repository.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+	t, err := f.repository.GetTOTP(ctx, userID)
+	if errors.Is(err, ErrTwoFactorNotEnabled) {
+		return ErrInvalidCode
+	}
+	if err != nil {
+		return err
+	}
+	if !t.Confirmed() {
+		return ErrInvalidCode
+	}
+	step, ok, err := f.validate(t, code)
+	if err != nil {
+		return err
+	}
+	// a step at or before the last used one is a replayed code
+	if !ok || step <= t.LastUsedStep {
+		return ErrInvalidCode
+	}
+	used, err := f.repository.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func (f *TwoFactor) validate(t *TOTP, code string) (int64, bool, error) {
+	secret, err := f.keyring.Open(&t.Secret)
+	if err != nil {
+		return 0, false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), totpSkew)
+	return step, ok, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user. Only their hashes
+// are stored, the codes are shown once.
+func (f *TwoFactor) RegenerateRecoveryCodes(ctx context.Context, userID uint64) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+	err := f.repository.ReplaceRecoveryCodes(ctx, userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable removes the TOTP and recovery codes of the user.
+func (f *TwoFactor) Disable(ctx context.Context, userID uint64) error {
+	return f.repository.DeleteTOTP(ctx, userID)
+}
+
+// HasFreshSecondFactor implements middleware.SecondFactorChecker. Users without a
+// TOTP have nothing to verify and always pass.
+func (f *TwoFactor) HasFreshSecondFactor(ctx context.Context, userID uint64, sessionID string, maxAge time.Duration) (bool, error) {
+	enabled, err := f.Enabled(ctx, userID)
+	if err != nil || !enabled {
+		return !enabled, err
+	}
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return false, nil
+	}
+	session, err := f.repository.GetSession(ctx, id)
+	if errors.Is(err, ErrSessionNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return session.SecondFactorAt.Valid && time.Since(session.SecondFactorAt.Time) <= maxAge, nil
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCode returns a code like "abcde-fghij".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength*5/8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
+
+// normalizeRecoveryCode accepts codes typed without the dash or in upper case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -1,9 +1,58 @@
 package user
 
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+
+	"github.com/citadel-corp/shopifyx-marketplace/internal/common/role"
+)
+
 type User struct {
 	ID               uint64
 	Username         string
 	Name             string
 	ProductSoldTotal int
 	HashedPassword   string
+	Roles            []role.Role
+	Email            sql.NullString
+	EmailVerifiedAt  sql.NullTime
+	SuspendedAt      sql.NullTime
+	SuspensionReason string
+	CreatedAt        sql.NullTime
+	DeletedAt        sql.NullTime
+}
+
+// Profile is a user with counts of what they own.
+type Profile struct {
+	User
+	ProductCount     int
+	BankAccountCount int
+	TwoFactorEnabled bool
+}
+
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt.Valid
+}
+
+// IsDeleted reports whether the user deleted their account. The row stays, stripped of
+// personal data, for the purchases that refer to it.
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt.Valid
+}
+
+// EmailVerified reports whether the user proved to own their email address. Only
+// verified addresses receive password resets.
+func (u *User) EmailVerified() bool {
+	return u.Email.Valid && u.EmailVerifiedAt.Valid
+}
+
+// deletedUsername returns a random username for a deleted user, which frees the old
+// one for others.
+func deletedUsername() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "deleted" + hex.EncodeToString(b), nil
 }